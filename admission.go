@@ -0,0 +1,72 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package eventlogger
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// admission counts the callers which are using something (such as a Broker
+// or graph) without locking, and stops admitting callers once it's closed so
+// that the closer can wait for those which were already admitted.  The zero
+// value is ready to use.
+type admission struct {
+	// state is the number of admitted callers shifted left by one, with the
+	// lowest bit set once closed.
+	state atomic.Uint64
+
+	initOnce sync.Once
+	doneOnce sync.Once
+	drained  chan struct{}
+}
+
+// enter admits a caller, returning false if the admission is closed.  Callers
+// which are admitted must call leave.
+func (a *admission) enter() bool {
+	if a.state.Add(2)&1 != 0 {
+		a.leave()
+		return false
+	}
+	return true
+}
+
+// join admits another caller on behalf of one which has been admitted and
+// hasn't left, even once the admission has been closed.  Callers which join
+// must call leave.
+func (a *admission) join() {
+	a.state.Add(2)
+}
+
+// leave records that an admitted caller has finished.
+func (a *admission) leave() {
+	if a.state.Add(^uint64(1)) == 1 {
+		a.signal()
+	}
+}
+
+// close stops admitting callers.  It may be called more than once.
+func (a *admission) close() {
+	if a.state.Or(1) == 0 {
+		a.signal()
+	}
+}
+
+// closed returns true once the admission has been closed.
+func (a *admission) closed() bool {
+	return a.state.Load()&1 != 0
+}
+
+// done returns a channel which is closed once the admission has been closed
+// and every admitted caller has left.
+func (a *admission) done() <-chan struct{} {
+	a.initOnce.Do(func() { a.drained = make(chan struct{}) })
+	return a.drained
+}
+
+// signal closes the done channel.
+func (a *admission) signal() {
+	a.done()
+	a.doneOnce.Do(func() { close(a.drained) })
+}
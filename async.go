@@ -0,0 +1,340 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package eventlogger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// OverflowPolicy is used to specify what SendAsync should do when the Broker's
+// async queue is full.
+type OverflowPolicy string
+
+const (
+	// OverflowBlock waits until there is room in the queue, or until the
+	// context passed to SendAsync is done.
+	OverflowBlock OverflowPolicy = "Block"
+	// OverflowDropNewest discards the event being sent.  Its SendFuture is
+	// completed with ErrEventDropped.
+	OverflowDropNewest OverflowPolicy = "DropNewest"
+	// OverflowDropOldest discards the oldest queued event to make room for the
+	// event being sent.  The discarded event's SendFuture is completed with
+	// ErrEventDropped.
+	OverflowDropOldest OverflowPolicy = "DropOldest"
+	// OverflowError returns ErrQueueFull from SendAsync.
+	OverflowError OverflowPolicy = "Error"
+)
+
+// SendFuture represents the eventual result of an event sent via SendAsync.
+type SendFuture struct {
+	done chan struct{}
+
+	l         sync.Mutex
+	status    Status
+	err       error
+	callbacks []func(Status, error)
+}
+
+func newSendFuture() *SendFuture {
+	return &SendFuture{done: make(chan struct{})}
+}
+
+// Done returns a channel which is closed once the event has been processed,
+// dropped or lost.
+func (f *SendFuture) Done() <-chan struct{} {
+	return f.done
+}
+
+// Wait blocks until the event has been processed, dropped or lost and then
+// returns the same Status and error that Send would have.  If ctx is done
+// first, ctx.Err() is returned; the event is still processed.
+func (f *SendFuture) Wait(ctx context.Context) (Status, error) {
+	select {
+	case <-ctx.Done():
+		return Status{}, ctx.Err()
+	case <-f.done:
+	}
+
+	f.l.Lock()
+	defer f.l.Unlock()
+	return f.status, f.err
+}
+
+// OnComplete registers a func which will be called with the result of the
+// send.  If the send has already completed, fn is called immediately.
+// Callbacks registered before completion are called on the goroutine which
+// completes the send, so they should not block.
+func (f *SendFuture) OnComplete(fn func(Status, error)) {
+	if fn == nil {
+		return
+	}
+
+	f.l.Lock()
+	select {
+	case <-f.done:
+		s, err := f.status, f.err
+		f.l.Unlock()
+		fn(s, err)
+	default:
+		f.callbacks = append(f.callbacks, fn)
+		f.l.Unlock()
+	}
+}
+
+// complete records the result and notifies any waiters. Only the first call
+// has any effect.
+func (f *SendFuture) complete(s Status, err error) {
+	f.l.Lock()
+	select {
+	case <-f.done:
+		f.l.Unlock()
+		return
+	default:
+	}
+	f.status, f.err = s, err
+	callbacks := f.callbacks
+	f.callbacks = nil
+	close(f.done)
+	f.l.Unlock()
+
+	for _, fn := range callbacks {
+		fn(s, err)
+	}
+}
+
+// asyncEvent is an event waiting in the asyncQueue.
+type asyncEvent struct {
+	ctx    context.Context
+	event  *Event
+	future *SendFuture
+}
+
+// asyncQueue is a bounded queue of events which is drained by a pool of
+// workers, each of which sends the event through the Broker.
+type asyncQueue struct {
+	b      *Broker
+	events chan *asyncEvent
+	policy OverflowPolicy
+
+	// l guards stopped, so that once stopped is set no more events are
+	// enqueued.
+	l       sync.RWMutex
+	stopped bool
+
+	// stopping is closed to release any callers blocked on a full queue.
+	stopping chan struct{}
+	// drain is closed to tell the workers to process what's left in the
+	// queue and then exit.
+	drain chan struct{}
+	// abandon is closed to tell the workers that any events still queued must
+	// be reported as lost rather than processed.
+	abandon chan struct{}
+
+	stopOnce    sync.Once
+	abandonOnce sync.Once
+	workers     sync.WaitGroup
+}
+
+func newAsyncQueue(b *Broker, size, workers int, policy OverflowPolicy) *asyncQueue {
+	q := &asyncQueue{
+		b:        b,
+		events:   make(chan *asyncEvent, size),
+		policy:   policy,
+		stopping: make(chan struct{}),
+		drain:    make(chan struct{}),
+		abandon:  make(chan struct{}),
+	}
+
+	q.workers.Add(workers)
+	for i := 0; i < workers; i++ {
+		go q.work()
+	}
+
+	return q
+}
+
+// work processes queued events until the queue is stopped, at which point the
+// remaining events are drained before returning.
+func (q *asyncQueue) work() {
+	defer q.workers.Done()
+
+	for {
+		select {
+		case ae := <-q.events:
+			q.process(ae)
+		case <-q.drain:
+			for {
+				select {
+				case ae := <-q.events:
+					q.process(ae)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// process sends a queued event, unless the queue has been abandoned in which
+// case the event is reported as lost.
+func (q *asyncQueue) process(ae *asyncEvent) {
+	select {
+	case <-q.abandon:
+		ae.future.complete(Status{}, ErrEventLost)
+		return
+	default:
+	}
+
+	// The caller of SendAsync has most likely moved on, so their context's
+	// cancellation must not apply to the processing of the event.
+	s, err := q.b.send(context.WithoutCancel(ae.ctx), ae.event)
+	ae.future.complete(s, err)
+}
+
+// enqueue adds the event to the queue according to the queue's OverflowPolicy.
+func (q *asyncQueue) enqueue(ctx context.Context, ae *asyncEvent) error {
+	q.l.RLock()
+	defer q.l.RUnlock()
+
+	if q.stopped {
+		return ErrAsyncStopped
+	}
+
+	switch q.policy {
+	case OverflowDropNewest:
+		select {
+		case q.events <- ae:
+		default:
+			ae.future.complete(Status{}, ErrEventDropped)
+		}
+	case OverflowDropOldest:
+		for {
+			select {
+			case q.events <- ae:
+				return nil
+			default:
+			}
+			select {
+			case old := <-q.events:
+				old.future.complete(Status{}, ErrEventDropped)
+			default:
+			}
+		}
+	case OverflowError:
+		select {
+		case q.events <- ae:
+		default:
+			return ErrQueueFull
+		}
+	default:
+		select {
+		case q.events <- ae:
+		case <-q.stopping:
+			return ErrAsyncStopped
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	return nil
+}
+
+// stop prevents further events from being enqueued and waits for the workers
+// to drain the queue.  If ctx is done before the queue is drained, the events
+// still queued are reported as lost.
+func (q *asyncQueue) stop(ctx context.Context) error {
+	q.stopOnce.Do(func() {
+		close(q.stopping)
+		q.l.Lock()
+		q.stopped = true
+		q.l.Unlock()
+		close(q.drain)
+	})
+
+	done := make(chan struct{})
+	go func() {
+		q.workers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+	}
+
+	q.abandonOnce.Do(func() { close(q.abandon) })
+
+DRAIN:
+	for {
+		select {
+		case ae := <-q.events:
+			ae.future.complete(Status{}, ErrEventLost)
+		default:
+			break DRAIN
+		}
+	}
+
+	return errors.Join(fmt.Errorf("unable to process all queued events: %w", ErrEventLost), ctx.Err())
+}
+
+// SendAsync enqueues an event of type t to be sent to all registered pipelines
+// by the Broker's async workers, and returns a SendFuture which can be used to
+// await the eventual Status.  The Broker must have been created using
+// WithAsyncQueue.
+//
+// What happens when the queue is full depends on the OverflowPolicy configured
+// via WithOverflowPolicy (default: OverflowBlock).
+//
+// The event is created (and timestamped, and populated by the Broker's
+// ContextExtractors) when SendAsync is called.  The ctx is
+// only used to wait for room in the queue; it is not used to cancel processing
+// of the event, although its values are retained.
+//
+// Accepted options: the same as SendWithOptions.
+func (b *Broker) SendAsync(ctx context.Context, t EventType, payload interface{}, opt ...Option) (*SendFuture, error) {
+	if b.async == nil {
+		return nil, ErrAsyncNotEnabled
+	}
+	opts, err := getOpts(opt...)
+	if err != nil {
+		return nil, fmt.Errorf("cannot send event: %w", err)
+	}
+
+	if b.admission.closed() {
+		return nil, ErrBrokerClosed
+	}
+
+	e, err := b.newEvent(ctx, t, payload, opts)
+	if err != nil {
+		return nil, fmt.Errorf("cannot send event: %w", err)
+	}
+	ae := &asyncEvent{
+		ctx:    ctx,
+		event:  e,
+		future: newSendFuture(),
+	}
+
+	if err := b.async.enqueue(ctx, ae); err != nil {
+		return nil, err
+	}
+
+	return ae.future, nil
+}
+
+// StopAsync stops the Broker accepting events via SendAsync and waits for any
+// queued events to be processed. If ctx is done before the queue has been
+// drained, the events which are still queued are completed with ErrEventLost
+// and an error is returned.  StopAsync is a no-op if the Broker was not created
+// using WithAsyncQueue.
+func (b *Broker) StopAsync(ctx context.Context) error {
+	if b.async == nil {
+		return nil
+	}
+
+	return b.async.stop(ctx)
+}
@@ -0,0 +1,238 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package eventlogger
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// blockingSink returns a sink node which signals on started each time it begins
+// processing an event and then blocks until release is closed.
+func blockingSink(started chan<- struct{}, release <-chan struct{}, processed *atomic.Int32) *testActionNode {
+	return &testActionNode{
+		nodeType: NodeTypeSink,
+		action: func(ctx context.Context, e *Event) (*Event, error) {
+			started <- struct{}{}
+			<-release
+			processed.Add(1)
+			return nil, nil
+		},
+	}
+}
+
+func TestBroker_SendAsync(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	sink := &testSink{}
+	b := newTestBroker(t, map[NodeID]Node{"sink": sink}, []Pipeline{testPipeline}, WithAsyncQueue(10, 1))
+	require.NoError(t, b.SetSuccessThresholdSinks("t", 1))
+
+	var callbacks atomic.Int32
+	futures := make([]*SendFuture, 5)
+	for i := range futures {
+		f, err := b.SendAsync(ctx, "t", i)
+		require.NoError(t, err)
+		f.OnComplete(func(Status, error) { callbacks.Add(1) })
+		futures[i] = f
+	}
+
+	for _, f := range futures {
+		s, err := f.Wait(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, []NodeID{"sink"}, s.CompleteSinks())
+	}
+
+	require.NoError(t, b.StopAsync(ctx))
+	assert.Equal(t, 5, sink.count)
+	assert.Equal(t, int32(5), callbacks.Load())
+
+	// Callbacks registered after completion are invoked immediately.
+	called := false
+	futures[0].OnComplete(func(Status, error) { called = true })
+	assert.True(t, called)
+
+	_, err := b.SendAsync(ctx, "t", "too late")
+	require.ErrorIs(t, err, ErrAsyncStopped)
+}
+
+func TestBroker_SendAsync_NotEnabled(t *testing.T) {
+	t.Parallel()
+
+	b, err := NewBroker()
+	require.NoError(t, err)
+	_, err = b.SendAsync(context.Background(), "t", nil)
+	require.ErrorIs(t, err, ErrAsyncNotEnabled)
+	require.NoError(t, b.StopAsync(context.Background()))
+}
+
+func TestBroker_SendAsync_UnregisteredEventType(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	b, err := NewBroker(WithAsyncQueue(1, 1))
+	require.NoError(t, err)
+
+	f, err := b.SendAsync(ctx, "unknown", nil)
+	require.NoError(t, err)
+	_, err = f.Wait(ctx)
+	require.EqualError(t, err, "no graph for EventType unknown")
+	require.NoError(t, b.StopAsync(ctx))
+}
+
+func TestBroker_SendAsync_OverflowPolicy(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		policy           OverflowPolicy
+		wantSendErr      error
+		wantErrs         []error
+		wantProcessed    int32
+		sendWithDeadline bool
+	}{
+		"block": {
+			policy:           OverflowBlock,
+			sendWithDeadline: true,
+			wantSendErr:      context.DeadlineExceeded,
+			wantErrs:         []error{nil, nil},
+			wantProcessed:    2,
+		},
+		"drop-newest": {
+			policy:        OverflowDropNewest,
+			wantErrs:      []error{nil, nil, ErrEventDropped},
+			wantProcessed: 2,
+		},
+		"drop-oldest": {
+			policy:        OverflowDropOldest,
+			wantErrs:      []error{nil, ErrEventDropped, nil},
+			wantProcessed: 2,
+		},
+		"error": {
+			policy:        OverflowError,
+			wantSendErr:   ErrQueueFull,
+			wantErrs:      []error{nil, nil},
+			wantProcessed: 2,
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+			started, release := make(chan struct{}, 3), make(chan struct{})
+			var processed atomic.Int32
+			sink := blockingSink(started, release, &processed)
+			b := newTestBroker(t, map[NodeID]Node{"sink": sink}, []Pipeline{testPipeline}, WithAsyncQueue(1, 1), WithOverflowPolicy(tc.policy))
+			require.NoError(t, b.SetSuccessThresholdSinks("t", 1))
+
+			// The first event occupies the only worker, the second fills the queue.
+			var futures []*SendFuture
+			f, err := b.SendAsync(ctx, "t", 1)
+			require.NoError(t, err)
+			futures = append(futures, f)
+			<-started
+			f, err = b.SendAsync(ctx, "t", 2)
+			require.NoError(t, err)
+			futures = append(futures, f)
+
+			sendCtx := ctx
+			if tc.sendWithDeadline {
+				var cancel context.CancelFunc
+				sendCtx, cancel = context.WithTimeout(ctx, 10*time.Millisecond)
+				defer cancel()
+			}
+			f, err = b.SendAsync(sendCtx, "t", 3)
+			switch tc.wantSendErr {
+			case nil:
+				require.NoError(t, err)
+				futures = append(futures, f)
+			default:
+				require.ErrorIs(t, err, tc.wantSendErr)
+			}
+
+			close(release)
+			require.Len(t, futures, len(tc.wantErrs))
+			for i, f := range futures {
+				_, err := f.Wait(ctx)
+				switch tc.wantErrs[i] {
+				case nil:
+					assert.NoError(t, err, "future %d", i)
+				default:
+					assert.ErrorIs(t, err, tc.wantErrs[i], "future %d", i)
+				}
+			}
+
+			require.NoError(t, b.StopAsync(ctx))
+			assert.Equal(t, tc.wantProcessed, processed.Load())
+		})
+	}
+}
+
+func TestBroker_StopAsync_Lost(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	started, release := make(chan struct{}, 2), make(chan struct{})
+	var processed atomic.Int32
+	sink := blockingSink(started, release, &processed)
+	b := newTestBroker(t, map[NodeID]Node{"sink": sink}, []Pipeline{testPipeline}, WithAsyncQueue(5, 1))
+	require.NoError(t, b.SetSuccessThresholdSinks("t", 1))
+
+	inFlight, err := b.SendAsync(ctx, "t", 1)
+	require.NoError(t, err)
+	<-started
+	queued, err := b.SendAsync(ctx, "t", 2)
+	require.NoError(t, err)
+
+	stopCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	err = b.StopAsync(stopCtx)
+	require.ErrorIs(t, err, ErrEventLost)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	_, err = queued.Wait(ctx)
+	require.ErrorIs(t, err, ErrEventLost)
+
+	// The event which was already being processed is allowed to finish.
+	close(release)
+	_, err = inFlight.Wait(ctx)
+	require.NoError(t, err)
+	require.NoError(t, b.StopAsync(ctx))
+	assert.Equal(t, int32(1), processed.Load())
+}
+
+func TestBroker_SendFuture_WaitContext(t *testing.T) {
+	t.Parallel()
+
+	f := newSendFuture()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := f.Wait(ctx)
+	require.ErrorIs(t, err, context.Canceled)
+
+	f.complete(Status{Warnings: []error{ErrEventDropped}}, nil)
+	f.complete(Status{}, ErrEventLost)
+	s, err := f.Wait(context.Background())
+	require.NoError(t, err)
+	require.Len(t, s.Warnings, 1)
+}
+
+func TestNewBroker_AsyncOptions(t *testing.T) {
+	t.Parallel()
+
+	_, err := NewBroker(WithAsyncQueue(0, 1))
+	require.ErrorIs(t, err, ErrInvalidParameter)
+	_, err = NewBroker(WithAsyncQueue(1, 0))
+	require.ErrorIs(t, err, ErrInvalidParameter)
+	_, err = NewBroker(WithOverflowPolicy("bad"))
+	require.EqualError(t, err, "cannot create broker: 'bad' is not a valid overflow policy: invalid parameter")
+}
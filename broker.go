@@ -7,7 +7,9 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/hashicorp/go-multierror"
@@ -42,9 +44,52 @@ const (
 //
 // A Node can be shared across multiple pipelines.
 type Broker struct {
-	nodes  map[NodeID]*nodeUsage
-	graphs map[EventType]*graph
-	lock   sync.RWMutex
+	registry
+	lock sync.RWMutex
+
+	// writeLock serializes changes to the registry, so that nodes can be
+	// opened without holding lock, which would block reading the registry.
+	// It's acquired before lock.
+	writeLock sync.Mutex
+
+	// async is only configured when the Broker is created using WithAsyncQueue.
+	async *asyncQueue
+
+	// retired are nodes which a Transaction removed, but couldn't close
+	// before its context was done.  They're closed by Close.
+	retired []retiredNode
+
+	// draining are graphs which have been replaced, and which may still be
+	// processing events.
+	draining []*graph
+
+	// closed is set by Close, after which events are no longer accepted.
+	closed bool
+
+	// admission tracks the events being sent via Send, so that Close can wait
+	// for them.
+	admission admission
+
+	// deadLetters tracks the dead letters being sent, so that Close can wait
+	// for them.
+	deadLetters admission
+
+	// routes is used to route events without locking, see routingTable.
+	routes atomic.Pointer[routingTable]
+
+	// extractors populate each Event from the context passed to Send.
+	extractors []ContextExtractor
+
+	// idGenerator generates the IDs of events, when configured.
+	idGenerator IDGenerator
+
+	// sequences holds the last sequence number (*atomic.Uint64) of each
+	// EventType.
+	sequences sync.Map
+
+	// payloadTypes holds the payload type (reflect.Type) registered for each
+	// EventType, see RegisterPayloadType.
+	payloadTypes sync.Map
 
 	*clock
 }
@@ -54,6 +99,10 @@ type nodeUsage struct {
 	node               Node
 	referenceCount     int
 	registrationPolicy RegistrationPolicy
+	settings           nodeSettings
+
+	// opened is true once the node has been opened, see Opener.
+	opened bool
 }
 
 // Option allows options to be passed as arguments.
@@ -63,6 +112,25 @@ type Option func(*options) error
 type options struct {
 	withPipelineRegistrationPolicy RegistrationPolicy
 	withNodeRegistrationPolicy     RegistrationPolicy
+	withAsyncQueueSize             int
+	withAsyncWorkers               int
+	withOverflowPolicy             OverflowPolicy
+	withObserver                   Observer
+	withNodeTimeout                time.Duration
+	withPipelineTimeout            time.Duration
+	withNodeRetryPolicy            *RetryPolicy
+	withWorkerPoolSize             int
+	withSaturationPolicy           SaturationPolicy
+	withRequiredPipeline           bool
+	withRequiredSinks              []NodeID
+	withPanicPolicy                PanicPolicy
+	withMetadata                   map[string]string
+	withContextExtractors          []ContextExtractor
+	withCreatedAt                  time.Time
+	withEventID                    string
+	withIDGenerator                IDGenerator
+	withReopenConcurrency          int
+	withOpenContext                context.Context
 }
 
 // getDefaultOptions returns a set of default options
@@ -70,6 +138,11 @@ func getDefaultOptions() options {
 	return options{
 		withPipelineRegistrationPolicy: AllowOverwrite,
 		withNodeRegistrationPolicy:     AllowOverwrite,
+		withOverflowPolicy:             OverflowBlock,
+		withSaturationPolicy:           SaturationCallerRuns,
+		withPanicPolicy:                PanicRecover,
+		withReopenConcurrency:          1,
+		withOpenContext:                context.Background(),
 	}
 }
 
@@ -121,12 +194,98 @@ func WithNodeRegistrationPolicy(policy RegistrationPolicy) Option {
 	}
 }
 
+// WithAsyncQueue configures the option that enables SendAsync, using a queue
+// which can hold size events and is drained by the specified number of workers.
+func WithAsyncQueue(size, workers int) Option {
+	return func(o *options) error {
+		switch {
+		case size < 1:
+			return fmt.Errorf("async queue size must be greater than 0: %w", ErrInvalidParameter)
+		case workers < 1:
+			return fmt.Errorf("async queue workers must be greater than 0: %w", ErrInvalidParameter)
+		}
+
+		o.withAsyncQueueSize = size
+		o.withAsyncWorkers = workers
+		return nil
+	}
+}
+
+// WithOverflowPolicy configures the option that determines what SendAsync
+// does when the async queue is full.
+func WithOverflowPolicy(policy OverflowPolicy) Option {
+	return func(o *options) error {
+		var err error
+
+		switch policy {
+		case OverflowBlock, OverflowDropNewest, OverflowDropOldest, OverflowError:
+			o.withOverflowPolicy = policy
+		default:
+			err = fmt.Errorf("'%s' is not a valid overflow policy: %w", policy, ErrInvalidParameter)
+		}
+
+		return err
+	}
+}
+
+// WithNodeTimeout configures the option that determines how long a node may
+// take to process an event, when registering a node. A timeout of 0 (the
+// default) means the node may take as long as the context passed to Send allows.
+func WithNodeTimeout(timeout time.Duration) Option {
+	return func(o *options) error {
+		if timeout < 0 {
+			return fmt.Errorf("node timeout cannot be negative: %w", ErrInvalidParameter)
+		}
+		o.withNodeTimeout = timeout
+		return nil
+	}
+}
+
+// WithPipelineTimeout configures the option that determines how long a
+// pipeline may take to process an event, when registering a pipeline. A
+// timeout of 0 (the default) means the pipeline may take as long as the context
+// passed to Send allows.
+func WithPipelineTimeout(timeout time.Duration) Option {
+	return func(o *options) error {
+		if timeout < 0 {
+			return fmt.Errorf("pipeline timeout cannot be negative: %w", ErrInvalidParameter)
+		}
+		o.withPipelineTimeout = timeout
+		return nil
+	}
+}
+
 // NewBroker creates a new Broker applying any relevant supplied options.
-// Options are currently accepted, but none are applied.
-func NewBroker(_ ...Option) (*Broker, error) {
+// Accepted options: WithAsyncQueue, WithOverflowPolicy (default: OverflowBlock),
+// WithObserver, WithWorkerPool, WithSaturationPolicy (default:
+// SaturationCallerRuns), WithPanicPolicy (default: PanicRecover),
+// WithContextExtractor, WithIDGenerator.
+//
+// When WithAsyncQueue is used, the Broker starts workers to process events
+// sent via SendAsync and StopAsync must be called to stop them.
+func NewBroker(opt ...Option) (*Broker, error) {
+	opts, err := getOpts(opt...)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create broker: %w", err)
+	}
+
 	b := &Broker{
-		nodes:  make(map[NodeID]*nodeUsage),
-		graphs: make(map[EventType]*graph),
+		registry: registry{
+			nodes:       make(map[NodeID]*nodeUsage),
+			graphs:      make(map[EventType]*graph),
+			observer:    opts.withObserver,
+			panicPolicy: opts.withPanicPolicy,
+		},
+	}
+	b.extractors = opts.withContextExtractors
+	b.idGenerator = opts.withIDGenerator
+	if opts.withWorkerPoolSize > 0 {
+		b.pool = newWorkerPool(opts.withWorkerPoolSize, opts.withSaturationPolicy)
+	}
+	b.publishRoutes()
+
+	if opts.withAsyncQueueSize > 0 {
+		b.async = newAsyncQueue(b, opts.withAsyncQueueSize, opts.withAsyncWorkers, opts.withOverflowPolicy)
 	}
 
 	return b, nil
@@ -160,7 +319,12 @@ type Status struct {
 	// the Event, resulting in immediate completion of a particular Pipeline.
 	completeSinks []NodeID
 	// Warnings lists any non-fatal errors that occurred while sending an Event.
+	// Errors from nodes are reported as a *NodeError, which identifies the node.
 	Warnings []error
+	// deadLetters describes the nodes which failed to process the Event.
+	deadLetters []*DeadLetter
+	// pipelines describes how each pipeline finished processing the Event.
+	pipelines []PipelineResult
 }
 
 // Complete returns the IDs of 'filter' and 'sink' type nodes that successfully
@@ -192,13 +356,48 @@ func (s Status) getError(ctxErr error, threshold, thresholdSinks int) error {
 // Send writes an event of type t to all registered pipelines concurrently and
 // reports on the result.  An error will only be returned if a pipeline's delivery
 // policies could not be satisfied.
+//
+// The Broker's ContextExtractors (see WithContextExtractor) populate the event
+// from ctx before it's processed.  When a payload type is registered for t (see
+// RegisterPayloadType), a payload which doesn't match it is rejected with a
+// *PayloadTypeError.
+//
+// Use SendWithOptions to set the event's fields when sending it.
 func (b *Broker) Send(ctx context.Context, t EventType, payload interface{}) (Status, error) {
-	b.lock.RLock()
-	g, ok := b.graphs[t]
-	b.lock.RUnlock()
+	return b.SendWithOptions(ctx, t, payload)
+}
 
-	if !ok {
-		return Status{}, fmt.Errorf("no graph for EventType %s", t)
+// SendWithOptions is Send, with options which set the event's fields.  They
+// take precedence over the Broker's ContextExtractors, and an error is returned
+// if they're invalid.
+//
+// Accepted options: WithCreatedAt, WithEventID, WithMetadata.
+func (b *Broker) SendWithOptions(ctx context.Context, t EventType, payload interface{}, opt ...Option) (Status, error) {
+	opts, err := getOpts(opt...)
+	if err != nil {
+		return Status{}, fmt.Errorf("cannot send event: %w", err)
+	}
+
+	if !b.admission.enter() {
+		return Status{}, ErrBrokerClosed
+	}
+	defer b.admission.leave()
+
+	e, err := b.newEvent(ctx, t, payload, opts)
+	if err != nil {
+		return Status{}, fmt.Errorf("cannot send event: %w", err)
+	}
+	return b.send(ctx, e)
+}
+
+// newEvent creates an Event of type t, timestamped using the Broker's clock.
+// The Broker's ContextExtractors populate it from ctx, and then the options
+// given when sending it are applied, so that they take precedence.  Finally an
+// ID is generated, if it doesn't have one and the Broker has an IDGenerator.
+// The payload must match the payload type registered for t, if any.
+func (b *Broker) newEvent(ctx context.Context, t EventType, payload interface{}, opts options) (*Event, error) {
+	if err := b.checkPayloadType(t, payload); err != nil {
+		return nil, err
 	}
 
 	e := &Event{
@@ -207,26 +406,182 @@ func (b *Broker) Send(ctx context.Context, t EventType, payload interface{}) (St
 		Formatted: make(map[string][]byte),
 		Payload:   payload,
 	}
+	for _, x := range b.extractors {
+		x.Extract(ctx, e)
+	}
 
-	return g.process(ctx, e)
+	if !opts.withCreatedAt.IsZero() {
+		e.CreatedAt = opts.withCreatedAt
+	}
+	if opts.withEventID != "" {
+		e.ID = opts.withEventID
+	}
+	for k, v := range opts.withMetadata {
+		e.SetMetadata(k, v)
+	}
+
+	if e.ID == "" && b.idGenerator != nil {
+		id, err := b.idGenerator.NewID()
+		if err != nil {
+			return nil, fmt.Errorf("unable to generate event ID: %w", err)
+		}
+		e.ID = id
+	}
+	return e, nil
 }
 
-// Reopen calls every registered Node's Reopen() function.  The intention is to
-// ask all nodes to reopen any files they have open.  This is typically used as
-// part of log rotation: after rotating, the rotator sends a signal to the
-// application, which then would invoke this method.  Another typically use-case
-// is to have all Nodes reevaluated any external configuration they might have.
-func (b *Broker) Reopen(ctx context.Context) error {
-	b.lock.RLock()
-	defer b.lock.RUnlock()
+// nextSequence returns the next sequence number for events of type t.
+func (b *Broker) nextSequence(t EventType) uint64 {
+	seq, ok := b.sequences.Load(t)
+	if !ok {
+		seq, _ = b.sequences.LoadOrStore(t, new(atomic.Uint64))
+	}
+	return seq.(*atomic.Uint64).Add(1)
+}
+
+// send routes the Event to the graphs registered for its EventType and the
+// patterns which match it, using the Broker's routingTable rather than
+// locking.  The graphs process the Event concurrently, and each must satisfy
+// its own delivery policy.
+func (b *Broker) send(ctx context.Context, e *Event) (Status, error) {
+	if e.Type.IsPattern() {
+		return Status{}, fmt.Errorf("cannot send event with pattern EventType %s: %w", e.Type, ErrInvalidParameter)
+	}
+	graphs, ok := b.route(e.Type)
+	if !ok {
+		return Status{}, fmt.Errorf("no graph for EventType %s", e.Type)
+	}
+	e.Sequence = b.nextSequence(e.Type)
+
+	// Dead letters refer to a copy of the Event taken before any node could
+	// change it, as the Event is shared by every pipeline.
+	original := e
+	for _, g := range graphs {
+		if g.deadLetterType != "" {
+			original = e.clone()
+			break
+		}
+	}
+
+	// Every graph starts processing the Event before waiting for any of them,
+	// so that they process it concurrently without further goroutines.
+	states := make([]*processState, len(graphs))
+	for i, g := range graphs {
+		states[i] = g.begin(ctx, e, original)
+	}
+	if len(graphs) == 1 {
+		return b.finishGraph(ctx, graphs[0], states[0], e)
+	}
+
+	statuses := make([]Status, len(graphs))
+	errs := make([]error, len(graphs))
+	for i, g := range graphs {
+		statuses[i], errs[i] = b.finishGraph(ctx, g, states[i], e)
+	}
+	return mergeStatuses(statuses), errors.Join(errs...)
+}
+
+// finishGraph waits for a graph to process the Event (see graph.begin), and
+// then starts sending any dead letters.
+func (b *Broker) finishGraph(ctx context.Context, g *graph, state *processState, e *Event) (Status, error) {
+	deadLetterType := g.deadLetterType
+
+	status, err := g.wait(ctx, state)
+
+	// Dead letters are never dead-lettered themselves, to avoid loops.
+	if _, isDeadLetter := e.Payload.(*DeadLetter); deadLetterType != "" && !isDeadLetter {
+		b.sendDeadLetters(ctx, deadLetterType, status.deadLetters)
+	}
+	status.deadLetters = nil
+
+	return status, err
+}
+
+// Close gracefully shuts down the Broker.  It stops the Broker accepting
+// events, waits for any events which are being sent (including those queued
+// by SendAsync and dead letters) and for the nodes processing them to return,
+// and then closes
+// every registered node exactly once via a
+// NodeController, regardless of how many pipelines reference it.  Nodes
+// removed by a Transaction which couldn't close them are closed too.  Any errors
+// closing nodes are aggregated (as multierror.Error), and the nodes and
+// pipelines are removed from the Broker.
+//
+// Once Close has been called, Send and SendAsync return ErrBrokerClosed, as do
+// RegisterNode and RegisterPipeline (wrapped).  If
+// ctx is done before the in-flight events complete, an error is returned
+// without closing any nodes, and Close may be called again.
+func (b *Broker) Close(ctx context.Context) error {
+	b.lock.Lock()
+	b.closed = true
+	b.admission.close()
+	b.lock.Unlock()
 
+	if err := b.StopAsync(ctx); err != nil {
+		return fmt.Errorf("unable to close broker: %w", err)
+	}
+
+	select {
+	case <-b.admission.done():
+	case <-ctx.Done():
+		return fmt.Errorf("unable to close broker, events are still being sent: %w", ctx.Err())
+	}
+
+	b.deadLetters.close()
+	select {
+	case <-b.deadLetters.done():
+	case <-ctx.Done():
+		return fmt.Errorf("unable to close broker, dead letters are still being sent: %w", ctx.Err())
+	}
+
+	// Nodes may still be processing events after Send has returned (such as
+	// when its context is done), so wait for every graph to finish with them.
+	b.writeLock.Lock()
+	b.lock.Lock()
 	for _, g := range b.graphs {
-		if err := g.reopen(ctx); err != nil {
-			return err
+		if !g.admission.closed() {
+			b.retireGraphs(g)
 		}
 	}
+	draining := append([]*graph(nil), b.draining...)
+	b.lock.Unlock()
+	b.writeLock.Unlock()
+	if err := waitForDraining(ctx, draining); err != nil {
+		return fmt.Errorf("unable to close broker, events are still being processed: %w", err)
+	}
 
-	return nil
+	b.writeLock.Lock()
+	defer b.writeLock.Unlock()
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	ids := make([]NodeID, 0, len(b.nodes))
+	for id := range b.nodes {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	var errors *multierror.Error
+	for _, id := range ids {
+		nc := NewNodeController(b.nodes[id].node)
+		if err := nc.Close(ctx); err != nil {
+			errors = multierror.Append(errors, fmt.Errorf("unable to close node ID %q: %w", id, err))
+		}
+		delete(b.nodes, id)
+	}
+	for _, r := range b.retired {
+		nc := NewNodeController(r.node)
+		if err := nc.Close(ctx); err != nil {
+			errors = multierror.Append(errors, fmt.Errorf("unable to close node ID %q: %w", r.id, err))
+		}
+	}
+	b.retired = nil
+	b.draining = nil
+	b.graphs = make(map[EventType]*graph)
+	b.publishRoutes()
+
+	return errors.ErrorOrNil()
 }
 
 // NodeID is a string that uniquely identifies a Node.
@@ -235,7 +590,14 @@ type NodeID string
 // RegisterNode assigns a node ID to a node.  Node IDs should be unique. A Node
 // may be a filter, formatter or sink (see NodeType). Nodes can be shared across
 // multiple pipelines.
-// Accepted options: WithNodeRegistrationPolicy (default: AllowOverwrite).
+//
+// When the node has a timeout and it does not finish processing an event in
+// time, the event is abandoned by the node's pipeline and a warning wrapping
+// ErrTimeout is included in the Status.  The timeout applies to each attempt
+// when the node also has a RetryPolicy.
+//
+// Accepted options: WithNodeRegistrationPolicy (default: AllowOverwrite),
+// WithNodeTimeout, WithNodeRetryPolicy.
 func (b *Broker) RegisterNode(id NodeID, node Node, opt ...Option) error {
 	if id == "" {
 		return fmt.Errorf("unable to register node, node ID cannot be empty: %w", ErrInvalidParameter)
@@ -246,35 +608,26 @@ func (b *Broker) RegisterNode(id NodeID, node Node, opt ...Option) error {
 		return fmt.Errorf("cannot register node: %w", err)
 	}
 
+	b.writeLock.Lock()
+	defer b.writeLock.Unlock()
+
 	b.lock.Lock()
 	defer b.lock.Unlock()
 
-	nr := &nodeUsage{
-		node:               node,
-		referenceCount:     0,
-		registrationPolicy: opts.withNodeRegistrationPolicy,
+	if b.closed {
+		return fmt.Errorf("cannot register node: %w", ErrBrokerClosed)
 	}
 
-	// Check if this node is already registered, if so maintain reference count
-	r, exists := b.nodes[id]
-	if exists {
-		switch r.registrationPolicy {
-		case AllowOverwrite:
-			nr.referenceCount = r.referenceCount
-		case DenyOverwrite:
-			return fmt.Errorf("node ID %q is already registered, configured policy prevents overwriting", id)
-		}
-	}
-
-	b.nodes[id] = nr
-
-	return nil
+	return b.registerNode(id, node, opts)
 }
 
 // RemoveNode will remove a node from the broker, if it is not currently  in use
 // This is useful if RegisterNode was used successfully prior to a failed RegisterPipeline call
 // referencing those nodes
 func (b *Broker) RemoveNode(ctx context.Context, id NodeID) error {
+	b.writeLock.Lock()
+	defer b.writeLock.Unlock()
+
 	b.lock.Lock()
 	defer b.lock.Unlock()
 	return b.removeNode(ctx, id, false)
@@ -320,6 +673,21 @@ type PipelineID string
 
 // Pipeline defines a pipe: its ID, the EventType it's for, and the nodes
 // that it contains. Nodes can be shared across multiple pipelines.
+//
+// The nodes are either a linear list (NodeIDs), or a graph which fans out into
+// branches (Edges). For example, the following Pipeline runs an encrypt filter
+// once per event and then formats the result as JSON for a file sink and as a
+// cloudevent for a channel sink:
+//
+//	Pipeline{
+//		PipelineID: "audit",
+//		EventType:  "audit",
+//		Edges: map[NodeID][]NodeID{
+//			"encrypt":     {"json", "cloudevents"},
+//			"json":        {"file"},
+//			"cloudevents": {"channel"},
+//		},
+//	}
 type Pipeline struct {
 	// PipelineID uniquely identifies the Pipeline
 	PipelineID PipelineID
@@ -329,10 +697,41 @@ type Pipeline struct {
 
 	// NodeIDs defines Pipeline's the list of nodes
 	NodeIDs []NodeID
+
+	// Edges defines the Pipeline's nodes as a graph which may fan out into
+	// branches. Each key is the ID of a node and its value lists the IDs of the
+	// nodes which process the event it returns (in order). The root of the
+	// graph is the only node which is not a child of another node, every branch
+	// must end with a sink and the graph must not contain cycles. A node with
+	// more than one parent processes the event once per parent.
+	// Edges cannot be used in combination with NodeIDs.
+	Edges map[NodeID][]NodeID
 }
 
 // RegisterPipeline adds a pipeline to the broker.
-// Accepted options: WithPipelineRegistrationPolicy (default: AllowOverwrite).
+//
+// When the pipeline has a timeout and it does not finish processing an event in
+// time, the event is abandoned by the pipeline (other pipelines are unaffected)
+// and a warning wrapping ErrTimeout is included in the Status.
+//
+// The pipeline's EventType may be a pattern: AllEventTypes ("*") or a
+// hierarchical prefix such as "audit.*", which matches "audit.login" and
+// "audit.login.failed".  Events are sent to the pipelines registered for their
+// EventType and for every pattern which matches it, except for dead letters
+// (see SetDeadLetterEventType).  The pipelines registered
+// for each EventType or pattern have their own delivery policy (success
+// thresholds and required pipelines), which only counts those pipelines, and
+// Send returns an error if any of the delivery policies isn't satisfied.
+//
+// Nodes which implement Opener are opened when they're first referenced by a
+// pipeline, and the pipeline isn't registered if any of them fails to open.
+// Those which were opened stay open, and aren't opened again when they're next
+// referenced.  They're opened without blocking the methods which read the
+// registry, such as Health.
+//
+// Accepted options: WithPipelineRegistrationPolicy (default: AllowOverwrite),
+// WithPipelineTimeout, WithRequiredPipeline, WithRequiredSinks,
+// WithOpenContext.
 func (b *Broker) RegisterPipeline(def Pipeline, opt ...Option) error {
 	err := def.validate()
 	if err != nil {
@@ -344,65 +743,39 @@ func (b *Broker) RegisterPipeline(def Pipeline, opt ...Option) error {
 		return fmt.Errorf("cannot register pipeline: %w", err)
 	}
 
-	b.lock.Lock()
-	defer b.lock.Unlock()
-
-	g, exists := b.graphs[def.EventType]
-	if !exists {
-		g = &graph{}
-		b.graphs[def.EventType] = g
-	}
-
-	// Get the configured policy
-	pol := AllowOverwrite
-	g.roots.Range(func(key PipelineID, v *registeredPipeline) bool {
-		if key == def.PipelineID {
-			pol = v.registrationPolicy
-			return false
-		}
-		return true
-	})
-
-	if pol == DenyOverwrite {
-		return fmt.Errorf("pipeline ID %q is already registered, configured policy prevents overwriting", def.PipelineID)
-	}
+	b.writeLock.Lock()
+	defer b.writeLock.Unlock()
 
-	// Gather the registered nodes, so they can be referenced for this pipeline.
-	nodes := make([]Node, len(def.NodeIDs))
-	for i, n := range def.NodeIDs {
-		nodeUsage, ok := b.nodes[n]
-		if !ok {
-			return fmt.Errorf("node ID %q not registered", n)
-		}
-		nodes[i] = nodeUsage.node
+	b.lock.Lock()
+	if b.closed {
+		b.lock.Unlock()
+		return fmt.Errorf("cannot register pipeline: %w", ErrBrokerClosed)
 	}
 
-	root, err := linkNodes(nodes, def.NodeIDs)
+	p, err := b.linkPipeline(def, opts)
 	if err != nil {
+		b.lock.Unlock()
 		return err
 	}
+	unopened := b.unopenedNodes(p.nodes())
+	b.lock.Unlock()
 
-	err = g.doValidate(nil, root)
-	if err != nil {
-		return err
-	}
+	// The registry can't change while the nodes are opened, since writeLock
+	// is held.
+	opened, err := openNodes(opts.withOpenContext, unopened)
 
-	// Create the pipeline registration using the optional policy (or default).
-	pipelineReg := &registeredPipeline{
-		rootNode:           root,
-		registrationPolicy: opts.withPipelineRegistrationPolicy,
-	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
 
-	// Store the pipeline and then update the reference count of the nodes in that pipeline.
-	g.roots.Store(def.PipelineID, pipelineReg)
-	for _, id := range def.NodeIDs {
-		nodeUsage, ok := b.nodes[id]
-		// We can be optimistic about this as we would have already errored above.
-		if ok {
-			nodeUsage.referenceCount++
-		}
+	if err != nil {
+		return fmt.Errorf("cannot register pipeline: %w", errors.Join(err, b.keepOpened(opts.withOpenContext, opened)))
 	}
-
+	markOpened(opened)
+	// Graphs are replaced rather than changed, since events are routed to
+	// them without locking.
+	g := b.graph(def.EventType).clone()
+	b.storePipeline(g, def.PipelineID, p)
+	b.replaceGraphs(map[EventType]*graph{def.EventType: g})
 	return nil
 }
 
@@ -415,6 +788,9 @@ func (b *Broker) RemovePipeline(t EventType, id PipelineID) error {
 		return errors.New("pipeline ID cannot be empty")
 	}
 
+	b.writeLock.Lock()
+	defer b.writeLock.Unlock()
+
 	b.lock.Lock()
 	defer b.lock.Unlock()
 
@@ -423,7 +799,11 @@ func (b *Broker) RemovePipeline(t EventType, id PipelineID) error {
 		return fmt.Errorf("no graph for EventType %s", t)
 	}
 
+	// Graphs are replaced rather than changed, since events are routed to
+	// them without locking.
+	g = g.clone()
 	g.roots.Delete(id)
+	b.replaceGraphs(map[EventType]*graph{t: g})
 	return nil
 }
 
@@ -443,6 +823,9 @@ func (b *Broker) RemovePipelineAndNodes(ctx context.Context, t EventType, id Pip
 		return false, errors.New("pipeline ID cannot be empty")
 	}
 
+	b.writeLock.Lock()
+	defer b.writeLock.Unlock()
+
 	b.lock.Lock()
 	defer b.lock.Unlock()
 
@@ -456,7 +839,11 @@ func (b *Broker) RemovePipelineAndNodes(ctx context.Context, t EventType, id Pip
 		return false, fmt.Errorf("unable to retrieve all nodes referenced by pipeline ID %q: %w", id, err)
 	}
 
+	// Graphs are replaced rather than changed, since events are routed to
+	// them without locking.
+	g = g.clone()
 	g.roots.Delete(id)
+	b.replaceGraphs(map[EventType]*graph{t: g})
 
 	var nodeErr error
 
@@ -477,6 +864,9 @@ func (b *Broker) RemovePipelineAndNodes(ctx context.Context, t EventType, id Pip
 // the pipeline's sink, but it would still count as success when it comes to
 // meeting this threshold.  Use this when you want to allow the filtering of
 // events without causing an error because an event was filtered.
+//
+// The threshold of a pattern (see RegisterPipeline) only counts the pipelines
+// registered for the pattern.
 func (b *Broker) SetSuccessThreshold(t EventType, successThreshold int) error {
 	switch {
 	case t == "":
@@ -484,17 +874,21 @@ func (b *Broker) SetSuccessThreshold(t EventType, successThreshold int) error {
 	case successThreshold < 0:
 		return fmt.Errorf("successThreshold must be 0 or greater")
 	}
+	if err := validatePattern(t); err != nil {
+		return err
+	}
+
+	b.writeLock.Lock()
+	defer b.writeLock.Unlock()
 
 	b.lock.Lock()
 	defer b.lock.Unlock()
 
-	g, ok := b.graphs[t]
-	if !ok {
-		g = &graph{}
-		b.graphs[t] = g
-	}
-
+	// Graphs are replaced rather than changed, since events are routed to
+	// them without locking.
+	g := b.graph(t).clone()
 	g.successThreshold = successThreshold
+	b.replaceGraphs(map[EventType]*graph{t: g})
 	return nil
 }
 
@@ -508,17 +902,19 @@ func (b *Broker) SetSuccessThresholdSinks(t EventType, successThresholdSinks int
 	case successThresholdSinks < 0:
 		return fmt.Errorf("successThresholdSinks must be 0 or greater")
 	}
+	if err := validatePattern(t); err != nil {
+		return err
+	}
+
+	b.writeLock.Lock()
+	defer b.writeLock.Unlock()
 
 	b.lock.Lock()
 	defer b.lock.Unlock()
 
-	g, ok := b.graphs[t]
-	if !ok {
-		g = &graph{}
-		b.graphs[t] = g
-	}
-
+	g := b.graph(t).clone()
 	g.successThresholdSinks = successThresholdSinks
+	b.replaceGraphs(map[EventType]*graph{t: g})
 	return nil
 }
 
@@ -560,19 +956,24 @@ func (b *Broker) SuccessThresholdSinks(t EventType) (int, bool) {
 }
 
 // IsAnyPipelineRegistered returns whether a pipeline for a given event type is already registered or not.
+// Pipelines registered for a pattern which matches the event type (see
+// RegisterPipeline) are included, as events of the type are sent to them.
 func (b *Broker) IsAnyPipelineRegistered(e EventType) bool {
-	b.lock.RLock()
-	defer b.lock.RUnlock()
-	g, found := b.graphs[e]
-	if !found {
+	routes := b.routes.Load()
+	if routes == nil {
 		return false
 	}
 
-	found = false
-	g.roots.Range(func(_ PipelineID, pipeline *registeredPipeline) bool {
-		found = true
-		return false
-	})
+	found := false
+	for _, g := range routes.match(e) {
+		g.roots.Range(func(_ PipelineID, pipeline *registeredPipeline) bool {
+			found = true
+			return false
+		})
+		if found {
+			break
+		}
+	}
 	return found
 }
 
@@ -589,11 +990,18 @@ func (p Pipeline) validate() error {
 		err = multierror.Append(err, errors.New("event type is required"))
 	}
 
-	if len(p.NodeIDs) == 0 {
+	if patternErr := validatePattern(p.EventType); patternErr != nil {
+		err = multierror.Append(err, patternErr)
+	}
+
+	switch {
+	case len(p.NodeIDs) == 0 && len(p.Edges) == 0:
 		err = multierror.Append(err, errors.New("node IDs are required"))
+	case len(p.NodeIDs) > 0 && len(p.Edges) > 0:
+		err = multierror.Append(err, errors.New("node IDs and edges cannot both be specified"))
 	}
 
-	for _, n := range p.NodeIDs {
+	for _, n := range p.nodeIDs() {
 		if n == "" {
 			err = multierror.Append(err, errors.New("node ID cannot be empty"))
 			break
@@ -602,3 +1010,34 @@ func (p Pipeline) validate() error {
 
 	return err
 }
+
+// nodeIDs returns the unique IDs of the nodes referenced by the Pipeline, in
+// the order they are first referenced.
+func (p Pipeline) nodeIDs() []NodeID {
+	var ids []NodeID
+	seen := make(map[NodeID]struct{})
+	add := func(id NodeID) {
+		if _, ok := seen[id]; !ok {
+			seen[id] = struct{}{}
+			ids = append(ids, id)
+		}
+	}
+
+	for _, id := range p.NodeIDs {
+		add(id)
+	}
+
+	parents := make([]NodeID, 0, len(p.Edges))
+	for id := range p.Edges {
+		parents = append(parents, id)
+	}
+	sort.Slice(parents, func(i, j int) bool { return parents[i] < parents[j] })
+	for _, id := range parents {
+		add(id)
+		for _, child := range p.Edges[id] {
+			add(child)
+		}
+	}
+
+	return ids
+}
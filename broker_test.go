@@ -10,6 +10,7 @@ import (
 	"os"
 	"path/filepath"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -36,6 +37,27 @@ func nodesToNodeIDs(t *testing.T, broker *Broker, nodes ...Node) []NodeID {
 	return nodeIDs
 }
 
+// testPipeline is a pipeline for the EventType "t" of the "formatter"
+// registered by newTestBroker and a "sink".
+var testPipeline = Pipeline{PipelineID: "p", EventType: "t", NodeIDs: []NodeID{"formatter", "sink"}}
+
+// newTestBroker creates a Broker using the options, registers a JSONFormatter
+// as "formatter" along with the nodes, and then registers the pipelines.
+func newTestBroker[N Node](t *testing.T, nodes map[NodeID]N, pipelines []Pipeline, opt ...Option) *Broker {
+	t.Helper()
+
+	b, err := NewBroker(opt...)
+	require.NoError(t, err)
+	require.NoError(t, b.RegisterNode("formatter", &JSONFormatter{}))
+	for id, n := range nodes {
+		require.NoError(t, b.RegisterNode(id, n))
+	}
+	for _, p := range pipelines {
+		require.NoError(t, b.RegisterPipeline(p))
+	}
+	return b
+}
+
 func TestBroker(t *testing.T) {
 	// Filter out the purple nodes
 	filter := &Filter{
@@ -110,6 +132,12 @@ func TestBroker(t *testing.T) {
 					"width": 4,
 				},
 			}
+			// The broker is shared by the tests, so the sequence numbers
+			// continue from the previous test.
+			var seq uint64
+			if last, ok := broker.sequences.Load(et); ok {
+				seq = last.(*atomic.Uint64).Load()
+			}
 			for _, p := range payloads {
 				_, err = broker.Send(context.Background(), et, p)
 				if err != nil {
@@ -131,11 +159,12 @@ func TestBroker(t *testing.T) {
 				t.Fatal(err)
 			}
 
-			prefix := fmt.Sprintf(`{"created_at":"%s","event_type":"Foo","payload":`, now.Format(time.RFC3339Nano))
+			// The purple event was filtered, so its sequence number is missing.
+			prefix := fmt.Sprintf(`{"created_at":"%s","event_type":"Foo","sequence":%%d,"payload":`, now.Format(time.RFC3339Nano))
 			suffix := "}\n"
 			var expect string
-			for _, s := range []string{`{"color":"red","width":1}`, `{"color":"green","width":2}`, `{"color":"blue","width":4}`} {
-				expect += fmt.Sprintf("%s%s%s", prefix, s, suffix)
+			for i, s := range []string{`{"color":"red","width":1}`, `{"color":"green","width":2}`, `{"color":"blue","width":4}`} {
+				expect += fmt.Sprintf(prefix+"%s%s", seq+[]uint64{1, 2, 4}[i], s, suffix)
 			}
 			if diff := deep.Equal(string(dat), expect); diff != nil {
 				t.Fatal(diff)
@@ -845,7 +874,7 @@ func TestBroker_RegisterPipeline_AllowOverwrite_Implicit(t *testing.T) {
 	err = b.RegisterNode("f2", &JSONFormatter{})
 	require.NoError(t, err)
 
-	err = b.RegisterNode("s1", &FileSink{})
+	err = b.RegisterNode("s1", &FileSink{Path: t.TempDir()})
 	require.NoError(t, err)
 
 	err = b.RegisterPipeline(Pipeline{
@@ -877,7 +906,7 @@ func TestBroker_RegisterPipeline_AllowOverwrite_Explicit(t *testing.T) {
 	err = b.RegisterNode("f2", &JSONFormatter{})
 	require.NoError(t, err)
 
-	err = b.RegisterNode("s1", &FileSink{})
+	err = b.RegisterNode("s1", &FileSink{Path: t.TempDir()})
 	require.NoError(t, err)
 
 	err = b.RegisterPipeline(Pipeline{
@@ -916,7 +945,7 @@ func TestBroker_RegisterPipeline_DenyOverwrite(t *testing.T) {
 	err = b.RegisterNode("f2", &JSONFormatter{})
 	require.NoError(t, err)
 
-	err = b.RegisterNode("s1", &FileSink{})
+	err = b.RegisterNode("s1", &FileSink{Path: t.TempDir()})
 	require.NoError(t, err)
 
 	err = b.RegisterPipeline(Pipeline{
@@ -956,7 +985,7 @@ func TestBroker_RegisterPipeline_AllowThenDenyOverwrite(t *testing.T) {
 	err = b.RegisterNode("f2", &JSONFormatter{})
 	require.NoError(t, err)
 
-	err = b.RegisterNode("s1", &FileSink{})
+	err = b.RegisterNode("s1", &FileSink{Path: t.TempDir()})
 	require.NoError(t, err)
 
 	err = b.RegisterPipeline(Pipeline{
@@ -1048,7 +1077,7 @@ func TestBroker_IsAnyPipelineRegistered(t *testing.T) {
 	err = b.RegisterNode("f1", &JSONFormatter{})
 	require.NoError(t, err)
 
-	err = b.RegisterNode("s1", &FileSink{})
+	err = b.RegisterNode("s1", &FileSink{Path: t.TempDir()})
 	require.NoError(t, err)
 
 	err = b.RegisterPipeline(Pipeline{
@@ -1087,7 +1116,7 @@ func TestBroker_IsAnyPipelineRegisteredRaceCondition(t *testing.T) {
 	err = b.RegisterNode("f1", &JSONFormatter{})
 	require.NoError(t, err)
 
-	err = b.RegisterNode("s1", &FileSink{})
+	err = b.RegisterNode("s1", &FileSink{Path: t.TempDir()})
 	require.NoError(t, err)
 
 	n := 100
@@ -1274,3 +1303,229 @@ func TestStatus_getError(t *testing.T) {
 	}
 
 }
+
+// TestBroker_RegisterPipeline_Edges ensures that a pipeline which fans out runs
+// the shared upstream nodes once per event, delivers to every branch and counts
+// references to each node once.
+func TestBroker_RegisterPipeline_Edges(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	b, err := NewBroker()
+	require.NoError(t, err)
+
+	var filtered atomic.Int32
+	filter := &Filter{Predicate: func(e *Event) (bool, error) {
+		filtered.Add(1)
+		return true, nil
+	}}
+	s1, s2, s3 := &testActionNode{}, &testActionNode{}, &testActionNode{}
+	require.NoError(t, b.RegisterNode("filter", filter))
+	require.NoError(t, b.RegisterNode("json", &JSONFormatter{}))
+	require.NoError(t, b.RegisterNode("json-filter", &JSONFormatterFilter{}))
+	require.NoError(t, b.RegisterNode("s1", s1))
+	require.NoError(t, b.RegisterNode("s2", s2))
+	require.NoError(t, b.RegisterNode("s3", s3))
+
+	err = b.RegisterPipeline(Pipeline{
+		PipelineID: "fan-out",
+		EventType:  "t",
+		Edges: map[NodeID][]NodeID{
+			"filter":      {"json", "json-filter"},
+			"json":        {"s1", "s3"},
+			"json-filter": {"s2", "s3"},
+		},
+	})
+	require.NoError(t, err)
+	require.NoError(t, b.SetSuccessThresholdSinks("t", 4))
+
+	status, err := b.Send(ctx, "t", "payload")
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), filtered.Load())
+	assert.ElementsMatch(t, []NodeID{"s1", "s2", "s3", "s3"}, status.CompleteSinks())
+
+	for id := range b.nodes {
+		assert.Equal(t, 1, b.nodes[id].referenceCount, id)
+	}
+
+	ok, err := b.RemovePipelineAndNodes(ctx, "t", "fan-out")
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Empty(t, b.nodes)
+}
+
+// TestBroker_RegisterPipeline_EdgesInvalid ensures that fan-out pipelines are
+// validated when they are registered.
+func TestBroker_RegisterPipeline_EdgesInvalid(t *testing.T) {
+	t.Parallel()
+
+	b, err := NewBroker()
+	require.NoError(t, err)
+	require.NoError(t, b.RegisterNode("json", &JSONFormatter{}))
+	require.NoError(t, b.RegisterNode("sink", &testSink{}))
+
+	tests := map[string]struct {
+		pipeline Pipeline
+		wantErr  string
+	}{
+		"unregistered-node": {
+			pipeline: Pipeline{Edges: map[NodeID][]NodeID{"json": {"missing"}}},
+			wantErr:  `node ID "missing" not registered`,
+		},
+		"cycle": {
+			pipeline: Pipeline{Edges: map[NodeID][]NodeID{"json": {"json"}}},
+			wantErr:  "no root node, every node is a child of another node",
+		},
+		"root-sink": {
+			pipeline: Pipeline{Edges: map[NodeID][]NodeID{"sink": {"json"}}},
+			wantErr:  "non-sink node has no children",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			tc.pipeline.PipelineID = "p"
+			tc.pipeline.EventType = "t"
+			err := b.RegisterPipeline(tc.pipeline)
+			require.EqualError(t, err, tc.wantErr)
+			require.False(t, b.IsAnyPipelineRegistered("t"))
+			// No graph is left behind for the EventType.
+			_, ok := b.SuccessThreshold("t")
+			require.False(t, ok)
+		})
+	}
+
+	err = b.RegisterPipeline(Pipeline{
+		PipelineID: "p",
+		EventType:  "t",
+		NodeIDs:    []NodeID{"json", "sink"},
+		Edges:      map[NodeID][]NodeID{"json": {"sink"}},
+	})
+	require.Error(t, err)
+	me, ok := err.(*multierror.Error)
+	require.True(t, ok)
+	require.EqualError(t, me.Unwrap(), "node IDs and edges cannot both be specified")
+}
+
+// countingCloser is a sink which counts how many times it's closed.
+type countingCloser struct {
+	testSink
+	closeErr error
+	closed   atomic.Int32
+}
+
+func (c *countingCloser) Close(_ context.Context) error {
+	c.closed.Add(1)
+	return c.closeErr
+}
+
+func TestBroker_Close(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	b, err := NewBroker(WithAsyncQueue(5, 1))
+	require.NoError(t, err)
+
+	shared := &countingCloser{}
+	failing := &countingCloser{closeErr: errors.New("flush failed")}
+	require.NoError(t, b.RegisterNode("formatter", &JSONFormatter{}))
+	require.NoError(t, b.RegisterNode("shared", shared))
+	require.NoError(t, b.RegisterNode("failing", failing))
+	for _, p := range []Pipeline{
+		{PipelineID: "p1", EventType: "t1", NodeIDs: []NodeID{"formatter", "shared"}},
+		{PipelineID: "p2", EventType: "t2", NodeIDs: []NodeID{"formatter", "shared"}},
+		{PipelineID: "p3", EventType: "t2", NodeIDs: []NodeID{"formatter", "failing"}},
+	} {
+		require.NoError(t, b.RegisterPipeline(p))
+	}
+
+	f, err := b.SendAsync(ctx, "t1", "queued")
+	require.NoError(t, err)
+
+	err = b.Close(ctx)
+	require.EqualError(t, err, "1 error occurred:\n\t* unable to close node ID \"failing\": flush failed\n\n")
+	assert.Equal(t, int32(1), shared.closed.Load())
+	assert.Equal(t, int32(1), failing.closed.Load())
+
+	// Queued events are processed before the nodes are closed.
+	_, err = f.Wait(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, shared.count)
+
+	_, err = b.Send(ctx, "t1", "too late")
+	require.ErrorIs(t, err, ErrBrokerClosed)
+	_, err = b.SendAsync(ctx, "t1", "too late")
+	require.ErrorIs(t, err, ErrBrokerClosed)
+	err = b.RegisterNode("late", &testSink{})
+	require.ErrorIs(t, err, ErrBrokerClosed)
+	err = b.RegisterPipeline(Pipeline{PipelineID: "p4", EventType: "t1", NodeIDs: []NodeID{"formatter", "shared"}})
+	require.ErrorIs(t, err, ErrBrokerClosed)
+
+	// Closing again doesn't close the nodes again.
+	require.NoError(t, b.Close(ctx))
+	assert.Equal(t, int32(1), shared.closed.Load())
+}
+
+func TestBroker_Close_InFlight(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	started, release := make(chan struct{}, 1), make(chan struct{})
+	var processed atomic.Int32
+	sink := &mockCloser{Node: blockingSink(started, release, &processed)}
+	b := newTestBroker(t, map[NodeID]Node{"sink": sink}, []Pipeline{testPipeline})
+	require.NoError(t, b.SetSuccessThresholdSinks("t", 1))
+
+	sent := make(chan error)
+	go func() {
+		_, err := b.Send(ctx, "t", "in-flight")
+		sent <- err
+	}()
+	<-started
+
+	// The node isn't closed while an event is being sent.
+	closeCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	err := b.Close(closeCtx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.False(t, sink.closed)
+
+	close(release)
+	require.NoError(t, <-sent)
+	require.NoError(t, b.Close(ctx))
+	assert.True(t, sink.closed)
+	assert.Equal(t, int32(1), processed.Load())
+}
+
+func TestBroker_Close_CancelledSend(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	started, release := make(chan struct{}, 1), make(chan struct{})
+	var processed atomic.Int32
+	sink := &mockCloser{Node: blockingSink(started, release, &processed)}
+	b := newTestBroker(t, map[NodeID]Node{"sink": sink}, []Pipeline{testPipeline})
+
+	sendCtx, cancelSend := context.WithCancel(ctx)
+	sent := make(chan error)
+	go func() {
+		_, err := b.Send(sendCtx, "t", "in-flight")
+		sent <- err
+	}()
+	<-started
+	cancelSend()
+	<-sent
+
+	// The node isn't closed while it's processing an event, even though Send
+	// has returned.
+	closeCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	err := b.Close(closeCtx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.False(t, sink.closed)
+
+	close(release)
+	require.NoError(t, b.Close(ctx))
+	assert.True(t, sink.closed)
+	assert.Equal(t, int32(1), processed.Load())
+}
@@ -0,0 +1,167 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package eventlogger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// CircuitState is the state of a CircuitBreaker.
+type CircuitState string
+
+const (
+	// CircuitClosed means events are passed to the wrapped node.
+	CircuitClosed CircuitState = "Closed"
+	// CircuitOpen means the wrapped node has failed too many times in a row,
+	// and events are rejected with ErrCircuitOpen until the cool-down has
+	// passed.
+	CircuitOpen CircuitState = "Open"
+	// CircuitHalfOpen means the cool-down has passed and a single event is
+	// being passed to the wrapped node, to determine whether it has recovered.
+	CircuitHalfOpen CircuitState = "HalfOpen"
+)
+
+// CircuitBreaker is a Node which wraps another node (typically a sink) and
+// stops passing events to it once it has failed failureThreshold times in a
+// row.  While the circuit is open, events are rejected immediately with an
+// error wrapping ErrCircuitOpen.  Once the cool-down has passed, the next event
+// is passed to the wrapped node: if it succeeds the circuit is closed,
+// otherwise it's opened again.
+//
+// Errors caused by the caller's context being cancelled are not counted as
+// failures.  CircuitBreaker implements NodeUnwrapper, so a NodeController can
+// close the wrapped node.
+type CircuitBreaker struct {
+	node             Node
+	failureThreshold int
+	coolDown         time.Duration
+
+	l        sync.Mutex
+	state    CircuitState
+	failures int
+	openedAt time.Time
+
+	// now only exists to make testing simpler.
+	now func() time.Time
+}
+
+var (
+	_ Node          = &CircuitBreaker{}
+	_ NodeUnwrapper = &CircuitBreaker{}
+)
+
+// NewCircuitBreaker creates a CircuitBreaker which wraps the node.  The circuit
+// is opened after failureThreshold consecutive failures, and stays open for the
+// coolDown.
+func NewCircuitBreaker(node Node, failureThreshold int, coolDown time.Duration) (*CircuitBreaker, error) {
+	switch {
+	case node == nil:
+		return nil, fmt.Errorf("missing node: %w", ErrInvalidParameter)
+	case failureThreshold < 1:
+		return nil, fmt.Errorf("failure threshold must be at least 1: %w", ErrInvalidParameter)
+	case coolDown <= 0:
+		return nil, fmt.Errorf("cool-down must be greater than 0: %w", ErrInvalidParameter)
+	}
+
+	return &CircuitBreaker{
+		node:             node,
+		failureThreshold: failureThreshold,
+		coolDown:         coolDown,
+		state:            CircuitClosed,
+		now:              time.Now,
+	}, nil
+}
+
+// Process passes the Event to the wrapped node, unless the circuit is open.
+func (c *CircuitBreaker) Process(ctx context.Context, e *Event) (*Event, error) {
+	if err := c.allow(); err != nil {
+		return nil, err
+	}
+
+	// A panic from the wrapped node is recorded as a failure before it
+	// continues (the Broker may recover it, see WithPanicPolicy), so that a
+	// trial event always ends.
+	panicked := true
+	defer func() {
+		if panicked {
+			c.record(errors.New("node panicked"))
+		}
+	}()
+
+	e, err := c.node.Process(ctx, e)
+	panicked = false
+	c.record(err)
+	return e, err
+}
+
+// allow determines whether an event can be passed to the wrapped node.
+func (c *CircuitBreaker) allow() error {
+	c.l.Lock()
+	defer c.l.Unlock()
+
+	switch c.state {
+	case CircuitOpen:
+		retryAt := c.openedAt.Add(c.coolDown)
+		if c.now().Before(retryAt) {
+			return fmt.Errorf("%w: rejecting events until %s", ErrCircuitOpen, retryAt.Format(time.RFC3339Nano))
+		}
+		c.state = CircuitHalfOpen
+		return nil
+	case CircuitHalfOpen:
+		return fmt.Errorf("%w: waiting for a trial event to complete", ErrCircuitOpen)
+	default:
+		return nil
+	}
+}
+
+// record updates the state of the circuit with the result of passing an event
+// to the wrapped node.
+func (c *CircuitBreaker) record(err error) {
+	c.l.Lock()
+	defer c.l.Unlock()
+
+	switch {
+	case err == nil:
+		c.state, c.failures = CircuitClosed, 0
+	case errors.Is(err, context.Canceled):
+		// Not the node's fault, but a trial event must still end.
+		if c.state == CircuitHalfOpen {
+			c.state = CircuitOpen
+		}
+	case c.state == CircuitHalfOpen:
+		c.state, c.openedAt = CircuitOpen, c.now()
+	default:
+		c.failures++
+		if c.failures >= c.failureThreshold {
+			c.state, c.openedAt, c.failures = CircuitOpen, c.now(), 0
+		}
+	}
+}
+
+// State returns the current state of the circuit.  An open circuit whose
+// cool-down has passed is reported as CircuitOpen until the next event arrives.
+func (c *CircuitBreaker) State() CircuitState {
+	c.l.Lock()
+	defer c.l.Unlock()
+	return c.state
+}
+
+// Reopen calls Reopen on the wrapped node.
+func (c *CircuitBreaker) Reopen() error {
+	return c.node.Reopen()
+}
+
+// Type returns the type of the wrapped node.
+func (c *CircuitBreaker) Type() NodeType {
+	return c.node.Type()
+}
+
+// Unwrap returns the wrapped node.
+func (c *CircuitBreaker) Unwrap() Node {
+	return c.node
+}
@@ -0,0 +1,197 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package eventlogger
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	errSink := errors.New("sink failed")
+	var fail atomic.Bool
+	var calls atomic.Int32
+	sink := &testActionNode{
+		nodeType: NodeTypeSink,
+		action: func(ctx context.Context, e *Event) (*Event, error) {
+			calls.Add(1)
+			if fail.Load() {
+				return nil, errSink
+			}
+			return nil, nil
+		},
+	}
+
+	cb, err := NewCircuitBreaker(sink, 2, time.Minute)
+	require.NoError(t, err)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	cb.now = func() time.Time { return now }
+	assert.Equal(t, NodeTypeSink, cb.Type())
+	assert.Equal(t, CircuitClosed, cb.State())
+
+	// Failures which don't reach the threshold keep the circuit closed.
+	fail.Store(true)
+	_, err = cb.Process(ctx, &Event{})
+	require.ErrorIs(t, err, errSink)
+	fail.Store(false)
+	_, err = cb.Process(ctx, &Event{})
+	require.NoError(t, err)
+	fail.Store(true)
+	_, err = cb.Process(ctx, &Event{})
+	require.ErrorIs(t, err, errSink)
+	assert.Equal(t, CircuitClosed, cb.State())
+
+	// Reaching the threshold opens the circuit, and events are rejected
+	// without calling the sink.
+	_, err = cb.Process(ctx, &Event{})
+	require.ErrorIs(t, err, errSink)
+	assert.Equal(t, CircuitOpen, cb.State())
+	_, err = cb.Process(ctx, &Event{})
+	require.ErrorIs(t, err, ErrCircuitOpen)
+	assert.Equal(t, int32(4), calls.Load())
+
+	// After the cool-down, a failed trial opens the circuit again.
+	now = now.Add(time.Minute)
+	_, err = cb.Process(ctx, &Event{})
+	require.ErrorIs(t, err, errSink)
+	assert.Equal(t, CircuitOpen, cb.State())
+	_, err = cb.Process(ctx, &Event{})
+	require.ErrorIs(t, err, ErrCircuitOpen)
+	assert.Equal(t, int32(5), calls.Load())
+
+	// A successful trial closes the circuit.
+	now = now.Add(time.Minute)
+	fail.Store(false)
+	_, err = cb.Process(ctx, &Event{})
+	require.NoError(t, err)
+	assert.Equal(t, CircuitClosed, cb.State())
+	assert.Equal(t, int32(6), calls.Load())
+}
+
+func TestCircuitBreaker_HalfOpen(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	started, release := make(chan struct{}, 1), make(chan struct{})
+	var processed atomic.Int32
+	cb, err := NewCircuitBreaker(blockingSink(started, release, &processed), 1, time.Minute)
+	require.NoError(t, err)
+	cb.state, cb.openedAt = CircuitOpen, time.Now().Add(-time.Hour)
+
+	// Only the trial event is passed to the sink while the circuit is half-open.
+	trial := make(chan error)
+	go func() {
+		_, err := cb.Process(ctx, &Event{})
+		trial <- err
+	}()
+	<-started
+	assert.Equal(t, CircuitHalfOpen, cb.State())
+	_, err = cb.Process(ctx, &Event{})
+	require.ErrorIs(t, err, ErrCircuitOpen)
+
+	close(release)
+	require.NoError(t, <-trial)
+	assert.Equal(t, CircuitClosed, cb.State())
+	assert.Equal(t, int32(1), processed.Load())
+}
+
+func TestCircuitBreaker_HalfOpenPanic(t *testing.T) {
+	t.Parallel()
+
+	var panics atomic.Bool
+	panics.Store(true)
+	sink := &testActionNode{
+		action: func(context.Context, *Event) (*Event, error) {
+			if panics.Load() {
+				panic("sink panicked")
+			}
+			return nil, nil
+		},
+	}
+	cb, err := NewCircuitBreaker(sink, 1, time.Minute)
+	require.NoError(t, err)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	cb.now = func() time.Time { return now }
+	cb.state, cb.openedAt = CircuitOpen, now.Add(-time.Hour)
+
+	b, err := NewBroker()
+	require.NoError(t, err)
+	require.NoError(t, b.RegisterNode("formatter", &JSONFormatter{}))
+	require.NoError(t, b.RegisterNode("sink", cb))
+	require.NoError(t, b.RegisterPipeline(Pipeline{PipelineID: "p", EventType: "t", NodeIDs: []NodeID{"formatter", "sink"}}))
+
+	// The Broker recovers the panic of the trial event, which opens the
+	// circuit again rather than leaving it half-open.
+	s, err := b.Send(context.Background(), "t", "payload")
+	require.NoError(t, err)
+	require.Len(t, s.Warnings, 1)
+	var panicErr *PanicError
+	require.ErrorAs(t, s.Warnings[0], &panicErr)
+	assert.Equal(t, CircuitOpen, cb.State())
+
+	// Once the cool-down has passed, the next trial closes the circuit.
+	now = now.Add(time.Minute)
+	panics.Store(false)
+	s, err = b.Send(context.Background(), "t", "payload")
+	require.NoError(t, err)
+	assert.Empty(t, s.Warnings)
+	assert.Equal(t, CircuitClosed, cb.State())
+}
+
+func TestCircuitBreaker_Broker(t *testing.T) {
+	t.Parallel()
+
+	var attempts atomic.Int32
+	cb, err := NewCircuitBreaker(failingSink(100, errors.New("sink failed"), &attempts), 1, time.Hour)
+	require.NoError(t, err)
+
+	b, err := NewBroker()
+	require.NoError(t, err)
+	require.NoError(t, b.RegisterNode("formatter", &JSONFormatter{}))
+	require.NoError(t, b.RegisterNode("sink", cb, WithNodeRetryPolicy(RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond})))
+	require.NoError(t, b.RegisterPipeline(Pipeline{
+		PipelineID: "p",
+		EventType:  "t",
+		NodeIDs:    []NodeID{"formatter", "sink"},
+	}))
+
+	// The open circuit is reported in the Status, and isn't retried.
+	s, err := b.Send(context.Background(), "t", "payload")
+	require.NoError(t, err)
+	require.Len(t, s.Warnings, 1)
+	assert.ErrorIs(t, s.Warnings[0], ErrCircuitOpen)
+	assert.Equal(t, int32(1), attempts.Load())
+	assert.Equal(t, CircuitOpen, cb.State())
+}
+
+func TestCircuitBreaker_Close(t *testing.T) {
+	t.Parallel()
+
+	inner := &mockCloser{Node: &testSink{}}
+	cb, err := NewCircuitBreaker(inner, 1, time.Minute)
+	require.NoError(t, err)
+	assert.Same(t, inner, cb.Unwrap())
+	require.NoError(t, NewNodeController(cb).Close(context.Background()))
+	assert.True(t, inner.closed)
+}
+
+func TestNewCircuitBreaker(t *testing.T) {
+	t.Parallel()
+
+	_, err := NewCircuitBreaker(nil, 1, time.Minute)
+	require.EqualError(t, err, "missing node: invalid parameter")
+	_, err = NewCircuitBreaker(&testSink{}, 0, time.Minute)
+	require.EqualError(t, err, "failure threshold must be at least 1: invalid parameter")
+	_, err = NewCircuitBreaker(&testSink{}, 1, 0)
+	require.EqualError(t, err, "cool-down must be greater than 0: invalid parameter")
+}
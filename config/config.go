@@ -0,0 +1,440 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package config
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/eventlogger"
+	"github.com/hashicorp/go-multierror"
+)
+
+// Format of a configuration document.
+type Format string
+
+const (
+	FormatJSON Format = "json" // FormatJSON is a JSON document
+	FormatHCL  Format = "hcl"  // FormatHCL is an HCL document
+)
+
+// Config declares the nodes, pipelines and event types of a Broker.
+type Config struct {
+	// Nodes to register with the Broker
+	Nodes []NodeConfig `json:"nodes" hcl:"node"`
+
+	// Pipelines to register with the Broker
+	Pipelines []PipelineConfig `json:"pipelines" hcl:"pipeline"`
+
+	// EventTypes configures the success thresholds of event types
+	EventTypes []EventTypeConfig `json:"event_types" hcl:"event_type"`
+
+	// UnusedKeys is populated by Parse when decoding HCL, with any keys which
+	// aren't recognized so that they can be reported by Validate.
+	UnusedKeys []string `json:"-" hcl:"-"`
+}
+
+// NodeConfig declares a node.
+type NodeConfig struct {
+	// ID of the node
+	ID string `json:"id" hcl:",key"`
+
+	// Type name of the node, for example "file" or "cloudevents"
+	Type string `json:"type" hcl:"type"`
+
+	// RegistrationPolicy of the node, either "AllowOverwrite" (the default) or
+	// "DenyOverwrite"
+	RegistrationPolicy string `json:"registration_policy,omitempty" hcl:"registration_policy"`
+
+	// Settings specific to the Type of node
+	Settings map[string]interface{} `json:"settings,omitempty" hcl:"settings"`
+
+	// UnusedKeys is populated by Parse when decoding HCL.
+	UnusedKeys []string `json:"-" hcl:"-"`
+}
+
+// PipelineConfig declares a pipeline, using either NodeIDs or Edges (see
+// eventlogger.Pipeline).
+type PipelineConfig struct {
+	// ID of the pipeline
+	ID string `json:"id" hcl:",key"`
+
+	// EventType the pipeline processes, which may be a pattern such as "*" or
+	// "audit.*" (see Broker.RegisterPipeline)
+	EventType string `json:"event_type" hcl:"event_type"`
+
+	// NodeIDs of a linear pipeline, in order
+	NodeIDs []string `json:"node_ids,omitempty" hcl:"node_ids"`
+
+	// Edges of a pipeline which fans out into branches, keyed by parent
+	Edges map[string][]string `json:"edges,omitempty" hcl:"edges"`
+
+	// RegistrationPolicy of the pipeline, either "AllowOverwrite" (the
+	// default) or "DenyOverwrite"
+	RegistrationPolicy string `json:"registration_policy,omitempty" hcl:"registration_policy"`
+
+	// Required is set when the pipeline must succeed (see
+	// eventlogger.WithRequiredPipeline)
+	Required bool `json:"required,omitempty" hcl:"required"`
+
+	// RequiredSinks are the IDs of the pipeline's sinks which must succeed
+	// (see eventlogger.WithRequiredSinks)
+	RequiredSinks []string `json:"required_sinks,omitempty" hcl:"required_sinks"`
+
+	// UnusedKeys is populated by Parse when decoding HCL.
+	UnusedKeys []string `json:"-" hcl:"-"`
+}
+
+// EventTypeConfig declares the success thresholds of an event type (see
+// Broker.SetSuccessThreshold and Broker.SetSuccessThresholdSinks).
+type EventTypeConfig struct {
+	// EventType being configured, which may be a pattern
+	EventType string `json:"event_type" hcl:",key"`
+
+	// SuccessThreshold of the event type
+	SuccessThreshold int `json:"success_threshold,omitempty" hcl:"success_threshold"`
+
+	// SuccessThresholdSinks of the event type
+	SuccessThresholdSinks int `json:"success_threshold_sinks,omitempty" hcl:"success_threshold_sinks"`
+
+	// UnusedKeys is populated by Parse when decoding HCL.
+	UnusedKeys []string `json:"-" hcl:"-"`
+}
+
+// Error describes a problem with part of a configuration document.
+type Error struct {
+	// Path to the offending part of the document, for example
+	// nodes[1].settings.max_bytes
+	Path string
+
+	// Err describing the problem
+	Err error
+}
+
+// Error returns the path and description of the problem.
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Path, e.Err)
+}
+
+// Unwrap returns the description of the problem.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// pathError creates an Error at path, using a formatted description.
+func pathError(path string, format string, a ...interface{}) *Error {
+	return &Error{Path: path, Err: fmt.Errorf(format, a...)}
+}
+
+// Parse decodes a configuration document of the given format.  The
+// configuration is not validated.
+func Parse(data []byte, format Format) (*Config, error) {
+	c := &Config{}
+	switch format {
+	case FormatJSON:
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(c); err != nil {
+			return nil, fmt.Errorf("unable to parse JSON config: %w", err)
+		}
+	case FormatHCL:
+		if err := parseHCL(data, c); err != nil {
+			return nil, fmt.Errorf("unable to parse HCL config: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("%q is not a valid config format: %w", format, eventlogger.ErrInvalidParameter)
+	}
+	return c, nil
+}
+
+// LoadFile reads and decodes the configuration document at path.  The format
+// is determined by the file's extension, which must be ".json" or ".hcl".  The
+// configuration is not validated.
+func LoadFile(path string) (*Config, error) {
+	var format Format
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".json":
+		format = FormatJSON
+	case ".hcl":
+		format = FormatHCL
+	default:
+		return nil, fmt.Errorf("unable to determine config format from file extension %q: %w", ext, eventlogger.ErrInvalidParameter)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read config: %w", err)
+	}
+
+	c, err := Parse(data, format)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return c, nil
+}
+
+// Validate checks the configuration for problems which can be found without
+// creating any nodes, such as missing or duplicate IDs, unknown node types and
+// pipelines referencing nodes which aren't declared.  Every problem is
+// reported as an *Error (aggregated as multierror.Error).
+func (c *Config) Validate() error {
+	var errs *multierror.Error
+	for _, k := range c.UnusedKeys {
+		errs = multierror.Append(errs, pathError(k, "unknown key"))
+	}
+
+	nodes := make(map[string]int, len(c.Nodes))
+	for i, n := range c.Nodes {
+		path := fmt.Sprintf("nodes[%d]", i)
+		for _, k := range n.UnusedKeys {
+			errs = multierror.Append(errs, pathError(path+"."+k, "unknown key"))
+		}
+		switch prev, ok := nodes[n.ID]; {
+		case n.ID == "":
+			errs = multierror.Append(errs, pathError(path+".id", "node ID is required"))
+		case ok:
+			errs = multierror.Append(errs, pathError(path+".id", "node ID %q is already declared at nodes[%d]", n.ID, prev))
+		default:
+			nodes[n.ID] = i
+		}
+		switch _, ok := eventlogger.LookupNodeFactory(n.Type); {
+		case n.Type == "":
+			errs = multierror.Append(errs, pathError(path+".type", "node type is required"))
+		case !ok:
+			errs = multierror.Append(errs, pathError(path+".type", "unknown node type %q", n.Type))
+		}
+		if err := validatePolicy(n.RegistrationPolicy); err != nil {
+			errs = multierror.Append(errs, &Error{Path: path + ".registration_policy", Err: err})
+		}
+	}
+
+	type pipelineKey struct{ eventType, id string }
+	pipelines := make(map[pipelineKey]int, len(c.Pipelines))
+	for i, p := range c.Pipelines {
+		path := fmt.Sprintf("pipelines[%d]", i)
+		for _, k := range p.UnusedKeys {
+			errs = multierror.Append(errs, pathError(path+"."+k, "unknown key"))
+		}
+		key := pipelineKey{p.EventType, p.ID}
+		switch prev, ok := pipelines[key]; {
+		case p.ID == "":
+			errs = multierror.Append(errs, pathError(path+".id", "pipeline ID is required"))
+		case ok:
+			errs = multierror.Append(errs, pathError(path+".id", "pipeline ID %q is already declared for event type %q at pipelines[%d]", p.ID, p.EventType, prev))
+		default:
+			pipelines[key] = i
+		}
+		if p.EventType == "" {
+			errs = multierror.Append(errs, pathError(path+".event_type", "event type is required"))
+		}
+		if err := validatePolicy(p.RegistrationPolicy); err != nil {
+			errs = multierror.Append(errs, &Error{Path: path + ".registration_policy", Err: err})
+		}
+
+		switch {
+		case len(p.NodeIDs) == 0 && len(p.Edges) == 0:
+			errs = multierror.Append(errs, pathError(path, "node_ids or edges are required"))
+		case len(p.NodeIDs) > 0 && len(p.Edges) > 0:
+			errs = multierror.Append(errs, pathError(path, "node_ids and edges cannot both be specified"))
+		}
+		for j, id := range p.NodeIDs {
+			if _, ok := nodes[id]; !ok {
+				errs = multierror.Append(errs, pathError(fmt.Sprintf("%s.node_ids[%d]", path, j), "node ID %q is not declared", id))
+			}
+		}
+		for _, parent := range sortedKeys(p.Edges) {
+			if _, ok := nodes[parent]; !ok {
+				errs = multierror.Append(errs, pathError(fmt.Sprintf("%s.edges.%s", path, parent), "node ID %q is not declared", parent))
+			}
+			for j, child := range p.Edges[parent] {
+				if _, ok := nodes[child]; !ok {
+					errs = multierror.Append(errs, pathError(fmt.Sprintf("%s.edges.%s[%d]", path, parent, j), "node ID %q is not declared", child))
+				}
+			}
+		}
+		for j, id := range p.RequiredSinks {
+			if !p.references(id) {
+				errs = multierror.Append(errs, pathError(fmt.Sprintf("%s.required_sinks[%d]", path, j), "node ID %q is not part of the pipeline", id))
+			}
+		}
+	}
+
+	eventTypes := make(map[string]int, len(c.EventTypes))
+	for i, t := range c.EventTypes {
+		path := fmt.Sprintf("event_types[%d]", i)
+		for _, k := range t.UnusedKeys {
+			errs = multierror.Append(errs, pathError(path+"."+k, "unknown key"))
+		}
+		switch prev, ok := eventTypes[t.EventType]; {
+		case t.EventType == "":
+			errs = multierror.Append(errs, pathError(path+".event_type", "event type is required"))
+		case ok:
+			errs = multierror.Append(errs, pathError(path+".event_type", "event type %q is already declared at event_types[%d]", t.EventType, prev))
+		default:
+			eventTypes[t.EventType] = i
+		}
+		if t.SuccessThreshold < 0 {
+			errs = multierror.Append(errs, pathError(path+".success_threshold", "must be 0 or greater"))
+		}
+		if t.SuccessThresholdSinks < 0 {
+			errs = multierror.Append(errs, pathError(path+".success_threshold_sinks", "must be 0 or greater"))
+		}
+	}
+
+	return errs.ErrorOrNil()
+}
+
+// references returns whether the node ID is part of the pipeline.
+func (p PipelineConfig) references(id string) bool {
+	for _, n := range p.NodeIDs {
+		if n == id {
+			return true
+		}
+	}
+	for parent, children := range p.Edges {
+		if parent == id {
+			return true
+		}
+		for _, child := range children {
+			if child == id {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// validatePolicy ensures the registration policy is valid, when given.
+func validatePolicy(p string) error {
+	switch eventlogger.RegistrationPolicy(p) {
+	case "", eventlogger.AllowOverwrite, eventlogger.DenyOverwrite:
+		return nil
+	default:
+		return fmt.Errorf("%q is not a valid registration policy", p)
+	}
+}
+
+// NewBroker creates a Broker using the supplied options, and then registers
+// the configuration's nodes, pipelines and event types with it.  The
+// configuration is validated first, and all the problems found (including
+// those found when creating nodes and registering pipelines) are reported as
+// *Error values (aggregated as multierror.Error).  If the Broker can't be
+// configured, it's closed using ctx.
+func NewBroker(ctx context.Context, c *Config, opt ...eventlogger.Option) (*eventlogger.Broker, error) {
+	const op = "config.NewBroker"
+	if c == nil {
+		return nil, fmt.Errorf("%s: missing config: %w", op, eventlogger.ErrInvalidParameter)
+	}
+	if err := c.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: invalid config: %w", op, err)
+	}
+
+	b, err := eventlogger.NewBroker(opt...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var errs *multierror.Error
+	for i, n := range c.Nodes {
+		path := fmt.Sprintf("nodes[%d]", i)
+		node, err := eventlogger.NewNodeFromSettings(b, n.Type, n.Settings)
+		if err != nil {
+			var settingErr *eventlogger.NodeSettingError
+			if errors.As(err, &settingErr) {
+				err = &Error{Path: path + ".settings." + settingErr.Setting, Err: settingErr.Err}
+			} else {
+				err = &Error{Path: path + ".settings", Err: err}
+			}
+			errs = multierror.Append(errs, err)
+			continue
+		}
+		var opts []eventlogger.Option
+		if n.RegistrationPolicy != "" {
+			opts = append(opts, eventlogger.WithNodeRegistrationPolicy(eventlogger.RegistrationPolicy(n.RegistrationPolicy)))
+		}
+		if err := b.RegisterNode(eventlogger.NodeID(n.ID), node, opts...); err != nil {
+			errs = multierror.Append(errs, &Error{Path: path, Err: err})
+		}
+	}
+	if errs != nil {
+		// The pipelines can't be registered without all of their nodes.
+		return nil, closeBroker(ctx, b, fmt.Errorf("%s: invalid config: %w", op, errs))
+	}
+
+	for i, p := range c.Pipelines {
+		def := eventlogger.Pipeline{
+			EventType:  eventlogger.EventType(p.EventType),
+			PipelineID: eventlogger.PipelineID(p.ID),
+		}
+		for _, id := range p.NodeIDs {
+			def.NodeIDs = append(def.NodeIDs, eventlogger.NodeID(id))
+		}
+		if len(p.Edges) > 0 {
+			def.Edges = make(map[eventlogger.NodeID][]eventlogger.NodeID, len(p.Edges))
+			for parent, children := range p.Edges {
+				for _, child := range children {
+					def.Edges[eventlogger.NodeID(parent)] = append(def.Edges[eventlogger.NodeID(parent)], eventlogger.NodeID(child))
+				}
+			}
+		}
+		var opts []eventlogger.Option
+		if p.RegistrationPolicy != "" {
+			opts = append(opts, eventlogger.WithPipelineRegistrationPolicy(eventlogger.RegistrationPolicy(p.RegistrationPolicy)))
+		}
+		if p.Required {
+			opts = append(opts, eventlogger.WithRequiredPipeline())
+		}
+		if len(p.RequiredSinks) > 0 {
+			sinks := make([]eventlogger.NodeID, len(p.RequiredSinks))
+			for j, id := range p.RequiredSinks {
+				sinks[j] = eventlogger.NodeID(id)
+			}
+			opts = append(opts, eventlogger.WithRequiredSinks(sinks...))
+		}
+		if err := b.RegisterPipeline(def, opts...); err != nil {
+			errs = multierror.Append(errs, &Error{Path: fmt.Sprintf("pipelines[%d]", i), Err: err})
+		}
+	}
+
+	for i, t := range c.EventTypes {
+		path := fmt.Sprintf("event_types[%d]", i)
+		if err := b.SetSuccessThreshold(eventlogger.EventType(t.EventType), t.SuccessThreshold); err != nil {
+			errs = multierror.Append(errs, &Error{Path: path + ".success_threshold", Err: err})
+		}
+		if err := b.SetSuccessThresholdSinks(eventlogger.EventType(t.EventType), t.SuccessThresholdSinks); err != nil {
+			errs = multierror.Append(errs, &Error{Path: path + ".success_threshold_sinks", Err: err})
+		}
+	}
+
+	if errs != nil {
+		return nil, closeBroker(ctx, b, fmt.Errorf("%s: invalid config: %w", op, errs))
+	}
+	return b, nil
+}
+
+// closeBroker closes a Broker which couldn't be configured, returning err along
+// with any error closing it.
+func closeBroker(ctx context.Context, b *eventlogger.Broker, err error) error {
+	if closeErr := b.Close(ctx); closeErr != nil {
+		return errors.Join(err, fmt.Errorf("unable to close broker: %w", closeErr))
+	}
+	return err
+}
+
+// sortedKeys returns the keys of the map, sorted.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,381 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package config
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/eventlogger"
+	"github.com/hashicorp/go-multierror"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testHCL = `
+node "cloudevents" {
+  type = "cloudevents"
+  settings {
+    source = "https://example.com/my-service"
+    format = "cloudevents-json"
+  }
+}
+
+node "audit-file" {
+  type                = "file"
+  registration_policy = "DenyOverwrite"
+  settings {
+    path         = "%s"
+    file_name    = "audit.log"
+    format       = "cloudevents-json"
+    max_bytes    = 1024
+    max_duration = "24h"
+  }
+}
+
+pipeline "audit" {
+  event_type     = "audit"
+  node_ids       = ["cloudevents", "audit-file"]
+  required_sinks = ["audit-file"]
+}
+
+event_type "audit" {
+  success_threshold_sinks = 1
+}
+`
+
+const testJSON = `{
+  "nodes": [
+    {"id": "json", "type": "json"},
+    {"id": "gated", "type": "gated", "settings": {"expiration": "10m"}},
+    {"id": "stdout", "type": "writer", "settings": {"output": "stdout"}}
+  ],
+  "pipelines": [
+    {
+      "id": "fan-out",
+      "event_type": "audit",
+      "edges": {"gated": ["json"], "json": ["stdout"]},
+      "required": true
+    }
+  ],
+  "event_types": [
+    {"event_type": "audit", "success_threshold": 1}
+  ]
+}`
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	t.Run("hcl", func(t *testing.T) {
+		c, err := Parse([]byte(testHCL), FormatHCL)
+		require.NoError(t, err)
+		require.NoError(t, c.Validate())
+		require.Len(t, c.Nodes, 2)
+		assert.Equal(t, NodeConfig{
+			ID:   "cloudevents",
+			Type: "cloudevents",
+			Settings: map[string]interface{}{
+				"source": "https://example.com/my-service",
+				"format": "cloudevents-json",
+			},
+		}, c.Nodes[0])
+		assert.Equal(t, "DenyOverwrite", c.Nodes[1].RegistrationPolicy)
+		assert.Equal(t, []PipelineConfig{{
+			ID:            "audit",
+			EventType:     "audit",
+			NodeIDs:       []string{"cloudevents", "audit-file"},
+			RequiredSinks: []string{"audit-file"},
+		}}, c.Pipelines)
+		assert.Equal(t, []EventTypeConfig{{EventType: "audit", SuccessThresholdSinks: 1}}, c.EventTypes)
+	})
+
+	t.Run("json", func(t *testing.T) {
+		c, err := Parse([]byte(testJSON), FormatJSON)
+		require.NoError(t, err)
+		require.NoError(t, c.Validate())
+		require.Len(t, c.Nodes, 3)
+		assert.Equal(t, map[string][]string{"gated": {"json"}, "json": {"stdout"}}, c.Pipelines[0].Edges)
+		assert.True(t, c.Pipelines[0].Required)
+		assert.Equal(t, []EventTypeConfig{{EventType: "audit", SuccessThreshold: 1}}, c.EventTypes)
+	})
+
+	t.Run("unknown-json-field", func(t *testing.T) {
+		_, err := Parse([]byte(`{"nodes": [{"id": "json", "type": "json", "bogus": 1}]}`), FormatJSON)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `unknown field "bogus"`)
+	})
+
+	t.Run("invalid-format", func(t *testing.T) {
+		_, err := Parse([]byte(testJSON), "yaml")
+		require.ErrorIs(t, err, eventlogger.ErrInvalidParameter)
+	})
+}
+
+func TestLoadFile(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+
+	jsonPath := filepath.Join(dir, "broker.json")
+	require.NoError(t, os.WriteFile(jsonPath, []byte(testJSON), 0o600))
+	c, err := LoadFile(jsonPath)
+	require.NoError(t, err)
+	assert.Len(t, c.Nodes, 3)
+
+	hclPath := filepath.Join(dir, "broker.HCL")
+	require.NoError(t, os.WriteFile(hclPath, []byte(testHCL), 0o600))
+	c, err = LoadFile(hclPath)
+	require.NoError(t, err)
+	assert.Len(t, c.Nodes, 2)
+
+	_, err = LoadFile(filepath.Join(dir, "broker.yaml"))
+	require.ErrorIs(t, err, eventlogger.ErrInvalidParameter)
+
+	_, err = LoadFile(filepath.Join(dir, "missing.json"))
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+// errorPaths returns the paths of the *Error values aggregated by err.
+func errorPaths(t *testing.T, err error) []string {
+	t.Helper()
+	var merr *multierror.Error
+	require.ErrorAs(t, err, &merr)
+	var paths []string
+	for _, e := range merr.Errors {
+		var cerr *Error
+		require.ErrorAs(t, e, &cerr)
+		paths = append(paths, cerr.Path)
+	}
+	return paths
+}
+
+func TestConfig_Validate(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		doc       string
+		format    Format
+		wantPaths []string
+	}{
+		"unknown-hcl-keys": {
+			doc: `
+bogus = 1
+node "json" {
+  type  = "json"
+  color = "red"
+}
+pipeline "p" {
+  event_type = "audit"
+  node_ids   = ["json"]
+  nodes      = ["json"]
+}
+event_type "audit" {
+  threshold = 1
+}`,
+			format:    FormatHCL,
+			wantPaths: []string{"bogus", "nodes[0].color", "pipelines[0].nodes", "event_types[0].threshold"},
+		},
+		// An unlabelled block is decoded in the order it was written, rather
+		// than after the labelled ones, and its items are its nodes.
+		"hcl-block-order": {
+			doc: `
+node {
+  csv {
+    type = "json"
+  }
+}
+node "json" {
+  type  = "json"
+  color = "red"
+}`,
+			format:    FormatHCL,
+			wantPaths: []string{"nodes[1].color"},
+		},
+		"nodes": {
+			doc: `{"nodes": [
+  {"type": "json"},
+  {"id": "a", "type": "json"},
+  {"id": "a", "type": "json"},
+  {"id": "b"},
+  {"id": "c", "type": "kafka", "registration_policy": "Sometimes"}
+]}`,
+			format: FormatJSON,
+			wantPaths: []string{
+				"nodes[0].id",
+				"nodes[2].id",
+				"nodes[3].type",
+				"nodes[4].type",
+				"nodes[4].registration_policy",
+			},
+		},
+		"pipelines": {
+			doc: `{
+  "nodes": [{"id": "a", "type": "json"}],
+  "pipelines": [
+    {"event_type": "audit", "node_ids": ["a"]},
+    {"id": "p", "node_ids": ["a"]},
+    {"id": "q", "event_type": "audit"},
+    {"id": "r", "event_type": "audit", "node_ids": ["a"], "edges": {"a": ["b"]}},
+    {"id": "r", "event_type": "audit", "node_ids": ["a", "z"]},
+    {"id": "s", "event_type": "audit", "edges": {"x": ["a", "y"]}, "registration_policy": "Never"},
+    {"id": "t", "event_type": "audit", "node_ids": ["a"], "required_sinks": ["a", "b"]}
+  ]
+}`,
+			format: FormatJSON,
+			wantPaths: []string{
+				"pipelines[0].id",
+				"pipelines[1].event_type",
+				"pipelines[2]",
+				"pipelines[3]",
+				"pipelines[3].edges.a[0]",
+				"pipelines[4].id",
+				"pipelines[4].node_ids[1]",
+				"pipelines[5].registration_policy",
+				"pipelines[5].edges.x",
+				"pipelines[5].edges.x[1]",
+				"pipelines[6].required_sinks[1]",
+			},
+		},
+		"event-types": {
+			doc: `{"event_types": [
+  {"success_threshold": 1},
+  {"event_type": "audit", "success_threshold": -1},
+  {"event_type": "audit", "success_threshold_sinks": -1}
+]}`,
+			format: FormatJSON,
+			wantPaths: []string{
+				"event_types[0].event_type",
+				"event_types[1].success_threshold",
+				"event_types[2].event_type",
+				"event_types[2].success_threshold_sinks",
+			},
+		},
+	}
+	for name, tc := range tests {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			c, err := Parse([]byte(tc.doc), tc.format)
+			require.NoError(t, err)
+			err = c.Validate()
+			require.Error(t, err)
+			assert.Equal(t, tc.wantPaths, errorPaths(t, err))
+		})
+	}
+}
+
+func TestNewBroker(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	t.Run("hcl", func(t *testing.T) {
+		dir := t.TempDir()
+		c, err := Parse([]byte(fmtHCL(dir)), FormatHCL)
+		require.NoError(t, err)
+
+		b, err := NewBroker(ctx, c)
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = b.Close(ctx) })
+
+		threshold, ok := b.SuccessThresholdSinks("audit")
+		require.True(t, ok)
+		assert.Equal(t, 1, threshold)
+		policy, ok := b.DeliveryPolicy("audit")
+		require.True(t, ok)
+		assert.Equal(t, map[eventlogger.PipelineID][]eventlogger.NodeID{"audit": {"audit-file"}}, policy.RequiredSinks)
+
+		status, err := b.Send(ctx, "audit", map[string]interface{}{"id": "1"})
+		require.NoError(t, err)
+		assert.Len(t, status.CompleteSinks(), 1)
+
+		// The file name is timestamped, since the file sink rotates.
+		files, err := filepath.Glob(filepath.Join(dir, "audit-*.log"))
+		require.NoError(t, err)
+		require.Len(t, files, 1)
+		data, err := os.ReadFile(files[0])
+		require.NoError(t, err)
+		assert.Contains(t, string(data), `"source":"https://example.com/my-service"`)
+	})
+
+	t.Run("json", func(t *testing.T) {
+		c, err := Parse([]byte(testJSON), FormatJSON)
+		require.NoError(t, err)
+
+		b, err := NewBroker(ctx, c)
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = b.Close(ctx) })
+
+		threshold, ok := b.SuccessThreshold("audit")
+		require.True(t, ok)
+		assert.Equal(t, 1, threshold)
+		assert.True(t, b.IsAnyPipelineRegistered("audit"))
+		policy, ok := b.DeliveryPolicy("audit")
+		require.True(t, ok)
+		assert.Equal(t, []eventlogger.PipelineID{"fan-out"}, policy.RequiredPipelines)
+	})
+
+	t.Run("custom-node-type", func(t *testing.T) {
+		var got string
+		require.NoError(t, eventlogger.RegisterNodeFactory("config-test-sink", eventlogger.NodeFactory{
+			Settings: []eventlogger.NodeSetting{{Name: "label", Type: eventlogger.NodeSettingString}},
+			New: func(_ *eventlogger.Broker, s eventlogger.NodeSettings) (eventlogger.Node, error) {
+				got = s.String("label")
+				return &eventlogger.FileSink{Path: t.TempDir()}, nil
+			},
+		}))
+		c := &Config{
+			Nodes: []NodeConfig{
+				{ID: "json", Type: "json"},
+				{ID: "custom", Type: "config-test-sink", Settings: map[string]interface{}{"label": "mine"}},
+			},
+			Pipelines: []PipelineConfig{{ID: "p", EventType: "audit", NodeIDs: []string{"json", "custom"}}},
+		}
+		b, err := NewBroker(ctx, c)
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = b.Close(ctx) })
+		assert.Equal(t, "mine", got)
+	})
+
+	t.Run("missing-config", func(t *testing.T) {
+		_, err := NewBroker(ctx, nil)
+		require.ErrorIs(t, err, eventlogger.ErrInvalidParameter)
+	})
+
+	t.Run("invalid-config", func(t *testing.T) {
+		_, err := NewBroker(ctx, &Config{Nodes: []NodeConfig{{ID: "a"}}})
+		require.Error(t, err)
+		assert.Equal(t, []string{"nodes[0].type"}, errorPaths(t, err))
+	})
+
+	t.Run("invalid-settings", func(t *testing.T) {
+		c, err := Parse([]byte(`{"nodes": [
+  {"id": "file", "type": "file", "settings": {"path": "/tmp", "max_bytes": "lots"}},
+  {"id": "file-2", "type": "file", "settings": {"path": "/tmp", "mode": "rwx"}},
+  {"id": "ce", "type": "cloudevents", "settings": {"source": "https://example.com", "format": "xml"}},
+  {"id": "gated", "type": "gated", "settings": {"expiration": "soon"}},
+  {"id": "json", "type": "json", "settings": {"pretty": true}},
+  {"id": "writer", "type": "writer", "settings": {"output": "/dev/null"}}
+]}`), FormatJSON)
+		require.NoError(t, err)
+		require.NoError(t, c.Validate())
+
+		_, err = NewBroker(ctx, c)
+		require.Error(t, err)
+		assert.Equal(t, []string{
+			"nodes[0].settings.max_bytes",
+			"nodes[1].settings.mode",
+			"nodes[2].settings.format",
+			"nodes[3].settings.expiration",
+			"nodes[4].settings.pretty",
+			"nodes[5].settings.output",
+		}, errorPaths(t, err))
+	})
+}
+
+// fmtHCL returns testHCL with the file sink writing to dir.
+func fmtHCL(dir string) string {
+	return fmt.Sprintf(testHCL, dir)
+}
@@ -0,0 +1,56 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package config builds an eventlogger.Broker from a declarative JSON or HCL
+// document, which lists the nodes to register (by type name and settings), the
+// pipelines which link them (and which of them must succeed) and the success
+// thresholds for each event type.
+//
+// For example, the following HCL registers a pipeline which writes audit
+// events to a file as cloudevents:
+//
+//	node "cloudevents" {
+//	  type = "cloudevents"
+//	  settings {
+//	    source = "https://example.com/my-service"
+//	  }
+//	}
+//
+//	node "audit-file" {
+//	  type = "file"
+//	  settings {
+//	    path         = "/var/log/my-service"
+//	    file_name    = "audit.log"
+//	    format       = "cloudevents-json"
+//	    max_duration = "24h"
+//	  }
+//	}
+//
+//	pipeline "audit" {
+//	  event_type     = "audit"
+//	  node_ids       = ["cloudevents", "audit-file"]
+//	  required_sinks = ["audit-file"]
+//	}
+//
+//	event_type "audit" {
+//	  success_threshold_sinks = 1
+//	}
+//
+// A node's type is the name of a factory registered with
+// eventlogger.RegisterNodeFactory, and its settings are checked against the
+// factory's settings schema.  The node types of this module's packages (such as
+// "file", "json", "writer", "cloudevents" and "gated") are always available,
+// and applications can register their own.  The encrypt filter isn't
+// registered: the filters/encrypt module depends on a released version of
+// eventlogger which predates the registry, and the filter needs a
+// wrapping.Wrapper which can't be described by settings, so applications which
+// use it must register a factory that supplies their wrapper.
+//
+// The equivalent JSON uses arrays of objects named "nodes", "pipelines" and
+// "event_types", where the labels of the HCL blocks are given as "id" (or
+// "event_type" for event types).
+//
+// Problems with the configuration are reported as *Error values, which
+// identify the offending part of the document using a path such as
+// nodes[1].settings.max_bytes (the second node).
+package config
@@ -0,0 +1,68 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package config_test
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/eventlogger/config"
+)
+
+func ExampleNewBroker() {
+	ctx := context.Background()
+
+	c, err := config.Parse([]byte(`
+node "json" {
+  type = "json"
+}
+
+node "stderr" {
+  type = "writer"
+  settings {
+    output = "stderr"
+  }
+}
+
+pipeline "audit" {
+  event_type = "audit"
+  node_ids   = ["json", "stderr"]
+}
+
+event_type "audit" {
+  success_threshold_sinks = 1
+}
+`), config.FormatHCL)
+	if err != nil {
+		// handle error
+	}
+
+	b, err := config.NewBroker(ctx, c)
+	if err != nil {
+		// handle error
+	}
+	defer b.Close(ctx)
+
+	fmt.Println(b.IsAnyPipelineRegistered("audit"))
+
+	// Output:
+	// true
+}
+
+func ExampleConfig_Validate() {
+	c, err := config.Parse([]byte(`{
+  "nodes": [{"id": "json", "type": "yaml"}],
+  "pipelines": [{"id": "audit", "event_type": "audit", "node_ids": ["json", "file"]}]
+}`), config.FormatJSON)
+	if err != nil {
+		// handle error
+	}
+
+	fmt.Println(c.Validate())
+
+	// Output:
+	// 2 errors occurred:
+	// 	* nodes[0].type: unknown node type "yaml"
+	// 	* pipelines[0].node_ids[1]: node ID "file" is not declared
+}
@@ -0,0 +1,122 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/hashicorp/hcl"
+	"github.com/hashicorp/hcl/hcl/ast"
+)
+
+// parseHCL decodes an HCL document into c, and records any keys which don't
+// correspond to a field of the config (which the HCL decoder ignores) so that
+// they can be reported by Validate.  Each top-level block is decoded on its
+// own, so that the blocks keep the order they were written in and their keys
+// are recorded alongside them.
+func parseHCL(data []byte, c *Config) error {
+	f, err := hcl.ParseBytes(data)
+	if err != nil {
+		return err
+	}
+	root, ok := f.Node.(*ast.ObjectList)
+	if !ok {
+		return fmt.Errorf("unexpected document root %T", f.Node)
+	}
+
+	for _, item := range root.Items {
+		var block Config
+		if err := hcl.DecodeObject(&block, &ast.ObjectList{Items: []*ast.ObjectItem{item}}); err != nil {
+			return err
+		}
+
+		switch key := itemKey(item); key {
+		case "node":
+			unknown := blockUnknownKeys(item, NodeConfig{})
+			for i := range block.Nodes {
+				if i < len(unknown) {
+					block.Nodes[i].UnusedKeys = unknown[i]
+				}
+			}
+			c.Nodes = append(c.Nodes, block.Nodes...)
+		case "pipeline":
+			unknown := blockUnknownKeys(item, PipelineConfig{})
+			for i := range block.Pipelines {
+				if i < len(unknown) {
+					block.Pipelines[i].UnusedKeys = unknown[i]
+				}
+			}
+			c.Pipelines = append(c.Pipelines, block.Pipelines...)
+		case "event_type":
+			unknown := blockUnknownKeys(item, EventTypeConfig{})
+			for i := range block.EventTypes {
+				if i < len(unknown) {
+					block.EventTypes[i].UnusedKeys = unknown[i]
+				}
+			}
+			c.EventTypes = append(c.EventTypes, block.EventTypes...)
+		default:
+			c.UnusedKeys = append(c.UnusedKeys, key)
+		}
+	}
+	return nil
+}
+
+// itemKey returns the first key of the item.
+func itemKey(item *ast.ObjectItem) string {
+	if len(item.Keys) == 0 {
+		return ""
+	}
+	if s, ok := item.Keys[0].Token.Value().(string); ok {
+		return s
+	}
+	return item.Keys[0].Token.Text
+}
+
+// blockUnknownKeys returns the unknown keys (see unknownKeys) of each element
+// which the HCL decoder decodes from a top-level block, in order.  A labelled
+// block, such as node "id" {...}, is a single element, whereas an unlabelled
+// block such as node { id {...} } holds an element for each of its items.
+func blockUnknownKeys(item *ast.ObjectItem, v interface{}) [][]string {
+	if len(item.Keys) > 1 {
+		return [][]string{unknownKeys(item.Val, v)}
+	}
+	obj, ok := item.Val.(*ast.ObjectType)
+	if !ok {
+		return nil
+	}
+	unknown := make([][]string, 0, len(obj.List.Items))
+	for _, child := range obj.List.Items {
+		unknown = append(unknown, unknownKeys(child.Val, v))
+	}
+	return unknown
+}
+
+// unknownKeys returns the keys within the block which don't correspond to an
+// hcl tagged field of v.
+func unknownKeys(val ast.Node, v interface{}) []string {
+	obj, ok := val.(*ast.ObjectType)
+	if !ok {
+		return nil
+	}
+
+	known := make(map[string]bool)
+	t := reflect.TypeOf(v)
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("hcl"), ",")
+		if name != "" {
+			known[name] = true
+		}
+	}
+
+	var unknown []string
+	for _, child := range obj.List.Items {
+		if key := itemKey(child); !known[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	return unknown
+}
@@ -0,0 +1,13 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package config
+
+import (
+	// Register the node types of the built-in packages, so that they're
+	// available to every config.
+	_ "github.com/hashicorp/eventlogger/filters/gated"
+	_ "github.com/hashicorp/eventlogger/formatter_filters/cloudevents"
+	_ "github.com/hashicorp/eventlogger/sinks/channel"
+	_ "github.com/hashicorp/eventlogger/sinks/writer"
+)
@@ -0,0 +1,159 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package eventlogger
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// DeadLetter is the payload of the events sent to an EventType's dead-letter
+// EventType (see SetDeadLetterEventType) when a node fails to process one of
+// its events, so that the event can be persisted and replayed later.
+type DeadLetter struct {
+	// Event which failed, as it was sent to the Broker.  It's a copy taken
+	// before any node processed the event, so changes made by nodes (such as
+	// formatting it) aren't included, and it mustn't be changed.
+	Event *Event
+
+	// PipelineID of the pipeline which failed
+	PipelineID PipelineID
+
+	// NodeID of the node which failed
+	NodeID NodeID
+
+	// Err returned by the node
+	Err error
+
+	// Attempts made by the node to process the Event, which is greater than 1
+	// when the node has a RetryPolicy.
+	Attempts int
+}
+
+// MarshalJSON encodes the DeadLetter as JSON, including the original event's
+// type, creation time, ID, sequence, metadata and payload so that it can be replayed.
+func (d *DeadLetter) MarshalJSON() ([]byte, error) {
+	type event struct {
+		CreatedAt time.Time         `json:"created_at"`
+		EventType EventType         `json:"event_type"`
+		ID        string            `json:"id,omitempty"`
+		Sequence  uint64            `json:"sequence,omitempty"`
+		Metadata  map[string]string `json:"metadata,omitempty"`
+		Payload   interface{}       `json:"payload"`
+	}
+	var e *event
+	if d.Event != nil {
+		e = &event{
+			CreatedAt: d.Event.CreatedAt,
+			EventType: d.Event.Type,
+			ID:        d.Event.ID,
+			Sequence:  d.Event.Sequence,
+			Metadata:  d.Event.Metadata(),
+			Payload:   d.Event.Payload,
+		}
+	}
+	var errMsg string
+	if d.Err != nil {
+		errMsg = d.Err.Error()
+	}
+	return json.Marshal(struct {
+		Event      *event     `json:"event"`
+		PipelineID PipelineID `json:"pipeline_id"`
+		NodeID     NodeID     `json:"node_id"`
+		Error      string     `json:"error,omitempty"`
+		Attempts   int        `json:"attempts"`
+	}{
+		Event:      e,
+		PipelineID: d.PipelineID,
+		NodeID:     d.NodeID,
+		Error:      errMsg,
+		Attempts:   d.Attempts,
+	})
+}
+
+// SetDeadLetterEventType sets the dead-letter EventType per EventType.  When a
+// node fails to process an event of type t (after any retries), a new event of
+// type deadLetterType is sent with a *DeadLetter payload describing the
+// failure.  Pipelines registered for deadLetterType determine where dead
+// letters are delivered, though pipelines registered for patterns (such as
+// AllEventTypes) don't receive them.  Events which are themselves dead letters
+// are never dead-lettered.  An empty deadLetterType disables dead-lettering.
+//
+// Dead letters are sent asynchronously once the node has failed, so Send
+// doesn't wait for them and failing to deliver them isn't reported in its
+// Status; use an Observer to monitor the dead-letter pipelines.  They're sent
+// even when the context passed to Send is done, within a timeout of 10s.
+func (b *Broker) SetDeadLetterEventType(t EventType, deadLetterType EventType) error {
+	switch {
+	case t == "":
+		return errors.New("event type cannot be empty")
+	case t == deadLetterType:
+		return fmt.Errorf("dead-letter event type cannot be the same as the event type %q", t)
+	case deadLetterType.IsPattern():
+		return fmt.Errorf("dead-letter event type %q cannot be a pattern", deadLetterType)
+	}
+	if err := validatePattern(t); err != nil {
+		return err
+	}
+
+	b.writeLock.Lock()
+	defer b.writeLock.Unlock()
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	g := b.graph(t).clone()
+	g.deadLetterType = deadLetterType
+	b.replaceGraphs(map[EventType]*graph{t: g})
+	return nil
+}
+
+// DeadLetterEventType returns the configured dead-letter EventType per
+// EventType (default: none), along with a boolean indicating whether the
+// EventType was registered with the broker.
+func (b *Broker) DeadLetterEventType(t EventType) (EventType, bool) {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+
+	g, ok := b.graphs[t]
+	if ok {
+		return g.deadLetterType, true
+	}
+
+	return "", false
+}
+
+// deadLetterTimeout bounds how long sending the dead letters of an event
+// takes, as they're sent even when the event's context is done.
+const deadLetterTimeout = 10 * time.Second
+
+// sendDeadLetters sends the dead letters as events of type t, using a goroutine
+// so that Send doesn't wait for them.  They're sent with a context which isn't
+// cancelled along with ctx (the failure being recorded may well be ctx being
+// done), but is bounded by deadLetterTimeout.  Close waits for them to be sent
+// before closing any nodes.
+func (b *Broker) sendDeadLetters(ctx context.Context, t EventType, deadLetters []*DeadLetter) {
+	if len(deadLetters) == 0 || !b.deadLetters.enter() {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), deadLetterTimeout)
+
+	go func() {
+		defer b.deadLetters.leave()
+		defer cancel()
+
+		for _, dl := range deadLetters {
+			e, err := b.newEvent(ctx, t, dl, options{})
+			if err != nil {
+				continue
+			}
+			// Nodes which fail to process a dead letter are reported to the
+			// Broker's Observer, as there's no Status to report them in.
+			_, _ = b.send(ctx, e)
+		}
+	}()
+}
@@ -0,0 +1,273 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package eventlogger
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// deadLetterSink returns a sink node which collects the DeadLetter payloads of
+// the events it processes.
+func deadLetterSink(l *sync.Mutex, got *[]*DeadLetter) *testActionNode {
+	return &testActionNode{
+		nodeType: NodeTypeSink,
+		action: func(ctx context.Context, e *Event) (*Event, error) {
+			l.Lock()
+			defer l.Unlock()
+			*got = append(*got, e.Payload.(*DeadLetter))
+			return nil, nil
+		},
+	}
+}
+
+// deadLetterPipeline is a pipeline for the EventType "dead" of the "formatter"
+// registered by newTestBroker and a "dead-sink".
+var deadLetterPipeline = Pipeline{PipelineID: "dead-letters", EventType: "dead", NodeIDs: []NodeID{"formatter", "dead-sink"}}
+
+func TestBroker_DeadLetter(t *testing.T) {
+	t.Parallel()
+
+	var l sync.Mutex
+	var got []*DeadLetter
+	var attempts atomic.Int32
+	errSink := errors.New("sink failed")
+	b := newTestBroker(t, map[NodeID]Node{"dead-sink": deadLetterSink(&l, &got)}, []Pipeline{deadLetterPipeline})
+	require.NoError(t, b.RegisterNode("sink", failingSink(100, errSink, &attempts), WithNodeRetryPolicy(RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond})))
+	require.NoError(t, b.RegisterPipeline(testPipeline))
+	require.NoError(t, b.SetSuccessThresholdSinks("dead", 1))
+
+	// Without a dead-letter event type, failures are only warnings.
+	s, err := b.Send(context.Background(), "t", "first")
+	require.NoError(t, err)
+	require.Len(t, s.Warnings, 1)
+	assert.Empty(t, got)
+
+	require.NoError(t, b.SetDeadLetterEventType("t", "dead"))
+	dlt, ok := b.DeadLetterEventType("t")
+	require.True(t, ok)
+	assert.Equal(t, EventType("dead"), dlt)
+
+	s, err = b.Send(context.Background(), "t", "second")
+	require.NoError(t, err)
+	require.Len(t, s.Warnings, 1)
+	assert.ErrorIs(t, s.Warnings[0], errSink)
+
+	// Close waits for the dead letters to be sent.
+	require.NoError(t, b.Close(context.Background()))
+	require.Len(t, got, 1)
+	dl := got[0]
+	assert.Equal(t, PipelineID("p"), dl.PipelineID)
+	assert.Equal(t, NodeID("sink"), dl.NodeID)
+	assert.ErrorIs(t, dl.Err, errSink)
+	assert.Equal(t, 2, dl.Attempts)
+	require.NotNil(t, dl.Event)
+	assert.Equal(t, EventType("t"), dl.Event.Type)
+	assert.Equal(t, "second", dl.Event.Payload)
+}
+
+func TestBroker_DeadLetter_Undeliverable(t *testing.T) {
+	t.Parallel()
+
+	var attempts, deadAttempts atomic.Int32
+	errSink := errors.New("sink failed")
+	errDead := errors.New("dead-letter sink failed")
+	b := newTestBroker(t, map[NodeID]Node{
+		"sink":      failingSink(100, errSink, &attempts),
+		"dead-sink": failingSink(100, errDead, &deadAttempts),
+	}, []Pipeline{testPipeline, deadLetterPipeline})
+	require.NoError(t, b.SetSuccessThresholdSinks("dead", 1))
+	require.NoError(t, b.SetDeadLetterEventType("t", "dead"))
+	// Dead letters are not dead-lettered, even if configured to be.
+	require.NoError(t, b.SetDeadLetterEventType("dead", "t"))
+
+	// Failing to deliver the dead letter isn't reported in the Status, as
+	// it's sent after Send returns.
+	s, err := b.Send(context.Background(), "t", "payload")
+	require.NoError(t, err)
+	require.Len(t, s.Warnings, 1)
+	assert.ErrorIs(t, s.Warnings[0], errSink)
+
+	require.NoError(t, b.Close(context.Background()))
+	assert.Equal(t, int32(1), deadAttempts.Load())
+	assert.Equal(t, int32(1), attempts.Load())
+}
+
+func TestBroker_DeadLetter_OriginalEvent(t *testing.T) {
+	t.Parallel()
+
+	var l sync.Mutex
+	var got []*DeadLetter
+	sink := &testActionNode{
+		action: func(ctx context.Context, e *Event) (*Event, error) {
+			e.SetMetadata("changed", "by sink")
+			return nil, errors.New("sink failed")
+		},
+	}
+	b := newTestBroker(t, map[NodeID]Node{"sink": sink, "dead-sink": deadLetterSink(&l, &got)}, []Pipeline{testPipeline, deadLetterPipeline})
+	require.NoError(t, b.SetSuccessThresholdSinks("dead", 1))
+	require.NoError(t, b.SetDeadLetterEventType("t", "dead"))
+
+	_, err := b.SendWithOptions(context.Background(), "t", "payload", WithMetadata(map[string]string{"tenant": "a"}))
+	require.NoError(t, err)
+	require.NoError(t, b.Close(context.Background()))
+	require.Len(t, got, 1)
+
+	// The dead letter refers to the event as it was sent, without the changes
+	// made by the formatter and the sink.
+	original := got[0].Event
+	assert.Equal(t, "payload", original.Payload)
+	assert.Equal(t, map[string]string{"tenant": "a"}, original.Metadata())
+	_, ok := original.Format(JSONFormat)
+	assert.False(t, ok)
+}
+
+func TestBroker_DeadLetter_Cancelled(t *testing.T) {
+	t.Parallel()
+
+	var l sync.Mutex
+	var got []*DeadLetter
+	b := newTestBroker(t, map[NodeID]Node{"sink": &testActionNode{}, "dead-sink": deadLetterSink(&l, &got)}, []Pipeline{testPipeline, deadLetterPipeline})
+	require.NoError(t, b.SetSuccessThresholdSinks("dead", 1))
+
+	// Dead letters are sent even when the context passed to Send is done,
+	// which may be why the node failed.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	b.sendDeadLetters(ctx, "dead", []*DeadLetter{{Event: &Event{Type: "t"}, PipelineID: "p", NodeID: "sink", Err: ctx.Err()}})
+	require.NoError(t, b.Close(context.Background()))
+	require.Len(t, got, 1)
+	assert.ErrorIs(t, got[0].Err, context.Canceled)
+}
+
+func TestBroker_DeadLetter_Async(t *testing.T) {
+	t.Parallel()
+
+	release := make(chan struct{})
+	var received atomic.Int32
+	deadSink := &testActionNode{
+		nodeType: NodeTypeSink,
+		action: func(ctx context.Context, e *Event) (*Event, error) {
+			received.Add(1)
+			<-release
+			return nil, nil
+		},
+	}
+	sink := &testActionNode{
+		action: func(ctx context.Context, e *Event) (*Event, error) {
+			return nil, errors.New("sink failed")
+		},
+	}
+	b := newTestBroker(t, map[NodeID]Node{"sink": sink, "dead-sink": deadSink}, []Pipeline{testPipeline, deadLetterPipeline})
+	require.NoError(t, b.SetDeadLetterEventType("t", "dead"))
+
+	// Send doesn't wait for the dead letter to be delivered.
+	_, err := b.Send(context.Background(), "t", "payload")
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return received.Load() == 1 }, time.Second, time.Millisecond)
+
+	// Close waits for it, though.
+	closed := make(chan error)
+	go func() {
+		closed <- b.Close(context.Background())
+	}()
+	select {
+	case <-closed:
+		t.Fatal("Close returned while a dead letter was being sent")
+	case <-time.After(10 * time.Millisecond):
+	}
+	close(release)
+	require.NoError(t, <-closed)
+}
+
+func TestBroker_DeadLetter_Patterns(t *testing.T) {
+	t.Parallel()
+
+	var l sync.Mutex
+	var got []*DeadLetter
+	all := make(chan *Event, 2)
+	sink := &testActionNode{
+		action: func(ctx context.Context, e *Event) (*Event, error) {
+			return nil, errors.New("sink failed")
+		},
+	}
+	b := newTestBroker(t, map[NodeID]Node{"sink": sink, "dead-sink": deadLetterSink(&l, &got), "all-sink": receivingSink(all)}, []Pipeline{
+		testPipeline,
+		deadLetterPipeline,
+		{PipelineID: "all", EventType: AllEventTypes, NodeIDs: []NodeID{"formatter", "all-sink"}},
+	})
+	require.NoError(t, b.SetDeadLetterEventType("t", "dead"))
+
+	_, err := b.Send(context.Background(), "t", "payload")
+	require.NoError(t, err)
+	require.NoError(t, b.Close(context.Background()))
+
+	// The dead letter is only sent to the pipelines registered for its
+	// EventType, and not to those registered for AllEventTypes.
+	require.Len(t, got, 1)
+	require.Len(t, all, 1)
+	assert.Equal(t, EventType("t"), (<-all).Type)
+}
+
+func TestBroker_SetDeadLetterEventType(t *testing.T) {
+	t.Parallel()
+
+	b, err := NewBroker()
+	require.NoError(t, err)
+
+	err = b.SetDeadLetterEventType("", "dead")
+	require.EqualError(t, err, "event type cannot be empty")
+	err = b.SetDeadLetterEventType("t", "t")
+	require.EqualError(t, err, `dead-letter event type cannot be the same as the event type "t"`)
+
+	_, ok := b.DeadLetterEventType("t")
+	require.False(t, ok)
+	require.NoError(t, b.SetDeadLetterEventType("t", ""))
+	dlt, ok := b.DeadLetterEventType("t")
+	require.True(t, ok)
+	require.Empty(t, dlt)
+}
+
+func TestDeadLetter_MarshalJSON(t *testing.T) {
+	t.Parallel()
+
+	dl := &DeadLetter{
+		Event: &Event{
+			Type:      "t",
+			CreatedAt: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+			Payload:   map[string]string{"user": "alice"},
+		},
+		PipelineID: "p",
+		NodeID:     "sink",
+		Err:        errors.New("disk full"),
+		Attempts:   3,
+	}
+
+	e, err := (&JSONFormatter{}).Process(context.Background(), &Event{Type: "dead", Payload: dl})
+	require.NoError(t, err)
+	formatted, ok := e.Format(JSONFormat)
+	require.True(t, ok)
+	assert.JSONEq(t, `{
+		"created_at": "0001-01-01T00:00:00Z",
+		"event_type": "dead",
+		"payload": {
+			"event": {
+				"created_at": "2024-01-02T03:04:05Z",
+				"event_type": "t",
+				"payload": {"user": "alice"}
+			},
+			"pipeline_id": "p",
+			"node_id": "sink",
+			"error": "disk full",
+			"attempts": 3
+		}
+	}`, string(formatted))
+}
@@ -8,4 +8,16 @@ import "errors"
 var (
 	ErrInvalidParameter = errors.New("invalid parameter")
 	ErrNodeNotFound     = errors.New("node not found")
+	ErrAsyncNotEnabled  = errors.New("async send not enabled")
+	ErrAsyncStopped     = errors.New("async send stopped")
+	ErrQueueFull        = errors.New("async queue full")
+	ErrEventDropped     = errors.New("event dropped")
+	ErrEventLost        = errors.New("event lost")
+	ErrTimeout          = errors.New("timeout")
+	ErrCircuitOpen      = errors.New("circuit open")
+	ErrBrokerClosed     = errors.New("broker closed")
+
+	ErrWorkerPoolSaturated    = errors.New("worker pool saturated")
+	ErrRequiredPipelineFailed = errors.New("required pipeline failed")
+	ErrInvalidPayloadType     = errors.New("invalid payload type")
 )
@@ -4,6 +4,7 @@
 package eventlogger
 
 import (
+	"fmt"
 	"sync"
 	"time"
 )
@@ -20,6 +21,15 @@ type Event struct {
 	// CreatedAt defines the time the event was Sent
 	CreatedAt time.Time
 
+	// ID of the Event, when given via WithEventID, set by a ContextExtractor
+	// or generated by the Broker's IDGenerator (see WithIDGenerator)
+	ID string
+
+	// Sequence of the Event amongst the events of its Type sent by the
+	// Broker, starting at 1.  It's set when the Event is routed, so the
+	// events which are routed have consecutive sequence numbers.
+	Sequence uint64
+
 	l sync.RWMutex
 
 	// Formatted used by Formatters to store formatted Event data which Sinks
@@ -29,6 +39,10 @@ type Event struct {
 
 	// Payload is the Event's payload data
 	Payload interface{}
+
+	// metadata carries cross-cutting data (such as request IDs, tenants or
+	// trace IDs) alongside the Payload, see SetMetadata.
+	metadata map[string]string
 }
 
 // FormattedAs sets a formatted value for the event, for the specified format
@@ -56,3 +70,93 @@ func (e *Event) Format(formatType string) ([]byte, bool) {
 	v, ok := e.Formatted[formatType]
 	return v, ok
 }
+
+// clone returns a copy of the Event, whose Formatted values and metadata can be
+// changed independently.  The Payload and the formatted values themselves are
+// shared.
+func (e *Event) clone() *Event {
+	e.l.RLock()
+	defer e.l.RUnlock()
+
+	c := &Event{
+		Type:      e.Type,
+		CreatedAt: e.CreatedAt,
+		ID:        e.ID,
+		Sequence:  e.Sequence,
+		Payload:   e.Payload,
+	}
+	if e.Formatted != nil {
+		c.Formatted = make(map[string][]byte, len(e.Formatted))
+		for k, v := range e.Formatted {
+			c.Formatted[k] = v
+		}
+	}
+	if e.metadata != nil {
+		c.metadata = make(map[string]string, len(e.metadata))
+		for k, v := range e.metadata {
+			c.metadata[k] = v
+		}
+	}
+	return c
+}
+
+// SetMetadata sets a metadata value for the event.  Any existing value for the
+// key is overwritten.  Metadata is shared by every pipeline processing the
+// event, so a value set by a node is visible to nodes which run after it,
+// including those of other pipelines.
+func (e *Event) SetMetadata(key, value string) {
+	e.l.Lock()
+	defer e.l.Unlock()
+	if e.metadata == nil {
+		e.metadata = make(map[string]string)
+	}
+	e.metadata[key] = value
+}
+
+// DeleteMetadata removes the metadata value for the key, if any.
+func (e *Event) DeleteMetadata(key string) {
+	e.l.Lock()
+	defer e.l.Unlock()
+	delete(e.metadata, key)
+}
+
+// MetadataValue will retrieve the metadata value for the key.  The two value
+// return allows the caller to determine the existence of the key.
+func (e *Event) MetadataValue(key string) (string, bool) {
+	e.l.RLock()
+	defer e.l.RUnlock()
+	v, ok := e.metadata[key]
+	return v, ok
+}
+
+// Metadata returns a copy of the event's metadata, or nil if it has none.
+func (e *Event) Metadata() map[string]string {
+	e.l.RLock()
+	defer e.l.RUnlock()
+	if len(e.metadata) == 0 {
+		return nil
+	}
+	md := make(map[string]string, len(e.metadata))
+	for k, v := range e.metadata {
+		md[k] = v
+	}
+	return md
+}
+
+// WithMetadata configures the option that sets metadata values for an event,
+// when sending an event.  It may be given more than once, in which case the
+// values are merged.
+func WithMetadata(md map[string]string) Option {
+	return func(o *options) error {
+		for k, v := range md {
+			if k == "" {
+				return fmt.Errorf("metadata key cannot be empty: %w", ErrInvalidParameter)
+			}
+			if o.withMetadata == nil {
+				o.withMetadata = make(map[string]string, len(md))
+			}
+			o.withMetadata[k] = v
+		}
+		return nil
+	}
+}
@@ -4,6 +4,7 @@
 package eventlogger
 
 import (
+	"context"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -76,3 +77,58 @@ func TestEvent_Format(t *testing.T) {
 		})
 	}
 }
+
+func TestEvent_Metadata(t *testing.T) {
+	t.Parallel()
+	assert, require := assert.New(t), require.New(t)
+
+	e := &Event{}
+	assert.Nil(e.Metadata())
+	_, ok := e.MetadataValue("tenant")
+	assert.False(ok)
+
+	e.SetMetadata("tenant", "a")
+	e.SetMetadata("request-id", "1")
+	e.SetMetadata("tenant", "b")
+	v, ok := e.MetadataValue("tenant")
+	require.True(ok)
+	assert.Equal("b", v)
+
+	md := e.Metadata()
+	assert.Equal(map[string]string{"tenant": "b", "request-id": "1"}, md)
+	md["tenant"] = "changed"
+	v, _ = e.MetadataValue("tenant")
+	assert.Equal("b", v, "Metadata should return a copy")
+
+	e.DeleteMetadata("tenant")
+	assert.Equal(map[string]string{"request-id": "1"}, e.Metadata())
+}
+
+func TestBroker_Send_WithMetadata(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	got := make(chan map[string]string, 1)
+	b, err := NewBroker()
+	require.NoError(t, err)
+	require.NoError(t, b.RegisterNode("tag", &Filter{Predicate: func(e *Event) (bool, error) {
+		e.SetMetadata("hostname", "host-1")
+		return true, nil
+	}}))
+	require.NoError(t, b.RegisterNode("formatter", &JSONFormatter{}))
+	require.NoError(t, b.RegisterNode("sink", &testActionNode{
+		action: func(_ context.Context, e *Event) (*Event, error) {
+			got <- e.Metadata()
+			return nil, nil
+		},
+	}))
+	require.NoError(t, b.RegisterPipeline(Pipeline{PipelineID: "p", EventType: "t", NodeIDs: []NodeID{"tag", "formatter", "sink"}}))
+
+	_, err = b.SendWithOptions(ctx, "t", "payload", WithMetadata(map[string]string{"tenant": "a"}), WithMetadata(map[string]string{"request-id": "1"}))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"tenant": "a", "request-id": "1", "hostname": "host-1"}, <-got)
+
+	_, err = b.SendWithOptions(ctx, "t", "payload", WithMetadata(map[string]string{"": "a"}))
+	require.ErrorIs(t, err, ErrInvalidParameter)
+	assert.EqualError(t, err, "cannot send event: metadata key cannot be empty: invalid parameter")
+}
@@ -0,0 +1,78 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package eventlogger
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// ContextExtractor populates an Event from the context passed to Send, before
+// the Event is processed.  This allows values carried by the context, such as
+// request IDs or the current trace span, to be recorded with every Event
+// without the caller adding them to each payload.  Extract is called on the
+// goroutine calling Send, so implementations should not block.
+type ContextExtractor interface {
+	Extract(ctx context.Context, e *Event)
+}
+
+// ContextExtractorFunc is an adapter which allows an ordinary func to be used
+// as a ContextExtractor.
+type ContextExtractorFunc func(ctx context.Context, e *Event)
+
+// Extract calls f(ctx, e).
+func (f ContextExtractorFunc) Extract(ctx context.Context, e *Event) {
+	f(ctx, e)
+}
+
+// ContextValueMetadata returns a ContextExtractor which sets the metadata value
+// for metadataKey to the value of ctxKey in the context, formatted with
+// fmt.Sprint, when the context has a value for ctxKey.
+func ContextValueMetadata(ctxKey interface{}, metadataKey string) ContextExtractor {
+	return ContextExtractorFunc(func(ctx context.Context, e *Event) {
+		if v := ctx.Value(ctxKey); v != nil {
+			e.SetMetadata(metadataKey, fmt.Sprint(v))
+		}
+	})
+}
+
+// WithContextExtractor configures the option that registers a ContextExtractor
+// with the Broker.  It may be given more than once, in which case the
+// extractors are called in the order they were given.  Options passed to Send,
+// such as WithMetadata, take precedence over values set by extractors.
+func WithContextExtractor(x ContextExtractor) Option {
+	return func(o *options) error {
+		if x == nil {
+			return fmt.Errorf("context extractor cannot be nil: %w", ErrInvalidParameter)
+		}
+		o.withContextExtractors = append(o.withContextExtractors, x)
+		return nil
+	}
+}
+
+// WithCreatedAt configures the option that sets the CreatedAt time of an event,
+// when sending an event, rather than using the Broker's clock.  This is
+// intended for replaying events which were created earlier.
+func WithCreatedAt(t time.Time) Option {
+	return func(o *options) error {
+		if t.IsZero() {
+			return fmt.Errorf("created at time cannot be zero: %w", ErrInvalidParameter)
+		}
+		o.withCreatedAt = t
+		return nil
+	}
+}
+
+// WithEventID configures the option that sets the ID of an event, when sending
+// an event.
+func WithEventID(id string) Option {
+	return func(o *options) error {
+		if id == "" {
+			return fmt.Errorf("event ID cannot be empty: %w", ErrInvalidParameter)
+		}
+		o.withEventID = id
+		return nil
+	}
+}
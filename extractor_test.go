@@ -0,0 +1,156 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package eventlogger
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testContextKey string
+
+// testSpan stands in for the span context of a tracing library.
+type testSpan struct {
+	traceID string
+	spanID  string
+}
+
+type testSpanKey struct{}
+
+// receivingSink returns a sink node which sends the events it receives to got.
+func receivingSink(got chan<- *Event) *testActionNode {
+	return &testActionNode{
+		action: func(_ context.Context, e *Event) (*Event, error) {
+			got <- e
+			return nil, nil
+		},
+	}
+}
+
+func TestBroker_Send_ContextExtractor(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	var calls []string
+	got := make(chan *Event, 1)
+	b := newTestBroker(t, map[NodeID]Node{"sink": receivingSink(got)}, []Pipeline{testPipeline},
+		WithContextExtractor(ContextValueMetadata(testContextKey("request-id"), "request_id")),
+		WithContextExtractor(ContextExtractorFunc(func(ctx context.Context, e *Event) {
+			calls = append(calls, "span")
+			if span, ok := ctx.Value(testSpanKey{}).(testSpan); ok {
+				e.SetMetadata("trace_id", span.traceID)
+				e.SetMetadata("span_id", span.spanID)
+			}
+		})),
+	)
+	b.StopTimeAt(now)
+
+	ctx := context.WithValue(context.Background(), testContextKey("request-id"), 42)
+	ctx = context.WithValue(ctx, testSpanKey{}, testSpan{traceID: "t-1", spanID: "s-1"})
+	_, err := b.Send(ctx, "t", "payload")
+	require.NoError(t, err)
+	e := <-got
+	assert.Equal(t, map[string]string{"request_id": "42", "trace_id": "t-1", "span_id": "s-1"}, e.Metadata())
+	assert.Equal(t, now, e.CreatedAt)
+	assert.Empty(t, e.ID)
+
+	// The extractors are called even when the context has no values.
+	_, err = b.Send(context.Background(), "t", "payload")
+	require.NoError(t, err)
+	assert.Nil(t, (<-got).Metadata())
+	assert.Equal(t, []string{"span", "span"}, calls)
+}
+
+func TestBroker_SendWithOptions(t *testing.T) {
+	t.Parallel()
+
+	createdAt := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	got := make(chan *Event, 1)
+	b := newTestBroker(t, map[NodeID]Node{"sink": receivingSink(got)}, []Pipeline{testPipeline}, WithContextExtractor(ContextExtractorFunc(func(_ context.Context, e *Event) {
+		e.ID = "extracted"
+		e.SetMetadata("tenant", "extracted")
+		e.SetMetadata("region", "eu")
+	})))
+	b.StopTimeAt(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC))
+
+	// The options take precedence over the extractor.
+	_, err := b.SendWithOptions(context.Background(), "t", "payload",
+		WithCreatedAt(createdAt),
+		WithEventID("event-1"),
+		WithMetadata(map[string]string{"tenant": "a"}),
+	)
+	require.NoError(t, err)
+	e := <-got
+	assert.Equal(t, createdAt, e.CreatedAt)
+	assert.Equal(t, "event-1", e.ID)
+	assert.Equal(t, map[string]string{"tenant": "a", "region": "eu"}, e.Metadata())
+
+	_, err = b.Send(context.Background(), "t", "payload")
+	require.NoError(t, err)
+	e = <-got
+	assert.Equal(t, "extracted", e.ID)
+	assert.Equal(t, map[string]string{"tenant": "extracted", "region": "eu"}, e.Metadata())
+}
+
+func TestBroker_SendWithOptions_Invalid(t *testing.T) {
+	t.Parallel()
+
+	b := newTestBroker(t, map[NodeID]Node{"sink": receivingSink(make(chan *Event, 1))}, []Pipeline{testPipeline})
+
+	tests := map[string]struct {
+		opt     Option
+		wantErr string
+	}{
+		"zero-created-at": {
+			opt:     WithCreatedAt(time.Time{}),
+			wantErr: "cannot send event: created at time cannot be zero: invalid parameter",
+		},
+		"empty-event-id": {
+			opt:     WithEventID(""),
+			wantErr: "cannot send event: event ID cannot be empty: invalid parameter",
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := b.SendWithOptions(context.Background(), "t", "payload", tc.opt)
+			require.ErrorIs(t, err, ErrInvalidParameter)
+			assert.EqualError(t, err, tc.wantErr)
+		})
+	}
+
+	_, err := NewBroker(WithContextExtractor(nil))
+	require.ErrorIs(t, err, ErrInvalidParameter)
+	assert.EqualError(t, err, "cannot create broker: context extractor cannot be nil: invalid parameter")
+}
+
+func TestBroker_SendAsync_ContextExtractor(t *testing.T) {
+	t.Parallel()
+
+	got := make(chan *Event, 1)
+	b := newTestBroker(t, map[NodeID]Node{"sink": receivingSink(got)}, []Pipeline{testPipeline},
+		WithAsyncQueue(1, 1),
+		WithContextExtractor(ContextValueMetadata(testContextKey("request-id"), "request_id")),
+	)
+	t.Cleanup(func() { _ = b.StopAsync(context.Background()) })
+	require.NoError(t, b.SetSuccessThresholdSinks("t", 1))
+
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), testContextKey("request-id"), "r-1"))
+	f, err := b.SendAsync(ctx, "t", "payload", WithEventID("event-1"))
+	require.NoError(t, err)
+	// The event was populated when SendAsync was called.
+	cancel()
+	_, err = f.Wait(context.Background())
+	require.NoError(t, err)
+	e := <-got
+	assert.Equal(t, "event-1", e.ID)
+	assert.Equal(t, map[string]string{"request_id": "r-1"}, e.Metadata())
+}
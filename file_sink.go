@@ -67,7 +67,12 @@ type FileSink struct {
 	l sync.Mutex
 }
 
-var _ Node = &FileSink{}
+var (
+	_ Node          = &FileSink{}
+	_ Opener        = &FileSink{}
+	_ HealthChecker = &FileSink{}
+	_ Named         = &FileSink{}
+)
 
 const (
 	defaultMode = 0600
@@ -186,6 +191,52 @@ func (fs *FileSink) Reopen() error {
 	return fs.reopen()
 }
 
+// Open creates the Sink's directory and checks that it's writable, so that a
+// Broker fails to register a pipeline for a file which can't be written.  The
+// file itself is opened when the first event is written.
+func (fs *FileSink) Open(_ context.Context) error {
+	switch fs.Path {
+	case stdout, stderr, devnull:
+		return nil
+	}
+
+	fs.l.Lock()
+	defer fs.l.Unlock()
+
+	if err := os.MkdirAll(fs.Path, dirMode); err != nil {
+		return err
+	}
+	return fs.checkWritable()
+}
+
+// Health reports whether the Sink's directory is writable and, once it has
+// been opened, whether its file still exists.
+func (fs *FileSink) Health(_ context.Context) error {
+	switch fs.Path {
+	case stdout, stderr, devnull:
+		return nil
+	}
+
+	fs.l.Lock()
+	defer fs.l.Unlock()
+
+	if fs.f != nil {
+		if _, err := os.Stat(fs.f.Name()); err != nil {
+			return fmt.Errorf("file is not open: %w", err)
+		}
+	}
+	return fs.checkWritable()
+}
+
+// checkWritable checks that files can be created in the Sink's directory, as
+// the file is (re)created there when it's opened, rotated or reopened.
+func (fs *FileSink) checkWritable() error {
+	if err := checkDirWritable(fs.Path); err != nil {
+		return fmt.Errorf("directory is not writable: %w", err)
+	}
+	return nil
+}
+
 // Name returns a representation of the Sink's name
 func (fs *FileSink) Name() string {
 	return fmt.Sprintf("sink:%s", fs.Path)
@@ -6,6 +6,7 @@ package eventlogger
 import (
 	"bytes"
 	"context"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"os"
 	"path/filepath"
@@ -407,3 +408,66 @@ func TestFileSink_DirMode(t *testing.T) {
 		t.Errorf("Expected file mode %q, got %q", parentDirMode.Perm(), actualDirMode.Perm())
 	}
 }
+
+func TestFileSink_Open(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	dir := filepath.Join(t.TempDir(), "audit")
+	fs := FileSink{Path: dir, FileName: "audit.log"}
+	require.NoError(t, fs.Open(ctx))
+
+	// The directory is created, but the file is opened when the first event is
+	// written.
+	files, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, files)
+
+	fs = FileSink{Path: filepath.Join(dir, "audit.log", "nested"), FileName: "audit.log"}
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "audit.log"), nil, 0o600))
+	assert.Error(t, fs.Open(ctx))
+
+	for _, path := range []string{stdout, stderr, devnull} {
+		fs = FileSink{Path: path}
+		assert.NoError(t, fs.Open(ctx), path)
+	}
+}
+
+func TestFileSink_Health(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	dir := t.TempDir()
+	fs := FileSink{Path: dir, FileName: "audit.log"}
+	require.NoError(t, fs.Health(ctx))
+
+	e := &Event{Formatted: map[string][]byte{JSONFormat: []byte("first")}}
+	_, err := fs.Process(ctx, e)
+	require.NoError(t, err)
+	require.NoError(t, fs.Health(ctx))
+
+	// Checking the health doesn't create any files.
+	files, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	assert.Equal(t, "audit.log", files[0].Name())
+
+	// The superuser can write to any directory.
+	if os.Geteuid() != 0 {
+		require.NoError(t, os.Chmod(dir, 0o500))
+		assert.ErrorContains(t, fs.Health(ctx), "directory is not writable")
+		require.NoError(t, os.Chmod(dir, 0o700))
+	}
+
+	require.NoError(t, os.Remove(filepath.Join(dir, "audit.log")))
+	assert.ErrorContains(t, fs.Health(ctx), "file is not open")
+
+	require.NoError(t, fs.Reopen())
+	require.NoError(t, fs.Health(ctx))
+
+	require.NoError(t, os.RemoveAll(dir))
+	assert.Error(t, fs.Health(ctx))
+
+	fs = FileSink{Path: devnull}
+	assert.NoError(t, fs.Health(ctx))
+}
@@ -0,0 +1,16 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:build !windows
+
+package eventlogger
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+// checkDirWritable checks the permissions of the directory, without creating
+// a file in it.
+func checkDirWritable(dir string) error {
+	return unix.Access(dir, unix.W_OK|unix.X_OK)
+}
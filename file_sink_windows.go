@@ -0,0 +1,26 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:build windows
+
+package eventlogger
+
+import (
+	"fmt"
+	"os"
+)
+
+// checkDirWritable checks that the directory exists and isn't read-only, as
+// Windows doesn't use the permissions of directories.
+func checkDirWritable(dir string) error {
+	fi, err := os.Stat(dir)
+	switch {
+	case err != nil:
+		return err
+	case !fi.IsDir():
+		return fmt.Errorf("%s is not a directory", dir)
+	case fi.Mode().Perm()&0o200 == 0:
+		return fmt.Errorf("%s is read-only", dir)
+	}
+	return nil
+}
@@ -17,7 +17,10 @@ type Filter struct {
 	name      string
 }
 
-var _ Node = &Filter{}
+var (
+	_ Node  = &Filter{}
+	_ Named = &Filter{}
+)
 
 // Process will call the Filter's Predicate func to determine whether to return
 // the Event or filter it out of the Pipeline (Filtered Events return nil, nil,
@@ -0,0 +1,28 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package gated
+
+import (
+	"github.com/hashicorp/eventlogger"
+)
+
+func init() {
+	eventlogger.MustRegisterNodeFactory("gated", eventlogger.NodeFactory{
+		Description: "Filter buffers Gateable events until they're flushed or expire",
+		Settings: []eventlogger.NodeSetting{
+			{Name: "expiration", Type: eventlogger.NodeSettingDuration, Description: "time after which gated events expire, defaulting to 10s"},
+		},
+		New: newFilterFromSettings,
+	})
+}
+
+// newFilterFromSettings creates a Filter, which sends expired events using the
+// Broker it's registered with (if any).
+func newFilterFromSettings(b *eventlogger.Broker, s eventlogger.NodeSettings) (eventlogger.Node, error) {
+	f := &Filter{Expiration: s.Duration("expiration")}
+	if b != nil {
+		f.Broker = b
+	}
+	return f, nil
+}
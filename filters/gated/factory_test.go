@@ -0,0 +1,28 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package gated_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hashicorp/eventlogger"
+	"github.com/hashicorp/eventlogger/filters/gated"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewFilterFromSettings(t *testing.T) {
+	b, err := eventlogger.NewBroker()
+	require.NoError(t, err)
+
+	n, err := eventlogger.NewNodeFromSettings(b, "gated", map[string]interface{}{"expiration": "5m"})
+	require.NoError(t, err)
+	assert.Equal(t, &gated.Filter{Broker: b, Expiration: 5 * time.Minute}, n)
+
+	// Without a broker, expired events are deleted rather than sent.
+	n, err = eventlogger.NewNodeFromSettings(nil, "gated", nil)
+	require.NoError(t, err)
+	assert.Nil(t, n.(*gated.Filter).Broker)
+}
@@ -17,20 +17,29 @@ const (
 // JSONFormatter is a Formatter Node which formats the Event as JSON.
 type JSONFormatter struct{}
 
-var _ Node = &JSONFormatter{}
+var (
+	_ Node  = &JSONFormatter{}
+	_ Named = &JSONFormatter{}
+)
 
-// Process formats the Event as JSON and stores that formatted data in
-// Event.Formatted with a key of "json"
+// Process formats the Event (including its ID, sequence and metadata) as JSON and stores that
+// formatted data in Event.Formatted with a key of "json"
 func (w *JSONFormatter) Process(ctx context.Context, e *Event) (*Event, error) {
 	buf := &bytes.Buffer{}
 	enc := json.NewEncoder(buf)
 	err := enc.Encode(struct {
 		CreatedAt time.Time `json:"created_at"`
 		EventType `json:"event_type"`
-		Payload   interface{} `json:"payload"`
+		ID        string            `json:"id,omitempty"`
+		Sequence  uint64            `json:"sequence,omitempty"`
+		Metadata  map[string]string `json:"metadata,omitempty"`
+		Payload   interface{}       `json:"payload"`
 	}{
 		e.CreatedAt,
 		e.Type,
+		e.ID,
+		e.Sequence,
+		e.Metadata(),
 		e.Payload,
 	})
 	if err != nil {
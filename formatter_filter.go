@@ -18,7 +18,10 @@ type JSONFormatterFilter struct {
 	Predicate func(e interface{}) (bool, error)
 }
 
-var _ Node = &JSONFormatterFilter{}
+var (
+	_ Node  = &JSONFormatterFilter{}
+	_ Named = &JSONFormatterFilter{}
+)
 
 // Process formats the Event as JSON and stores that formatted data in
 // Event.Formatted with a key of "json" and then may filter the event based on
@@ -29,10 +32,16 @@ func (w *JSONFormatterFilter) Process(ctx context.Context, e *Event) (*Event, er
 	err := enc.Encode(struct {
 		CreatedAt time.Time `json:"created_at"`
 		EventType `json:"event_type"`
-		Payload   interface{} `json:"payload"`
+		ID        string            `json:"id,omitempty"`
+		Sequence  uint64            `json:"sequence,omitempty"`
+		Metadata  map[string]string `json:"metadata,omitempty"`
+		Payload   interface{}       `json:"payload"`
 	}{
 		e.CreatedAt,
 		e.Type,
+		e.ID,
+		e.Sequence,
+		e.Metadata(),
 		e.Payload,
 	})
 	if err != nil {
@@ -68,5 +77,5 @@ func (w *JSONFormatterFilter) Type() NodeType {
 
 // Name returns a representation of the FormatterFilter's name
 func (w *JSONFormatterFilter) Name() string {
-	return "JSONFormatteFilter"
+	return "JSONFormatterFilter"
 }
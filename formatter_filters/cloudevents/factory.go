@@ -0,0 +1,48 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package cloudevents
+
+import (
+	"net/url"
+
+	"github.com/hashicorp/eventlogger"
+)
+
+func init() {
+	eventlogger.MustRegisterNodeFactory("cloudevents", eventlogger.NodeFactory{
+		Description: "FormatterFilter formats events as cloudevents",
+		Settings: []eventlogger.NodeSetting{
+			{Name: "source", Type: eventlogger.NodeSettingString, Required: true, Description: "URL identifying the source of events"},
+			{Name: "schema", Type: eventlogger.NodeSettingString, Description: "URL of the schema of event data"},
+			{Name: "format", Type: eventlogger.NodeSettingString, Description: "either \"cloudevents-json\" (the default) or \"cloudevents-text\""},
+		},
+		New: newFormatterFilterFromSettings,
+	})
+}
+
+// newFormatterFilterFromSettings creates a FormatterFilter.
+func newFormatterFilterFromSettings(_ *eventlogger.Broker, s eventlogger.NodeSettings) (eventlogger.Node, error) {
+	source, err := url.Parse(s.String("source"))
+	if err != nil || s.String("source") == "" {
+		return nil, eventlogger.NewNodeSettingError("source", "%q is not a valid URL", s.String("source"))
+	}
+
+	var schema *url.URL
+	if raw := s.String("schema"); raw != "" {
+		if schema, err = url.Parse(raw); err != nil {
+			return nil, eventlogger.NewNodeSettingError("schema", "%q is not a valid URL", raw)
+		}
+	}
+
+	format := Format(s.String("format"))
+	if err := format.validate(); err != nil {
+		return nil, eventlogger.NewNodeSettingError("format", "%q is not a valid cloudevents format", format)
+	}
+
+	return &FormatterFilter{
+		Source: source,
+		Schema: schema,
+		Format: format,
+	}, nil
+}
@@ -0,0 +1,44 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package cloudevents
+
+import (
+	"testing"
+
+	"github.com/hashicorp/eventlogger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewFormatterFilterFromSettings(t *testing.T) {
+	n, err := eventlogger.NewNodeFromSettings(nil, "cloudevents", map[string]interface{}{
+		"source": "https://example.com/my-service",
+		"schema": "https://example.com/schema",
+		"format": "cloudevents-text",
+	})
+	require.NoError(t, err)
+	f, ok := n.(*FormatterFilter)
+	require.True(t, ok)
+	assert.Equal(t, "https://example.com/my-service", f.Source.String())
+	assert.Equal(t, "https://example.com/schema", f.Schema.String())
+	assert.Equal(t, FormatText, f.Format)
+
+	tests := map[string]struct {
+		settings    map[string]interface{}
+		wantSetting string
+	}{
+		"missing-source": {settings: map[string]interface{}{}, wantSetting: "source"},
+		"invalid-source": {settings: map[string]interface{}{"source": "://"}, wantSetting: "source"},
+		"invalid-schema": {settings: map[string]interface{}{"source": "https://example.com", "schema": "://"}, wantSetting: "schema"},
+		"invalid-format": {settings: map[string]interface{}{"source": "https://example.com", "format": "xml"}, wantSetting: "format"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := eventlogger.NewNodeFromSettings(nil, "cloudevents", tc.settings)
+			var settingErr *eventlogger.NodeSettingError
+			require.ErrorAs(t, err, &settingErr)
+			assert.Equal(t, tc.wantSetting, settingErr.Setting)
+		})
+	}
+}
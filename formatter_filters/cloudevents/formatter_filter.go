@@ -10,7 +10,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/url"
+	"sort"
+	"strings"
 	"time"
+	"unicode"
 
 	"github.com/hashicorp/eventlogger"
 	"github.com/hashicorp/go-secure-stdlib/strutil"
@@ -24,8 +27,9 @@ const (
 
 // ID defines an optional single function interface that Event Payloads may
 // implement which returns the cloudevent ID for the event payload. If an Event
-// Payload doesn't implement this optional interface then a unique ID is
-// generated and used for the cloudevent's ID.
+// Payload doesn't implement this optional interface then the Event's ID is
+// used for the cloudevent's ID, or a unique ID is generated when the Event
+// doesn't have one.
 type ID interface {
 	// ID returns the cloudevent ID
 	ID() string
@@ -82,6 +86,89 @@ type Event struct {
 	// SerializedHmac is optional and will contain the signature of the
 	// serialized field (see: FormatterFilter.Signer)
 	SerializedHmac string `json:"serialized_hmac,omitempty"`
+
+	// Extensions are optional extension context attributes, which are encoded
+	// alongside the other attributes (see: FormatterFilter.Process)
+	Extensions map[string]string `json:"-"`
+}
+
+// reservedAttributes are the names of attributes which extensions can't use.
+var reservedAttributes = map[string]struct{}{
+	"id": {}, "source": {}, "specversion": {}, "type": {}, "data": {}, "data_base64": {},
+	"datacontenttype": {}, "datacontentype": {}, "dataschema": {}, "subject": {}, "time": {},
+	"serialized": {}, "serialized_hmac": {},
+}
+
+// MarshalJSON encodes the Event as JSON, with its Extensions (sorted by name)
+// following the other attributes.
+func (e Event) MarshalJSON() ([]byte, error) {
+	type event Event
+	data, err := json.Marshal(event(e))
+	if err != nil || len(e.Extensions) == 0 {
+		return data, err
+	}
+
+	names := make([]string, 0, len(e.Extensions))
+	for name := range e.Extensions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	buf := bytes.NewBuffer(data[:len(data)-1])
+	for _, name := range names {
+		k, err := json.Marshal(name)
+		if err != nil {
+			return nil, err
+		}
+		v, err := json.Marshal(e.Extensions[name])
+		if err != nil {
+			return nil, err
+		}
+		buf.WriteByte(',')
+		buf.Write(k)
+		buf.WriteByte(':')
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// extensions converts metadata into extension attributes.  Extension names may
+// only contain lowercase letters and digits, so keys are lowercased and any
+// other characters are removed.  Keys which would be empty or which would
+// conflict with another attribute are ignored, and if several keys convert to
+// the same name then the first (in sorted order) is used.
+func extensions(md map[string]string) map[string]string {
+	if len(md) == 0 {
+		return nil
+	}
+
+	keys := make([]string, 0, len(md))
+	for k := range md {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	ext := make(map[string]string, len(md))
+	for _, k := range keys {
+		name := strings.Map(func(r rune) rune {
+			r = unicode.ToLower(r)
+			if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+				return r
+			}
+			return -1
+		}, k)
+		if _, reserved := reservedAttributes[name]; reserved || name == "" {
+			continue
+		}
+		if _, ok := ext[name]; !ok {
+			ext[name] = md[k]
+		}
+	}
+	if len(ext) == 0 {
+		return nil
+	}
+	return ext
 }
 
 // FormatterFilter is a Node which formats the Event as a CloudEvent in JSON
@@ -116,7 +203,10 @@ type FormatterFilter struct {
 	SignEventTypes []string
 }
 
-var _ eventlogger.Node = &FormatterFilter{}
+var (
+	_ eventlogger.Node  = &FormatterFilter{}
+	_ eventlogger.Named = &FormatterFilter{}
+)
 
 func (f *FormatterFilter) validate() error {
 	const op = "cloudevents.(FormatterFilter).validate"
@@ -138,8 +228,9 @@ func (f *FormatterFilter) validate() error {
 // Process formats the Event as a cloudevent and stores that formatted data in
 // Event.Formatted0 with a key of either "cloudevents-json"
 // (cloudevents.FormatJSON) or "cloudevents-text" (cloudevents.FormatText) based
-// on the FormatterFilter.Format value. If the node has a Predicate, then the
-// filter will be applied to the resulting CloudEvent.
+// on the FormatterFilter.Format value. The Event's metadata is included as
+// extension attributes (see Event.Extensions). If the node has a Predicate, then
+// the filter will be applied to the resulting CloudEvent.
 func (f *FormatterFilter) Process(ctx context.Context, e *eventlogger.Event) (*eventlogger.Event, error) {
 	const op = "cloudevents.(FormatterFilter).Process"
 	if err := f.validate(); err != nil {
@@ -161,6 +252,8 @@ func (f *FormatterFilter) Process(ctx context.Context, e *eventlogger.Event) (*e
 		if id == "" {
 			return nil, fmt.Errorf("%s: returned ID() is empty: %w", op, eventlogger.ErrInvalidParameter)
 		}
+	} else if e.ID != "" {
+		id = e.ID
 	} else {
 		var err error
 		id, err = newId()
@@ -181,6 +274,7 @@ func (f *FormatterFilter) Process(ctx context.Context, e *eventlogger.Event) (*e
 		Data:        data,
 		DataSchema:  schema,
 		Time:        e.CreatedAt,
+		Extensions:  extensions(e.Metadata()),
 	}
 	switch f.Format {
 	case FormatJSON, FormatUnspecified:
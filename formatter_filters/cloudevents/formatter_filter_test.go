@@ -422,3 +422,132 @@ func (t *testOptionalInterfaces) ID() string {
 func (t *testOptionalInterfaces) Data() interface{} {
 	return t.payload["data"]
 }
+
+func TestFormatterFilter_Process_Metadata(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	testURL, err := url.Parse("https://localhost")
+	require.NoError(t, err)
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := map[string]struct {
+		format Format
+		want   string
+	}{
+		"json": {
+			format: FormatJSON,
+			want:   `{"id":"1","source":"https://localhost","specversion":"1.0","type":"test","data":"p","datacontentype":"application/cloudevents","time":"2024-01-02T03:04:05Z","requestid":"r-1","tenant":"a"}` + "\n",
+		},
+		"text": {
+			format: FormatText,
+			want: `{
+  "id": "1",
+  "source": "https://localhost",
+  "specversion": "1.0",
+  "type": "test",
+  "data": "p",
+  "datacontentype": "text/plain",
+  "time": "2024-01-02T03:04:05Z",
+  "requestid": "r-1",
+  "tenant": "a"
+}
+`,
+		},
+	}
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var got Event
+			f := &FormatterFilter{
+				Source: testURL,
+				Format: tc.format,
+				Predicate: func(_ context.Context, ce interface{}) (bool, error) {
+					got = ce.(Event)
+					return true, nil
+				},
+			}
+			e := &eventlogger.Event{Type: "test", CreatedAt: now, Payload: testPayloadWithID{id: "1", data: "p"}}
+			e.SetMetadata("Request-ID", "r-1")
+			e.SetMetadata("tenant", "a")
+			e.SetMetadata("type", "ignored")
+			e.SetMetadata("--", "ignored")
+
+			_, err := f.Process(ctx, e)
+			require.NoError(t, err)
+			assert.Equal(t, map[string]string{"requestid": "r-1", "tenant": "a"}, got.Extensions)
+			formatted, ok := e.Format(string(tc.format))
+			require.True(t, ok)
+			assert.Equal(t, tc.want, string(formatted))
+		})
+	}
+}
+
+func Test_extensions(t *testing.T) {
+	t.Parallel()
+
+	assert.Nil(t, extensions(nil))
+	assert.Nil(t, extensions(map[string]string{"ID": "reserved", "!": "empty"}))
+	// Keys which convert to the same name use the first, in sorted order.
+	assert.Equal(t, map[string]string{"traceid": "first", "x1": "y"}, extensions(map[string]string{
+		"trace_id": "second",
+		"Trace-ID": "first",
+		"x1":       "y",
+	}))
+}
+
+// testPayloadWithID is a payload which implements the ID and Data interfaces.
+type testPayloadWithID struct {
+	id   string
+	data interface{}
+}
+
+func (p testPayloadWithID) ID() string        { return p.id }
+func (p testPayloadWithID) Data() interface{} { return p.data }
+
+func TestFormatterFilter_Process_EventID(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	testURL, err := url.Parse("https://localhost")
+	require.NoError(t, err)
+
+	tests := map[string]struct {
+		e      *eventlogger.Event
+		wantID string
+	}{
+		"payload-id": {
+			e:      &eventlogger.Event{Type: "test", ID: "event-1", Payload: testPayloadWithID{id: "payload-1", data: "p"}},
+			wantID: "payload-1",
+		},
+		"event-id": {
+			e:      &eventlogger.Event{Type: "test", ID: "event-1", Payload: "p"},
+			wantID: "event-1",
+		},
+		"generated": {
+			e: &eventlogger.Event{Type: "test", Payload: "p"},
+		},
+	}
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var got Event
+			f := &FormatterFilter{
+				Source: testURL,
+				Predicate: func(_ context.Context, ce interface{}) (bool, error) {
+					got = ce.(Event)
+					return true, nil
+				},
+			}
+			_, err := f.Process(ctx, tc.e)
+			require.NoError(t, err)
+			if tc.wantID == "" {
+				assert.Len(t, got.ID, 10)
+				return
+			}
+			assert.Equal(t, tc.wantID, got.ID)
+		})
+	}
+}
@@ -6,6 +6,10 @@ package eventlogger
 import (
 	"context"
 	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestJSONFormatter(t *testing.T) {
@@ -18,3 +22,55 @@ func TestJSONFormatter(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestJSONFormatter_Metadata(t *testing.T) {
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := map[string]struct {
+		node     Node
+		id       string
+		sequence uint64
+		metadata map[string]string
+		want     string
+	}{
+		"formatter-without-metadata": {
+			node: &JSONFormatter{},
+			want: `{"created_at":"2024-01-02T03:04:05Z","event_type":"t","payload":"p"}` + "\n",
+		},
+		"formatter": {
+			node:     &JSONFormatter{},
+			metadata: map[string]string{"tenant": "a", "request-id": "1"},
+			want:     `{"created_at":"2024-01-02T03:04:05Z","event_type":"t","metadata":{"request-id":"1","tenant":"a"},"payload":"p"}` + "\n",
+		},
+		"formatter-filter": {
+			node:     &JSONFormatterFilter{},
+			metadata: map[string]string{"tenant": "a"},
+			want:     `{"created_at":"2024-01-02T03:04:05Z","event_type":"t","metadata":{"tenant":"a"},"payload":"p"}` + "\n",
+		},
+		"formatter-with-id": {
+			node:     &JSONFormatter{},
+			id:       "event-1",
+			sequence: 42,
+			metadata: map[string]string{"tenant": "a"},
+			want:     `{"created_at":"2024-01-02T03:04:05Z","event_type":"t","id":"event-1","sequence":42,"metadata":{"tenant":"a"},"payload":"p"}` + "\n",
+		},
+		"formatter-filter-with-id": {
+			node:     &JSONFormatterFilter{},
+			id:       "event-1",
+			sequence: 42,
+			want:     `{"created_at":"2024-01-02T03:04:05Z","event_type":"t","id":"event-1","sequence":42,"payload":"p"}` + "\n",
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			e := &Event{Type: "t", CreatedAt: createdAt, ID: tc.id, Sequence: tc.sequence, Payload: "p"}
+			for k, v := range tc.metadata {
+				e.SetMetadata(k, v)
+			}
+			_, err := tc.node.Process(context.Background(), e)
+			require.NoError(t, err)
+			got, ok := e.Format(JSONFormat)
+			require.True(t, ok)
+			assert.Equal(t, tc.want, string(got))
+		})
+	}
+}
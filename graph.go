@@ -5,8 +5,11 @@ package eventlogger
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/hashicorp/go-multierror"
 )
@@ -14,6 +17,10 @@ import (
 // graph
 type graph struct {
 
+	// eventType is the EventType, or pattern, which the graph's pipelines are
+	// registered for.
+	eventType EventType
+
 	// roots maps PipelineIDs to pipelineRegistrations.
 	// A registeredPipeline includes the root Node for a pipeline.
 	roots graphMap
@@ -27,146 +34,477 @@ type graph struct {
 	// successThresholdSinks specifies how many sinks must successfully process
 	// an event for Process to not return an error.
 	successThresholdSinks int
+
+	// observer is notified after every call to Node.Process, when not nil.
+	observer Observer
+
+	// pool processes nodes, when not nil.  Otherwise, each node is processed
+	// by its own goroutine.
+	pool *workerPool
+
+	// panicPolicy determines whether panics from nodes are recovered
+	panicPolicy PanicPolicy
+
+	// deadLetterType is the EventType used to send a DeadLetter when a node
+	// fails to process an event, when not empty.
+	deadLetterType EventType
+
+	// admission tracks the events being processed by the graph, so that a
+	// Broker which replaces it can wait for them before closing nodes.
+	admission admission
+}
+
+// clone returns a copy of the graph with its own map of pipelines, so that
+// the copy can be changed without affecting events which are being processed
+// by the original.
+func (g *graph) clone() *graph {
+	c := &graph{
+		eventType:             g.eventType,
+		successThreshold:      g.successThreshold,
+		successThresholdSinks: g.successThresholdSinks,
+		observer:              g.observer,
+		pool:                  g.pool,
+		panicPolicy:           g.panicPolicy,
+		deadLetterType:        g.deadLetterType,
+	}
+	c.roots.copyFrom(&g.roots.snapshotMap)
+	return c
+}
+
+// pipelineRun holds the state of a single Event being processed by a single
+// pipeline.
+type pipelineRun struct {
+	// id of the pipeline
+	id PipelineID
+
+	// eventType (or pattern) which the pipeline is registered for
+	eventType EventType
+
+	// event as it was sent, before being processed by any node, which is a
+	// copy when the graph has a dead-letter EventType (see graph.begin)
+	event *Event
+
+	// ctx is used when processing the pipeline's nodes, it is derived from
+	// the context passed to graph.process and has the pipeline's timeout (if
+	// any) applied.
+	ctx context.Context
+
+	// timeout of the pipeline, if any
+	timeout time.Duration
+
+	// start is when the pipeline started processing the event
+	start time.Time
+
+	// cancel releases the resources of ctx, once every node in the pipeline
+	// has been processed.
+	cancel context.CancelFunc
+
+	// active counts the nodes which are yet to be processed
+	active atomic.Int64
+}
+
+// newPipelineRun creates a pipelineRun for the registered pipeline.
+func newPipelineRun(ctx context.Context, t EventType, id PipelineID, pipeline *registeredPipeline, original *Event) *pipelineRun {
+	run := &pipelineRun{id: id, eventType: t, event: original, ctx: ctx, timeout: pipeline.timeout, start: time.Now()}
+	if pipeline.timeout > 0 {
+		run.ctx, run.cancel = context.WithTimeout(ctx, pipeline.timeout)
+	}
+	return run
+}
+
+// add records that a node is going to be processed.
+func (r *pipelineRun) add() {
+	r.active.Add(1)
+}
+
+// done records that a node has been processed, and releases the run's
+// context once every node has been processed.
+func (r *pipelineRun) done() {
+	if r.active.Add(-1) == 0 && r.cancel != nil {
+		r.cancel()
+	}
+}
+
+// processState collects the Status of a single Event being processed by a
+// graph, as each branch of each pipeline completes.
+type processState struct {
+	// pending counts the nodes which are yet to be processed
+	pending atomic.Int64
+
+	// finished is closed once every node has been processed
+	finished chan struct{}
+
+	// admission of the graph, which is left once finished has been closed
+	admission *admission
+
+	l      sync.Mutex
+	status Status
+
+	// runs are the pipelines which have been started
+	runs []*pipelineRun
+
+	// returned is set once graph.process has returned, after which any
+	// Status is discarded.
+	returned bool
+
+	// eventType (or pattern) which the graph's pipelines are registered for
+	eventType EventType
+
+	// roots are the pipelines the event is being processed by
+	roots []snapshotEntry[PipelineID, *registeredPipeline]
+}
+
+// add records that a node is going to be processed.
+func (s *processState) add() {
+	s.pending.Add(1)
+}
+
+// done records that a node has been processed.  Once every node has been
+// processed, the graph's admission is left so that a Broker which has replaced
+// the graph can close its nodes.
+func (s *processState) done() {
+	if s.pending.Add(-1) == 0 {
+		close(s.finished)
+		s.admission.leave()
+	}
+}
+
+// report adds the Status of a completed branch, unless graph.process has
+// already returned.
+func (s *processState) report(status Status) {
+	s.l.Lock()
+	defer s.l.Unlock()
+	if s.returned {
+		return
+	}
+	s.status.Warnings = append(s.status.Warnings, status.Warnings...)
+	s.status.complete = append(s.status.complete, status.complete...)
+	s.status.completeSinks = append(s.status.completeSinks, status.completeSinks...)
+	s.status.deadLetters = append(s.status.deadLetters, status.deadLetters...)
+	s.status.pipelines = append(s.status.pipelines, status.pipelines...)
+}
+
+// started records that the pipeline has started processing the event.
+func (s *processState) started(run *pipelineRun) {
+	s.l.Lock()
+	defer s.l.Unlock()
+	s.runs = append(s.runs, run)
+}
+
+// result returns the Status collected so far, discarding any reported later.
+// The pipelines which haven't finished, including those which weren't started,
+// are reported as cancelled.
+func (s *processState) result(ctx context.Context) Status {
+	s.l.Lock()
+	defer s.l.Unlock()
+	s.returned = true
+
+	status := s.status
+	runs := make(map[PipelineID]*pipelineRun, len(s.runs))
+	for _, run := range s.runs {
+		runs[run.id] = run
+	}
+	for _, p := range s.roots {
+		r := PipelineResult{EventType: s.eventType, PipelineID: p.key, Outcome: PipelineCancelled, Err: ctx.Err()}
+		run, ok := runs[p.key]
+		switch {
+		case !ok:
+		case run.active.Load() > 0:
+			r.Duration = time.Since(run.start)
+		default:
+			continue
+		}
+		status.pipelines = append(status.pipelines, r)
+	}
+	sortPipelineResults(status.pipelines)
+	return status
 }
 
 // Process the Event by routing it through all of the graph's nodes,
 // starting with the root node.
 func (g *graph) process(ctx context.Context, e *Event) (Status, error) {
-	statusChan := make(chan Status)
-	var wg sync.WaitGroup
-	go func() {
-		g.roots.Range(func(_ PipelineID, pipeline *registeredPipeline) bool {
-			select {
+	original := e
+	if g.deadLetterType != "" {
+		original = e.clone()
+	}
+	if !g.admission.enter() {
+		return Status{}, fmt.Errorf("graph for EventType %s has been replaced", g.eventType)
+	}
+	return g.wait(ctx, g.begin(ctx, e, original))
+}
+
+// begin starts processing the Event, see graph.wait.  The caller must have been
+// admitted to the graph, and the admission is left once every node has been
+// processed rather than when graph.wait returns.  The original Event is
+// the one dead letters refer to, which must be a copy taken before any node
+// could change e when the graph has a dead-letter EventType.  Without a pool,
+// the root nodes are started by a goroutine rather than the caller so that
+// graph.wait returns once ctx is done.  With a pool, the caller hands them to
+// the pool itself, so that the pool bounds the number of goroutines (see
+// SaturationCallerRuns).
+func (g *graph) begin(ctx context.Context, e *Event, original *Event) *processState {
+	roots := g.roots.snapshot().entries
+	state := &processState{finished: make(chan struct{}), admission: &g.admission, eventType: g.eventType, roots: roots}
+
+	// Hold the state open until every root node has been started, so that it
+	// isn't finished by the first pipeline to complete.
+	state.add()
+	start := func() {
+		defer state.done()
+		for _, p := range roots {
 			// Don't continue to start root nodes if our context is already done.
 			// We would just process the node and then drop the status, and no
 			// other linked nodes would be processed.
-			case <-ctx.Done():
-				return false
-			default:
+			if ctx.Err() != nil {
+				break
 			}
 
-			wg.Add(1)
-			g.doProcess(ctx, pipeline.rootNode, e, statusChan, &wg)
-			return true
-		})
-		wg.Wait()
-		close(statusChan)
-	}()
-	var status Status
-	var done bool
-	for !done {
-		select {
-		case <-ctx.Done():
-			done = true
-		case s, ok := <-statusChan:
-			if ok {
-				status.Warnings = append(status.Warnings, s.Warnings...)
-				status.complete = append(status.complete, s.complete...)
-				status.completeSinks = append(status.completeSinks, s.completeSinks...)
-			} else {
-				done = true
+			run := newPipelineRun(ctx, g.eventType, p.key, p.value, original)
+			state.started(run)
+			if g.pool == nil {
+				run.add()
+				state.add()
+				g.doProcess(ctx, run, p.value.rootNode, e, state)
+				continue
 			}
+			g.start(ctx, run, p.value.rootNode, e, state)
 		}
 	}
-	return status, status.getError(ctx.Err(), g.successThreshold, g.successThresholdSinks)
+	// Without a pool, the root nodes are processed in turn by a goroutine.
+	// Otherwise each pipeline is handed to the pool.
+	if g.pool == nil {
+		go start()
+	} else {
+		start()
+	}
+	return state
+}
+
+// wait waits for the Event started by graph.begin to be processed, or for ctx
+// to be done, and returns its Status.
+func (g *graph) wait(ctx context.Context, state *processState) (Status, error) {
+	select {
+	case <-ctx.Done():
+	case <-state.finished:
+	}
+	status := state.result(ctx)
+	err := status.getError(ctx.Err(), g.successThreshold, g.successThresholdSinks)
+	if requiredErr := requiredError(state.roots, status.pipelines); requiredErr != nil {
+		err = errors.Join(err, requiredErr)
+	}
+	return status, err
+}
+
+// start processes the node (and then its children) using the graph's
+// workerPool if any, or a new goroutine otherwise.  When the pool rejects the
+// node, it's reported in the same way as an error from the node.
+func (g *graph) start(ctx context.Context, run *pipelineRun, node *linkedNode, e *Event, state *processState) {
+	run.add()
+	state.add()
+	fn := func() {
+		g.doProcess(ctx, run, node, e, state)
+	}
+
+	if g.pool == nil {
+		go fn()
+		return
+	}
+	if g.pool.run(fn) {
+		return
+	}
+
+	err := fmt.Errorf("%w: not processed in pipeline ID %q", ErrWorkerPoolSaturated, run.id)
+	state.report(failedStatus(ctx, run, node, err, 0))
+	run.done()
+	state.done()
+}
+
+// failedStatus describes the node failing to process the pipelineRun's event
+// with err, after the number of attempts.
+func failedStatus(ctx context.Context, run *pipelineRun, node *linkedNode, err error, attempts int) Status {
+	return Status{
+		Warnings: []error{&NodeError{
+			EventType:         run.event.Type,
+			PipelineEventType: run.eventType,
+			PipelineID:        run.id,
+			NodeID:            node.nodeID,
+			NodeName:          node.name,
+			Err:               err,
+		}},
+		deadLetters: []*DeadLetter{{
+			Event:      run.event,
+			PipelineID: run.id,
+			NodeID:     node.nodeID,
+			Err:        err,
+			Attempts:   attempts,
+		}},
+		pipelines: []PipelineResult{branchResult(ctx, run, node, nil, err)},
+	}
 }
 
 // Recursively process every node in the graph.
 //
-// # No Status is sent when a request is cancelled by the context
+// The ctx is the context passed to graph.process, whereas nodes are processed
+// using the pipelineRun's context which may also have a timeout applied.
+//
+// # No Status is reported once graph.process has returned
 //
-// Status will be sent when we stop processing nodes, which can happen if:
+// Status will be reported when we stop processing nodes, which can happen if:
 //   - a node.Process(...) returns an error, and Status.complete is empty
 //   - a node.Process(...) filters an event, and Status.complete contains the
 //     filter node's ID
 //   - the final node in a pipeline (a sink) finishes, and Status.complete contains
 //     the sink node's ID
-func (g *graph) doProcess(ctx context.Context, node *linkedNode, e *Event, statusChan chan Status, wg *sync.WaitGroup) {
-	defer wg.Done()
+func (g *graph) doProcess(ctx context.Context, run *pipelineRun, node *linkedNode, e *Event, state *processState) {
+	defer state.done()
+	defer run.done()
 
 	// Process the current Node
-	e, err := node.node.Process(ctx, e)
+	e, attempts, err := g.processWithRetry(ctx, run, node, e)
 	if err != nil {
-		select {
-		case <-ctx.Done():
-		case statusChan <- Status{Warnings: []error{err}}:
-		}
+		state.report(failedStatus(ctx, run, node, err, attempts))
 		return
 	}
 
-	completeStatus := Status{complete: []NodeID{node.nodeID}}
+	completeStatus := Status{
+		complete:  []NodeID{node.nodeID},
+		pipelines: []PipelineResult{branchResult(ctx, run, node, e, nil)},
+	}
 	if node.node.Type() == NodeTypeSink {
 		completeStatus.completeSinks = []NodeID{node.nodeID}
 	}
 
 	// If the Event is nil, it has been filtered out and we are done.
 	if e == nil {
-		select {
-		case <-ctx.Done():
-		case statusChan <- completeStatus:
-		}
+		state.report(completeStatus)
 		return
 	}
 
 	// Process any child nodes.  This is depth-first.
-	if len(node.next) != 0 {
-		// If the new Event is nil, it has been filtered out and we are done.
-		if e == nil {
-			statusChan <- Status{}
-			return
-		}
+	if len(node.next) == 0 {
+		state.report(completeStatus)
+		return
+	}
+	// When the graph has a pool, the last child is processed by this
+	// goroutine rather than using another worker.
+	next := node.next
+	if g.pool != nil {
+		next = next[:len(next)-1]
+	}
+	for _, child := range next {
+		g.start(ctx, run, child, e, state)
+	}
+	if g.pool != nil {
+		run.add()
+		state.add()
+		g.doProcess(ctx, run, node.next[len(node.next)-1], e, state)
+	}
+}
 
-		for _, child := range node.next {
-			wg.Add(1)
-			go g.doProcess(ctx, child, e, statusChan, wg)
+// processWithRetry calls processNode, retrying according to the node's
+// RetryPolicy (if any) while the pipelineRun's context allows.  The number of
+// attempts made is returned, along with the result of the final attempt.
+func (g *graph) processWithRetry(ctx context.Context, run *pipelineRun, node *linkedNode, e *Event) (*Event, int, error) {
+	policy := node.settings.retryPolicy
+	for attempt := 1; ; attempt++ {
+		result, err := g.processNode(ctx, run, node, e)
+		if err == nil || policy == nil || attempt >= policy.MaxAttempts || !policy.retryable(err) {
+			return result, attempt, err
 		}
-	} else {
+
+		timer := time.NewTimer(policy.backoff(attempt))
 		select {
-		case <-ctx.Done():
-		case statusChan <- completeStatus:
+		case <-run.ctx.Done():
+			timer.Stop()
+			return nil, attempt, err
+		case <-timer.C:
 		}
 	}
 }
 
-func (g *graph) reopen(ctx context.Context) error {
-	var errors *multierror.Error
+// processNode calls Process on the node using the pipelineRun's context, with
+// the node's timeout (if any) applied. The call is reported to the graph's
+// Observer (if any).
+//
+// When the pipeline or node has a timeout, Process is called in its own
+// goroutine so that a node which doesn't respect its context can be abandoned
+// once the timeout has passed, allowing the rest of the graph to complete.
+// Timeouts are reported as errors wrapping ErrTimeout which identify the node.
+func (g *graph) processNode(ctx context.Context, run *pipelineRun, node *linkedNode, e *Event) (*Event, error) {
+	if g.observer == nil {
+		return g.doProcessNode(ctx, run, node, e)
+	}
 
-	g.roots.Range(func(_ PipelineID, pipeline *registeredPipeline) bool {
-		err := g.doReopen(ctx, pipeline.rootNode)
-		if err != nil {
-			errors = multierror.Append(errors, err)
-		}
-		return true
+	start := time.Now()
+	result, err := g.doProcessNode(ctx, run, node, e)
+	nodeType := node.node.Type()
+	g.observer.ObserveNode(ctx, NodeObservation{
+		EventType:         e.Type,
+		PipelineEventType: run.eventType,
+		PipelineID:        run.id,
+		NodeID:            node.nodeID,
+		NodeName:          node.name,
+		NodeType:          nodeType,
+		Duration:          time.Since(start),
+		Outcome:           nodeOutcome(nodeType, result, err),
+		Err:               err,
 	})
-
-	return errors.ErrorOrNil()
+	return result, err
 }
 
-// Recursively reopen every node in the graph.
-func (g *graph) doReopen(ctx context.Context, node *linkedNode) error {
-	// Process the current Node
-	err := node.node.Reopen()
-	if err != nil {
-		return err
+// doProcessNode calls Process on the node, applying any timeouts.
+func (g *graph) doProcessNode(ctx context.Context, run *pipelineRun, node *linkedNode, e *Event) (*Event, error) {
+	timeout := node.settings.timeout
+	if timeout <= 0 && run.timeout <= 0 {
+		return g.callNode(run.ctx, node, e)
 	}
 
-	// Process any child nodes.  This is depth-first.
-	for _, child := range node.next {
+	nodeCtx := run.ctx
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		nodeCtx, cancel = context.WithTimeout(run.ctx, timeout)
+		defer cancel()
+	}
 
-		err = g.doReopen(ctx, child)
-		if err != nil {
-			return err
+	type result struct {
+		e   *Event
+		err error
+	}
+	// The node may be abandoned below, so it holds its own admission to the
+	// graph until it returns.
+	g.admission.join()
+	resultChan := make(chan result, 1)
+	go func() {
+		defer g.admission.leave()
+		e, err := g.callNode(nodeCtx, node, e)
+		resultChan <- result{e, err}
+	}()
+
+	var r result
+	select {
+	case r = <-resultChan:
+	case <-nodeCtx.Done():
+		r.err = nodeCtx.Err()
+	}
+
+	// Only attribute the error to a timeout when it was ours, rather than the
+	// caller's context ending.
+	if r.err != nil && ctx.Err() == nil && errors.Is(nodeCtx.Err(), context.DeadlineExceeded) {
+		limit, d := "node", timeout
+		if run.ctx.Err() != nil {
+			limit, d = "pipeline", run.timeout
 		}
+		return nil, fmt.Errorf("%w: did not complete in pipeline ID %q within the %s timeout of %s: %w", ErrTimeout, run.id, limit, d, r.err)
 	}
 
-	return nil
+	return r.e, r.err
 }
 
 func (g *graph) validate() error {
 	var errors *multierror.Error
 
 	g.roots.Range(func(_ PipelineID, pipeline *registeredPipeline) bool {
-		err := g.doValidate(nil, pipeline.rootNode)
+		err := g.doValidate(nil, pipeline.rootNode, nil)
 		if err != nil {
 			errors = multierror.Append(errors, err)
 		}
@@ -176,7 +514,14 @@ func (g *graph) validate() error {
 	return errors.ErrorOrNil()
 }
 
-func (g *graph) doValidate(parent, node *linkedNode) error {
+// Recursively validate every branch of the graph.  The ancestors of the node
+// being validated are tracked so that cycles can be detected, callers should
+// pass nil.
+func (g *graph) doValidate(parent, node *linkedNode, ancestors map[*linkedNode]struct{}) error {
+	if _, ok := ancestors[node]; ok {
+		return fmt.Errorf("cycle detected at node ID %q", node.nodeID)
+	}
+
 	isInner := len(node.next) > 0
 
 	switch {
@@ -190,13 +535,21 @@ func (g *graph) doValidate(parent, node *linkedNode) error {
 		return nil
 	}
 
-	// Process any child nodes.  This is depth-first.
+	if ancestors == nil {
+		ancestors = make(map[*linkedNode]struct{})
+	}
+	ancestors[node] = struct{}{}
+	defer delete(ancestors, node)
+
+	// Process any child nodes.  This is depth-first, and every branch is
+	// validated so that all the problems with the graph are reported.
+	var errs []error
 	for _, child := range node.next {
-		err := g.doValidate(node, child)
+		err := g.doValidate(node, child, ancestors)
 		if err != nil {
-			return err
+			errs = append(errs, err)
 		}
 	}
 
-	return nil
+	return errors.Join(errs...)
 }
@@ -5,12 +5,14 @@ package eventlogger
 
 import (
 	"context"
+	"maps"
 	"os"
 	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
@@ -47,10 +49,8 @@ func TestReopen(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	g := graph{}
 	reg := &registeredPipeline{rootNode: root, registrationPolicy: AllowOverwrite}
-	g.roots.Store("id", reg)
-	err = g.reopen(context.Background())
+	err = reopenNodes(context.Background(), reg.nodes(), 1)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -505,3 +505,158 @@ func (s *testActionNode) Process(ctx context.Context, e *Event) (*Event, error)
 
 	return nil, nil
 }
+
+// TestValidate_Edges ensures that every branch of a graph which fans out is
+// validated, and that cycles are detected.
+func TestValidate_Edges(t *testing.T) {
+	nodes := map[NodeID]Node{
+		"filter":    &Filter{Predicate: func(e *Event) (bool, error) { return true, nil }},
+		"formatter": &JSONFormatter{},
+		"sink":      &FileSink{Path: "/path/to/file"},
+		"sink2":     &FileSink{Path: "/path/to/file2"},
+	}
+
+	testcases := map[string]struct {
+		edges   map[NodeID][]NodeID
+		wantErr string
+	}{
+		"good": {
+			edges: map[NodeID][]NodeID{"filter": {"formatter"}, "formatter": {"sink", "sink2"}},
+		},
+		"sink-without-formatter-in-one-branch": {
+			edges:   map[NodeID][]NodeID{"filter": {"formatter", "sink2"}, "formatter": {"sink"}},
+			wantErr: "sink node without preceding formatter or formatter filter",
+		},
+		"every-branch-reported": {
+			edges:   map[NodeID][]NodeID{"filter": {"sink", "sink2"}},
+			wantErr: "sink node without preceding formatter or formatter filter\nsink node without preceding formatter or formatter filter",
+		},
+		"cycle": {
+			edges:   map[NodeID][]NodeID{"filter": {"formatter"}, "formatter": {"sink", "filter2"}, "filter2": {"formatter"}},
+			wantErr: `cycle detected at node ID "formatter"`,
+		},
+	}
+
+	for name, tc := range testcases {
+		t.Run(name, func(t *testing.T) {
+			n := maps.Clone(nodes)
+			n["filter2"] = &Filter{}
+			root, err := linkEdges(n, tc.edges)
+			require.NoError(t, err)
+
+			g := graph{}
+			err = g.doValidate(nil, root, nil)
+			switch tc.wantErr {
+			case "":
+				require.NoError(t, err)
+			default:
+				require.EqualError(t, err, tc.wantErr)
+			}
+		})
+	}
+}
+
+// TestBroker_Timeouts ensures that node and pipeline timeouts abandon a hung
+// node, attribute a timeout warning to it and let other pipelines complete.
+func TestBroker_Timeouts(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		nodeOpts     []Option
+		pipelineOpts []Option
+		wantErr      string
+	}{
+		"node": {
+			nodeOpts: []Option{WithNodeTimeout(20 * time.Millisecond)},
+			wantErr:  `node ID "hung": timeout: did not complete in pipeline ID "slow" within the node timeout of 20ms: context deadline exceeded`,
+		},
+		"pipeline": {
+			pipelineOpts: []Option{WithPipelineTimeout(20 * time.Millisecond)},
+			wantErr:      `node ID "hung": timeout: did not complete in pipeline ID "slow" within the pipeline timeout of 20ms: context deadline exceeded`,
+		},
+		"node-within-pipeline": {
+			nodeOpts:     []Option{WithNodeTimeout(20 * time.Millisecond)},
+			pipelineOpts: []Option{WithPipelineTimeout(time.Minute)},
+			wantErr:      `node ID "hung": timeout: did not complete in pipeline ID "slow" within the node timeout of 20ms: context deadline exceeded`,
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			// The hung node ignores its context, so it can only be abandoned.
+			release := make(chan struct{})
+			t.Cleanup(func() { close(release) })
+			hung := &testActionNode{action: func(context.Context, *Event) (*Event, error) {
+				<-release
+				return nil, nil
+			}}
+
+			b, err := NewBroker()
+			require.NoError(t, err)
+			require.NoError(t, b.RegisterNode("json", &JSONFormatter{}))
+			require.NoError(t, b.RegisterNode("hung", hung, tc.nodeOpts...))
+			require.NoError(t, b.RegisterNode("fast", &testActionNode{}))
+			require.NoError(t, b.RegisterPipeline(Pipeline{
+				PipelineID: "slow",
+				EventType:  "t",
+				NodeIDs:    []NodeID{"json", "hung"},
+			}, tc.pipelineOpts...))
+			require.NoError(t, b.RegisterPipeline(Pipeline{
+				PipelineID: "fast",
+				EventType:  "t",
+				NodeIDs:    []NodeID{"json", "fast"},
+			}))
+			require.NoError(t, b.SetSuccessThresholdSinks("t", 1))
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			status, err := b.Send(ctx, "t", "payload")
+			require.NoError(t, err)
+			assert.Equal(t, []NodeID{"fast"}, status.CompleteSinks())
+			require.Len(t, status.Warnings, 1)
+			assert.ErrorIs(t, status.Warnings[0], ErrTimeout)
+			assert.ErrorIs(t, status.Warnings[0], context.DeadlineExceeded)
+			assert.EqualError(t, status.Warnings[0], tc.wantErr)
+		})
+	}
+}
+
+// TestBroker_Timeouts_ContextAware ensures that a node which respects its
+// context is told about its timeout.
+func TestBroker_Timeouts_ContextAware(t *testing.T) {
+	t.Parallel()
+
+	sink := &testActionNode{action: func(ctx context.Context, _ *Event) (*Event, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}}
+
+	b, err := NewBroker()
+	require.NoError(t, err)
+	require.NoError(t, b.RegisterNode("json", &JSONFormatter{}))
+	require.NoError(t, b.RegisterNode("sink", sink, WithNodeTimeout(10*time.Millisecond)))
+	require.NoError(t, b.RegisterPipeline(Pipeline{
+		PipelineID: "p",
+		EventType:  "t",
+		NodeIDs:    []NodeID{"json", "sink"},
+	}))
+
+	status, err := b.Send(context.Background(), "t", "payload")
+	require.NoError(t, err)
+	require.Len(t, status.Warnings, 1)
+	assert.ErrorIs(t, status.Warnings[0], ErrTimeout)
+}
+
+func TestBroker_Timeouts_Options(t *testing.T) {
+	t.Parallel()
+
+	b, err := NewBroker()
+	require.NoError(t, err)
+	err = b.RegisterNode("n", &JSONFormatter{}, WithNodeTimeout(-1))
+	require.EqualError(t, err, "cannot register node: node timeout cannot be negative: invalid parameter")
+	err = b.RegisterPipeline(Pipeline{PipelineID: "p", EventType: "t", NodeIDs: []NodeID{"n"}}, WithPipelineTimeout(-1))
+	require.EqualError(t, err, "cannot register pipeline: pipeline timeout cannot be negative: invalid parameter")
+}
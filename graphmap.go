@@ -5,14 +5,13 @@ package eventlogger
 
 import (
 	"fmt"
-	"sync"
+	"time"
 )
 
-// TODO: remove this if Go ever introduces sync.Map with generics
-
-// graphMap implements a type-safe synchronized map[PipelineID]*linkedNode
+// graphMap maps PipelineIDs to registered pipelines, using a snapshotMap so
+// that events can be routed through an immutable snapshot of the pipelines.
 type graphMap struct {
-	m sync.Map
+	snapshotMap[PipelineID, *registeredPipeline]
 }
 
 // registeredPipeline represents both linked nodes and the registration policy
@@ -20,37 +19,25 @@ type graphMap struct {
 type registeredPipeline struct {
 	rootNode           *linkedNode
 	registrationPolicy RegistrationPolicy
-}
 
-// Range calls sync.Map.Range
-func (g *graphMap) Range(f func(key PipelineID, value *registeredPipeline) bool) {
-	g.m.Range(func(key, value interface{}) bool {
-		return f(key.(PipelineID), value.(*registeredPipeline))
-	})
-}
+	// timeout for the pipeline to process an event, if any
+	timeout time.Duration
 
-// Store calls sync.Map.Store
-func (g *graphMap) Store(id PipelineID, root *registeredPipeline) {
-	g.m.Store(id, root)
-}
+	// required is set when the pipeline must succeed
+	required bool
 
-// Delete calls sync.Map.Delete
-func (g *graphMap) Delete(id PipelineID) {
-	g.m.Delete(id)
+	// requiredSinks maps the IDs of the sinks which must succeed to the IDs
+	// of the nodes on the way to them (including the sink)
+	requiredSinks map[NodeID]map[NodeID]struct{}
 }
 
 // Nodes returns all the nodes referenced by the specified Pipeline
 func (g *graphMap) Nodes(id PipelineID) ([]NodeID, error) {
-	v, ok := g.m.Load(id)
+	pr, ok := g.Load(id)
 	if !ok {
 		return nil, fmt.Errorf("unable to load root node from underlying data store")
 	}
 
-	pr, ok := v.(*registeredPipeline)
-	if !ok {
-		return nil, fmt.Errorf("unable to retrieve pipeline registration (linked nodes and policy) from underlying data store")
-	}
-
 	nodes := pr.rootNode.flatten()
 	result := make([]NodeID, len(nodes))
 	i := 0
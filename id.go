@@ -0,0 +1,140 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package eventlogger
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/go-secure-stdlib/base62"
+)
+
+// IDGenerator generates the unique IDs of the events created by a Broker (see
+// WithIDGenerator).  NewID is called on the goroutine calling Send, so
+// implementations must be safe for concurrent use.
+type IDGenerator interface {
+	NewID() (string, error)
+}
+
+// IDGeneratorFunc is an adapter which allows an ordinary func to be used as an
+// IDGenerator.
+type IDGeneratorFunc func() (string, error)
+
+// NewID calls f().
+func (f IDGeneratorFunc) NewID() (string, error) {
+	return f()
+}
+
+// WithIDGenerator configures the option that provides an IDGenerator for the
+// Broker, which generates the ID of every Event which doesn't have one after
+// the Broker's ContextExtractors and the options passed to Send (see
+// WithEventID) have been applied.  When no IDGenerator is configured, events
+// only have the IDs they're given.
+func WithIDGenerator(g IDGenerator) Option {
+	return func(o *options) error {
+		if g == nil {
+			return fmt.Errorf("id generator cannot be nil: %w", ErrInvalidParameter)
+		}
+		o.withIDGenerator = g
+		return nil
+	}
+}
+
+// UUIDv4Generator returns an IDGenerator of random (version 4) UUIDs.
+func UUIDv4Generator() IDGenerator {
+	return IDGeneratorFunc(func() (string, error) {
+		var b [16]byte
+		if _, err := rand.Read(b[:]); err != nil {
+			return "", fmt.Errorf("unable to generate id: %w", err)
+		}
+		return formatUUID(b, 4), nil
+	})
+}
+
+// UUIDv7Generator returns an IDGenerator of time-ordered (version 7) UUIDs,
+// which sort by the millisecond they were generated in.
+func UUIDv7Generator() IDGenerator {
+	return IDGeneratorFunc(func() (string, error) {
+		var b [16]byte
+		if err := timestampedRandom(b[:]); err != nil {
+			return "", err
+		}
+		return formatUUID(b, 7), nil
+	})
+}
+
+// formatUUID sets the version and variant bits of b and formats it as a UUID.
+func formatUUID(b [16]byte, version byte) string {
+	b[6] = (b[6] & 0x0f) | version<<4
+	b[8] = (b[8] & 0x3f) | 0x80 // RFC 9562 variant
+
+	buf := make([]byte, 36)
+	hex.Encode(buf[0:8], b[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], b[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], b[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], b[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], b[10:])
+	return string(buf)
+}
+
+// crockford is the Crockford base32 alphabet used by ULIDs.
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// ULIDGenerator returns an IDGenerator of ULIDs, which sort by the millisecond
+// they were generated in.
+func ULIDGenerator() IDGenerator {
+	return IDGeneratorFunc(func() (string, error) {
+		var b [16]byte
+		if err := timestampedRandom(b[:]); err != nil {
+			return "", err
+		}
+
+		// The 128 bits are encoded as 26 characters of 5 bits each, with
+		// the 2 unused bits at the start.
+		buf := make([]byte, 26)
+		for i := range buf {
+			var v byte
+			for j := 0; j < 5; j++ {
+				bit := i*5 + j - 2
+				v <<= 1
+				if bit >= 0 && b[bit/8]&(0x80>>(bit%8)) != 0 {
+					v |= 1
+				}
+			}
+			buf[i] = crockford[v]
+		}
+		return string(buf), nil
+	})
+}
+
+// Base62Generator returns an IDGenerator of random base62 strings of the
+// length, which must be positive.  A length of at least 22 gives as many
+// random bits as a UUID.
+func Base62Generator(length int) (IDGenerator, error) {
+	if length < 1 {
+		return nil, fmt.Errorf("base62 id length must be positive: %w", ErrInvalidParameter)
+	}
+	return IDGeneratorFunc(func() (string, error) {
+		return base62.Random(length)
+	}), nil
+}
+
+// timestampedRandom fills b with the current Unix time in milliseconds, as a
+// big-endian 48 bit integer, followed by random bytes.
+func timestampedRandom(b []byte) error {
+	var ms [8]byte
+	binary.BigEndian.PutUint64(ms[:], uint64(time.Now().UnixMilli()))
+	copy(b[:6], ms[2:])
+	if _, err := rand.Read(b[6:]); err != nil {
+		return fmt.Errorf("unable to generate id: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,197 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package eventlogger
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIDGenerators(t *testing.T) {
+	t.Parallel()
+
+	base62, err := Base62Generator(22)
+	require.NoError(t, err)
+
+	tests := map[string]struct {
+		generator IDGenerator
+		pattern   string
+		// sorted IDs sort by the millisecond they were generated in
+		sorted bool
+	}{
+		"uuidv4": {
+			generator: UUIDv4Generator(),
+			pattern:   `^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`,
+		},
+		"uuidv7": {
+			generator: UUIDv7Generator(),
+			pattern:   `^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`,
+			sorted:    true,
+		},
+		"ulid": {
+			generator: ULIDGenerator(),
+			pattern:   `^[0-7][0-9A-HJKMNP-TV-Z]{25}$`,
+			sorted:    true,
+		},
+		"base62": {
+			generator: base62,
+			pattern:   `^[0-9A-Za-z]{22}$`,
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			seen := make(map[string]struct{})
+			var last string
+			for i := 0; i < 100; i++ {
+				id, err := tc.generator.NewID()
+				require.NoError(t, err)
+				assert.Regexp(t, regexp.MustCompile(tc.pattern), id)
+				assert.NotContains(t, seen, id)
+				seen[id] = struct{}{}
+
+				if tc.sorted && i%10 == 0 {
+					time.Sleep(2 * time.Millisecond)
+					id, err = tc.generator.NewID()
+					require.NoError(t, err)
+					assert.Greater(t, id, last)
+					last = id
+				}
+			}
+		})
+	}
+
+	for _, length := range []int{0, -1} {
+		g, err := Base62Generator(length)
+		require.ErrorIs(t, err, ErrInvalidParameter)
+		assert.Nil(t, g)
+	}
+}
+
+func TestULIDGenerator_Timestamp(t *testing.T) {
+	t.Parallel()
+
+	before := time.Now().UnixMilli()
+	id, err := ULIDGenerator().NewID()
+	require.NoError(t, err)
+	after := time.Now().UnixMilli()
+
+	// The first 10 characters encode the timestamp.
+	var ms int64
+	for _, c := range id[:10] {
+		ms = ms<<5 | int64(indexCrockford(c))
+	}
+	assert.GreaterOrEqual(t, ms, before)
+	assert.LessOrEqual(t, ms, after)
+}
+
+// indexCrockford returns the value of a Crockford base32 character.
+func indexCrockford(c rune) int {
+	for i, d := range crockford {
+		if d == c {
+			return i
+		}
+	}
+	return -1
+}
+
+func TestBroker_IDGenerator(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	var n int
+	got := make(chan *Event, 1)
+	b := newTestBroker(t, map[NodeID]Node{"sink": receivingSink(got)}, []Pipeline{testPipeline}, WithIDGenerator(IDGeneratorFunc(func() (string, error) {
+		n++
+		if n == 3 {
+			return "", errors.New("no more IDs")
+		}
+		return "generated", nil
+	})))
+
+	_, err := b.Send(ctx, "t", "payload")
+	require.NoError(t, err)
+	assert.Equal(t, "generated", (<-got).ID)
+
+	// IDs which are given aren't replaced.
+	_, err = b.SendWithOptions(ctx, "t", "payload", WithEventID("given"))
+	require.NoError(t, err)
+	assert.Equal(t, "given", (<-got).ID)
+
+	_, err = b.Send(ctx, "t", "payload")
+	require.NoError(t, err)
+	assert.Equal(t, "generated", (<-got).ID)
+
+	_, err = b.Send(ctx, "t", "payload")
+	assert.EqualError(t, err, "cannot send event: unable to generate event ID: no more IDs")
+
+	_, err = NewBroker(WithIDGenerator(nil))
+	require.ErrorIs(t, err, ErrInvalidParameter)
+	assert.EqualError(t, err, "cannot create broker: id generator cannot be nil: invalid parameter")
+}
+
+func TestBroker_Sequence(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	b, err := NewBroker()
+	require.NoError(t, err)
+	require.NoError(t, b.RegisterNode("formatter", &JSONFormatter{}))
+
+	var l sync.Mutex
+	sequences := make(map[EventType][]uint64)
+	require.NoError(t, b.RegisterNode("sink", &testActionNode{
+		action: func(_ context.Context, e *Event) (*Event, error) {
+			l.Lock()
+			defer l.Unlock()
+			sequences[e.Type] = append(sequences[e.Type], e.Sequence)
+			return nil, nil
+		},
+	}))
+	for _, et := range []EventType{"a", "b"} {
+		require.NoError(t, b.RegisterPipeline(Pipeline{PipelineID: "p", EventType: et, NodeIDs: []NodeID{"formatter", "sink"}}))
+	}
+
+	// Events which can't be routed don't use a sequence number.
+	_, err = b.Send(ctx, "unknown", "payload")
+	require.Error(t, err)
+
+	const senders, events = 10, 50
+	var wg sync.WaitGroup
+	for i := 0; i < senders; i++ {
+		wg.Add(1)
+		go func(et EventType) {
+			defer wg.Done()
+			for j := 0; j < events; j++ {
+				_, err := b.Send(ctx, et, "payload")
+				assert.NoError(t, err)
+			}
+		}([]EventType{"a", "b"}[i%2])
+	}
+	wg.Wait()
+
+	// Each event type has consecutive sequence numbers, starting at 1.
+	for _, et := range []EventType{"a", "b"} {
+		got := make(map[uint64]struct{})
+		for _, seq := range sequences[et] {
+			got[seq] = struct{}{}
+		}
+		require.Len(t, got, senders/2*events)
+		for seq := uint64(1); seq <= senders/2*events; seq++ {
+			assert.Contains(t, got, seq)
+		}
+	}
+	_, ok := b.sequences.Load(EventType("unknown"))
+	assert.False(t, ok)
+}
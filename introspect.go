@@ -0,0 +1,313 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package eventlogger
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// NodeInfo describes a node registered with a Broker.
+type NodeInfo struct {
+	// ID of the node
+	ID NodeID `json:"id"`
+
+	// Name of the node, if it implements Named
+	Name string `json:"name,omitempty"`
+
+	// Type of the node
+	Type NodeType `json:"type"`
+
+	// ReferenceCount is the number of registered pipelines which use the node
+	ReferenceCount int `json:"reference_count"`
+
+	// RegistrationPolicy the node was registered with
+	RegistrationPolicy RegistrationPolicy `json:"registration_policy"`
+
+	// Timeout the node was registered with, if any
+	Timeout time.Duration `json:"timeout,omitempty"`
+}
+
+// PipelineInfo describes a pipeline registered with a Broker.
+type PipelineInfo struct {
+	// ID of the pipeline
+	ID PipelineID `json:"id"`
+
+	// EventType the pipeline processes
+	EventType EventType `json:"event_type"`
+
+	// NodeIDs of the pipeline in the order they process an event, which is
+	// the order they were registered in for a linear pipeline.  For a pipeline
+	// which fans out into branches, a node is listed once after all of its
+	// parents.
+	NodeIDs []NodeID `json:"node_ids"`
+
+	// Edges of the pipeline, mapping the ID of each node which has children to
+	// the IDs of its children (in order).
+	Edges map[NodeID][]NodeID `json:"edges,omitempty"`
+
+	// RegistrationPolicy the pipeline was registered with
+	RegistrationPolicy RegistrationPolicy `json:"registration_policy"`
+
+	// Timeout the pipeline was registered with, if any
+	Timeout time.Duration `json:"timeout,omitempty"`
+
+	// Required is set when the pipeline must succeed (see
+	// WithRequiredPipeline)
+	Required bool `json:"required,omitempty"`
+
+	// RequiredSinks of the pipeline which must succeed (see
+	// WithRequiredSinks), sorted
+	RequiredSinks []NodeID `json:"required_sinks,omitempty"`
+}
+
+// EventTypeInfo describes an EventType known to a Broker, which has either
+// registered pipelines or configured success thresholds.
+type EventTypeInfo struct {
+	// EventType being described
+	EventType EventType `json:"event_type"`
+
+	// SuccessThreshold of the event type (see Broker.SetSuccessThreshold)
+	SuccessThreshold int `json:"success_threshold"`
+
+	// SuccessThresholdSinks of the event type (see
+	// Broker.SetSuccessThresholdSinks)
+	SuccessThresholdSinks int `json:"success_threshold_sinks"`
+
+	// DeadLetterEventType of the event type, if any (see
+	// Broker.SetDeadLetterEventType)
+	DeadLetterEventType EventType `json:"dead_letter_event_type,omitempty"`
+
+	// Pipelines registered for the event type, sorted by ID
+	Pipelines []PipelineInfo `json:"pipelines"`
+}
+
+// Topology is a point in time description of everything registered with a
+// Broker.
+type Topology struct {
+	// Nodes registered with the Broker, sorted by ID
+	Nodes []NodeInfo `json:"nodes"`
+
+	// EventTypes known to the Broker, sorted
+	EventTypes []EventTypeInfo `json:"event_types"`
+}
+
+// Nodes returns a description of every node registered with the Broker,
+// sorted by ID.
+func (b *Broker) Nodes() []NodeInfo {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+	return b.nodeInfos()
+}
+
+// EventTypes returns the EventTypes known to the Broker (those with registered
+// pipelines or configured success thresholds), sorted.
+func (b *Broker) EventTypes() []EventType {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+
+	types := make([]EventType, 0, len(b.graphs))
+	for t := range b.graphs {
+		types = append(types, t)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+	return types
+}
+
+// Pipelines returns a description of every pipeline registered for the
+// EventType, sorted by ID.
+func (b *Broker) Pipelines(t EventType) []PipelineInfo {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+
+	g, ok := b.graphs[t]
+	if !ok {
+		return nil
+	}
+	return g.pipelineInfos(t)
+}
+
+// Topology returns a description of all the nodes, event types and pipelines
+// registered with the Broker.
+func (b *Broker) Topology() Topology {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+
+	types := make([]EventType, 0, len(b.graphs))
+	for t := range b.graphs {
+		types = append(types, t)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+
+	topology := Topology{
+		Nodes:      b.nodeInfos(),
+		EventTypes: make([]EventTypeInfo, 0, len(types)),
+	}
+	for _, t := range types {
+		g := b.graphs[t]
+		topology.EventTypes = append(topology.EventTypes, EventTypeInfo{
+			EventType:             t,
+			SuccessThreshold:      g.successThreshold,
+			SuccessThresholdSinks: g.successThresholdSinks,
+			DeadLetterEventType:   g.deadLetterType,
+			Pipelines:             g.pipelineInfos(t),
+		})
+	}
+	return topology
+}
+
+// nodeInfos describes the registered nodes, sorted by ID.
+// This function assumes that the caller holds a lock.
+func (b *Broker) nodeInfos() []NodeInfo {
+	infos := make([]NodeInfo, 0, len(b.nodes))
+	for id, n := range b.nodes {
+		infos = append(infos, NodeInfo{
+			ID:                 id,
+			Name:               NewNodeController(n.node).Name(),
+			Type:               n.node.Type(),
+			ReferenceCount:     n.referenceCount,
+			RegistrationPolicy: n.registrationPolicy,
+			Timeout:            n.settings.timeout,
+		})
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].ID < infos[j].ID })
+	return infos
+}
+
+// pipelineInfos describes the pipelines of the graph, sorted by ID.
+func (g *graph) pipelineInfos(t EventType) []PipelineInfo {
+	infos := []PipelineInfo{}
+	g.roots.Range(func(id PipelineID, p *registeredPipeline) bool {
+		info := PipelineInfo{
+			ID:                 id,
+			EventType:          t,
+			RegistrationPolicy: p.registrationPolicy,
+			Timeout:            p.timeout,
+			Required:           p.required,
+			RequiredSinks:      p.requiredSinkIDs(),
+		}
+		for _, l := range p.rootNode.ordered() {
+			info.NodeIDs = append(info.NodeIDs, l.nodeID)
+			for _, child := range l.next {
+				if info.Edges == nil {
+					info.Edges = make(map[NodeID][]NodeID)
+				}
+				info.Edges[l.nodeID] = append(info.Edges[l.nodeID], child.nodeID)
+			}
+		}
+		infos = append(infos, info)
+		return true
+	})
+	sort.Slice(infos, func(i, j int) bool { return infos[i].ID < infos[j].ID })
+	return infos
+}
+
+// ordered returns the linked nodes in topological order, so that every node is
+// listed after all of its parents.  Children are visited in order, so a linear
+// list of nodes is returned as it was linked.
+func (l *linkedNode) ordered() []*linkedNode {
+	parents := make(map[*linkedNode]int)
+	l.walk(func(n *linkedNode) {
+		for _, child := range n.next {
+			parents[child]++
+		}
+	})
+
+	var ordered []*linkedNode
+	queue := []*linkedNode{l}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		ordered = append(ordered, n)
+		for _, child := range n.next {
+			if parents[child]--; parents[child] == 0 {
+				queue = append(queue, child)
+			}
+		}
+	}
+	return ordered
+}
+
+// WriteJSON writes the Topology to w as indented JSON.
+func (t Topology) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(t); err != nil {
+		return fmt.Errorf("unable to write topology as JSON: %w", err)
+	}
+	return nil
+}
+
+// WriteDOT writes the Topology to w as a Graphviz DOT digraph.  Each node is
+// drawn once, labelled with its ID, name (if any) and type, with a shape
+// depending on its type.  Each pipeline is drawn as
+// an entry point (labelled with its event type and ID) linked to its first
+// node, and its edges are labelled with the pipeline's ID.
+func (t Topology) WriteDOT(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "digraph eventlogger {")
+	fmt.Fprintln(bw, "  rankdir=LR;")
+
+	for _, n := range t.Nodes {
+		label := fmt.Sprintf("%s\n(%s)", n.ID, n.Type)
+		if n.Name != "" {
+			label = fmt.Sprintf("%s\n%s\n(%s)", n.ID, n.Name, n.Type)
+		}
+		fmt.Fprintf(bw, "  %s [label=%s, shape=%s];\n", dotID("node", string(n.ID)), strconv.Quote(label), dotShape(n.Type))
+	}
+
+	for _, et := range t.EventTypes {
+		for _, p := range et.Pipelines {
+			entry := dotID("pipeline", string(et.EventType)+"/"+string(p.ID))
+			fmt.Fprintf(bw, "  %s [label=%s, shape=cds];\n", entry, strconv.Quote(fmt.Sprintf("%s\n%s", et.EventType, p.ID)))
+			if len(p.NodeIDs) > 0 {
+				fmt.Fprintf(bw, "  %s -> %s;\n", entry, dotID("node", string(p.NodeIDs[0])))
+			}
+
+			parents := make([]NodeID, 0, len(p.Edges))
+			for parent := range p.Edges {
+				parents = append(parents, parent)
+			}
+			sort.Slice(parents, func(i, j int) bool { return parents[i] < parents[j] })
+			for _, parent := range parents {
+				for _, child := range p.Edges[parent] {
+					fmt.Fprintf(bw, "  %s -> %s [label=%s];\n", dotID("node", string(parent)), dotID("node", string(child)), strconv.Quote(string(p.ID)))
+				}
+			}
+		}
+	}
+
+	fmt.Fprintln(bw, "}")
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("unable to write topology as DOT: %w", err)
+	}
+	return nil
+}
+
+// dotID returns a quoted DOT ID, prefixed by kind so that node and pipeline
+// IDs can't collide.
+func dotID(kind, id string) string {
+	return strconv.Quote(kind + ":" + id)
+}
+
+// dotShape returns the DOT shape used to draw a node of the NodeType.
+func dotShape(t NodeType) string {
+	switch t {
+	case NodeTypeFilter:
+		return "diamond"
+	case NodeTypeFormatter:
+		return "ellipse"
+	case NodeTypeFormatterFilter:
+		return "hexagon"
+	case NodeTypeSink:
+		return "box"
+	default:
+		return "plain"
+	}
+}
@@ -0,0 +1,140 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package eventlogger
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newIntrospectionBroker creates a Broker with a linear "audit/file" pipeline
+// and an "audit/fan-out" pipeline which shares its filter.
+func newIntrospectionBroker(t *testing.T) *Broker {
+	t.Helper()
+	b, err := NewBroker()
+	require.NoError(t, err)
+
+	filter := &Filter{Predicate: func(e *Event) (bool, error) { return true, nil }}
+	require.NoError(t, b.RegisterNode("filter", filter, WithNodeRegistrationPolicy(DenyOverwrite)))
+	require.NoError(t, b.RegisterNode("json", &JSONFormatter{}, WithNodeTimeout(time.Second)))
+	require.NoError(t, b.RegisterNode("file", &FileSink{Path: "/dev/stdout"}))
+	require.NoError(t, b.RegisterNode("json-2", &JSONFormatter{}))
+	require.NoError(t, b.RegisterNode("file-2", &FileSink{Path: t.TempDir()}))
+	require.NoError(t, b.RegisterNode("unused", &JSONFormatter{}))
+
+	require.NoError(t, b.RegisterPipeline(Pipeline{
+		EventType:  "audit",
+		PipelineID: "file",
+		NodeIDs:    []NodeID{"filter", "json", "file"},
+	}, WithPipelineTimeout(time.Minute)))
+	require.NoError(t, b.RegisterPipeline(Pipeline{
+		EventType:  "audit",
+		PipelineID: "fan-out",
+		Edges: map[NodeID][]NodeID{
+			"filter": {"json", "json-2"},
+			"json":   {"file-2"},
+			"json-2": {"file-2"},
+		},
+	}, WithPipelineRegistrationPolicy(DenyOverwrite)))
+	require.NoError(t, b.SetSuccessThresholdSinks("audit", 1))
+	require.NoError(t, b.SetSuccessThreshold("other", 2))
+	return b
+}
+
+func TestBroker_Introspection(t *testing.T) {
+	t.Parallel()
+	b := newIntrospectionBroker(t)
+
+	assert.Equal(t, []EventType{"audit", "other"}, b.EventTypes())
+
+	nodes := b.Nodes()
+	require.Len(t, nodes, 6)
+	assert.Equal(t, NodeInfo{ID: "file", Name: "sink:/dev/stdout", Type: NodeTypeSink, ReferenceCount: 1, RegistrationPolicy: AllowOverwrite}, nodes[0])
+	// The filter doesn't have a name.
+	assert.Equal(t, NodeInfo{ID: "filter", Type: NodeTypeFilter, ReferenceCount: 2, RegistrationPolicy: DenyOverwrite}, nodes[2])
+	assert.Equal(t, NodeInfo{ID: "json", Name: "JSONFormatter", Type: NodeTypeFormatter, ReferenceCount: 2, RegistrationPolicy: AllowOverwrite, Timeout: time.Second}, nodes[3])
+	assert.Equal(t, NodeInfo{ID: "unused", Name: "JSONFormatter", Type: NodeTypeFormatter, RegistrationPolicy: AllowOverwrite}, nodes[5])
+
+	assert.Equal(t, []PipelineInfo{
+		{
+			ID:        "fan-out",
+			EventType: "audit",
+			NodeIDs:   []NodeID{"filter", "json", "json-2", "file-2"},
+			Edges: map[NodeID][]NodeID{
+				"filter": {"json", "json-2"},
+				"json":   {"file-2"},
+				"json-2": {"file-2"},
+			},
+			RegistrationPolicy: DenyOverwrite,
+		},
+		{
+			ID:        "file",
+			EventType: "audit",
+			NodeIDs:   []NodeID{"filter", "json", "file"},
+			Edges: map[NodeID][]NodeID{
+				"filter": {"json"},
+				"json":   {"file"},
+			},
+			RegistrationPolicy: AllowOverwrite,
+			Timeout:            time.Minute,
+		},
+	}, b.Pipelines("audit"))
+	assert.Empty(t, b.Pipelines("other"))
+	assert.Nil(t, b.Pipelines("missing"))
+
+	topology := b.Topology()
+	assert.Equal(t, nodes, topology.Nodes)
+	require.Len(t, topology.EventTypes, 2)
+	assert.Equal(t, EventType("audit"), topology.EventTypes[0].EventType)
+	assert.Equal(t, 1, topology.EventTypes[0].SuccessThresholdSinks)
+	assert.Len(t, topology.EventTypes[0].Pipelines, 2)
+	assert.Equal(t, EventTypeInfo{EventType: "other", SuccessThreshold: 2, Pipelines: []PipelineInfo{}}, topology.EventTypes[1])
+}
+
+func TestTopology_WriteJSON(t *testing.T) {
+	t.Parallel()
+	b := newIntrospectionBroker(t)
+	want := b.Topology()
+
+	var buf bytes.Buffer
+	require.NoError(t, want.WriteJSON(&buf))
+	assert.Contains(t, buf.String(), `"type": "formatter"`)
+
+	var got Topology
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &got))
+	assert.Equal(t, want, got)
+}
+
+func TestTopology_WriteDOT(t *testing.T) {
+	t.Parallel()
+	b, err := NewBroker()
+	require.NoError(t, err)
+	require.NoError(t, b.RegisterNode("json", &JSONFormatter{}))
+	require.NoError(t, b.RegisterNode("file", &FileSink{Path: "/dev/stdout"}))
+	require.NoError(t, b.RegisterNode("filter", &Filter{Predicate: func(e *Event) (bool, error) { return true, nil }}))
+	require.NoError(t, b.RegisterPipeline(Pipeline{
+		EventType:  "audit",
+		PipelineID: "file",
+		NodeIDs:    []NodeID{"filter", "json", "file"},
+	}))
+
+	var buf bytes.Buffer
+	require.NoError(t, b.Topology().WriteDOT(&buf))
+	assert.Equal(t, `digraph eventlogger {
+  rankdir=LR;
+  "node:file" [label="file\nsink:/dev/stdout\n(sink)", shape=box];
+  "node:filter" [label="filter\n(filter)", shape=diamond];
+  "node:json" [label="json\nJSONFormatter\n(formatter)", shape=ellipse];
+  "pipeline:audit/file" [label="audit\nfile", shape=cds];
+  "pipeline:audit/file" -> "node:filter";
+  "node:filter" -> "node:json" [label="file"];
+  "node:json" -> "node:file" [label="file"];
+}
+`, buf.String())
+}
@@ -0,0 +1,172 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package eventlogger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// Opener is an optional interface for a Node which needs to acquire resources,
+// such as opening a file, before it processes events.  The Broker opens a node
+// once, when it's first referenced by a registered pipeline, and the
+// pipeline isn't registered if it fails to open.
+type Opener interface {
+	Open(ctx context.Context) error
+}
+
+// HealthChecker is an optional interface for a Node which can report whether
+// it's able to process events, such as whether its file is still writable.
+// Health returns nil when the node is healthy.
+type HealthChecker interface {
+	Health(ctx context.Context) error
+}
+
+// Open the Node if it implements the Opener interface, and if required use the
+// NodeUnwrapper interface to unwrap it before opening it.
+func (nc *NodeController) Open(ctx context.Context) error {
+	if o, ok := unwrapNode[Opener](nc.n); ok {
+		return o.Open(ctx)
+	}
+	return nil
+}
+
+// Health checks the health of the Node if it implements the HealthChecker
+// interface, and if required use the NodeUnwrapper interface to unwrap it
+// before checking it.  Nodes which don't implement it are healthy.
+func (nc *NodeController) Health(ctx context.Context) error {
+	if h, ok := unwrapNode[HealthChecker](nc.n); ok {
+		return h.Health(ctx)
+	}
+	return nil
+}
+
+// unwrapNode returns the Node as T, using the NodeUnwrapper interface to unwrap
+// it until it implements T.
+func unwrapNode[T any](n Node) (T, bool) {
+	for {
+		if t, ok := n.(T); ok {
+			return t, true
+		}
+		u, ok := n.(NodeUnwrapper)
+		if !ok {
+			var zero T
+			return zero, false
+		}
+		n = u.Unwrap()
+	}
+}
+
+// Health checks the health of every node referenced by a registered pipeline,
+// using the HealthChecker interface, so that it can be used for readiness
+// probes.  The nodes are checked concurrently and the errors of the unhealthy
+// nodes are aggregated (as multierror.Error) in node ID order, each
+// identifying its node.  Nodes which haven't been checked when ctx is done are
+// unhealthy, with the context's error.  The nodes are checked without holding
+// the Broker's lock, so that a slow check doesn't block registering nodes and
+// pipelines.
+func (b *Broker) Health(ctx context.Context) error {
+	b.lock.RLock()
+	if b.closed {
+		b.lock.RUnlock()
+		return fmt.Errorf("cannot check health: %w", ErrBrokerClosed)
+	}
+	nodes := make(map[NodeID]Node, len(b.nodes))
+	for id, usage := range b.nodes {
+		if usage.referenceCount > 0 {
+			nodes[id] = usage.node
+		}
+	}
+	b.lock.RUnlock()
+
+	if len(nodes) == 0 {
+		return nil
+	}
+	return forEachNode(ctx, nodes, len(nodes), "node ID %q is unhealthy: %w", func(nc *NodeController) error {
+		return nc.Health(ctx)
+	})
+}
+
+// WithOpenContext configures the option that determines the context used to
+// open the nodes of a pipeline when it's registered (default:
+// context.Background()), see Opener.
+func WithOpenContext(ctx context.Context) Option {
+	return func(o *options) error {
+		if ctx == nil {
+			return fmt.Errorf("open context cannot be nil: %w", ErrInvalidParameter)
+		}
+		o.withOpenContext = ctx
+		return nil
+	}
+}
+
+// unopenedNodes returns the usage of the nodes which haven't been opened yet.
+// This function assumes that the caller holds a lock.
+func (r *registry) unopenedNodes(nodes map[NodeID]Node) map[NodeID]*nodeUsage {
+	unopened := make(map[NodeID]*nodeUsage)
+	for id := range nodes {
+		if usage, ok := r.nodes[id]; ok && !usage.opened {
+			unopened[id] = usage
+		}
+	}
+	return unopened
+}
+
+// openNodes opens the nodes, in node ID order, and returns those which were
+// opened.  It stops at the first node which fails to open, without closing
+// those which were opened (see keepOpened).  It doesn't mark the nodes as
+// opened (see markOpened), so the caller needn't hold a lock, but it must hold
+// the Broker's writeLock so that the nodes aren't changed meanwhile.
+func openNodes(ctx context.Context, nodes map[NodeID]*nodeUsage) (map[NodeID]*nodeUsage, error) {
+	ids := make([]NodeID, 0, len(nodes))
+	for id := range nodes {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	opened := make(map[NodeID]*nodeUsage, len(ids))
+	for _, id := range ids {
+		if err := NewNodeController(nodes[id].node).Open(ctx); err != nil {
+			return opened, fmt.Errorf("unable to open node ID %q: %w", id, err)
+		}
+		opened[id] = nodes[id]
+	}
+	return opened, nil
+}
+
+// keepOpened handles the nodes which openNodes opened before another node
+// failed to open.  Those which are registered stay open, and are marked as
+// opened so that they're closed along with the other registered nodes rather
+// than being left registered but closed.  The others, which were only
+// registered by a Transaction that failed, are closed.
+// This function assumes that the caller holds a lock.
+func (r *registry) keepOpened(ctx context.Context, opened map[NodeID]*nodeUsage) error {
+	ids := make([]NodeID, 0, len(opened))
+	for id := range opened {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	var err error
+	for _, id := range ids {
+		if usage, ok := r.nodes[id]; ok && usage.node == opened[id].node {
+			usage.opened = true
+			continue
+		}
+		if closeErr := NewNodeController(opened[id].node).Close(ctx); closeErr != nil {
+			err = errors.Join(err, fmt.Errorf("unable to close node ID %q: %w", id, closeErr))
+		}
+	}
+	return err
+}
+
+// markOpened records that the nodes have been opened by openNodes.
+// This function assumes that the caller holds a lock.
+func markOpened(nodes map[NodeID]*nodeUsage) {
+	for _, usage := range nodes {
+		usage.opened = true
+	}
+}
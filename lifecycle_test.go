@@ -0,0 +1,225 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package eventlogger
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// lifecycleNode is a sink which counts how many times it's opened and closed,
+// and optionally fails to open or is unhealthy.
+type lifecycleNode struct {
+	testActionNode
+	opened    atomic.Int32
+	closed    atomic.Int32
+	openErr   error
+	healthErr error
+	ctx       context.Context
+}
+
+func (n *lifecycleNode) Open(ctx context.Context) error {
+	n.ctx = ctx
+	if n.openErr != nil {
+		return n.openErr
+	}
+	n.opened.Add(1)
+	return nil
+}
+
+func (n *lifecycleNode) Close(context.Context) error {
+	n.closed.Add(1)
+	return nil
+}
+
+func (n *lifecycleNode) Health(context.Context) error {
+	return n.healthErr
+}
+
+func TestBroker_RegisterPipeline_Open(t *testing.T) {
+	t.Parallel()
+
+	b, err := NewBroker()
+	require.NoError(t, err)
+	a, bad := &lifecycleNode{}, &lifecycleNode{openErr: errors.New("bad open")}
+	// A circuit breaker is unwrapped to open the node it wraps.
+	wrapped := &lifecycleNode{}
+	cb, err := NewCircuitBreaker(wrapped, 1, time.Second)
+	require.NoError(t, err)
+	require.NoError(t, b.RegisterNode("formatter", &JSONFormatter{}))
+	require.NoError(t, b.RegisterNode("a", a))
+	require.NoError(t, b.RegisterNode("bad", bad))
+	require.NoError(t, b.RegisterNode("wrapped", cb))
+
+	require.NoError(t, b.RegisterPipeline(Pipeline{PipelineID: "p1", EventType: "t", NodeIDs: []NodeID{"formatter", "a"}}))
+	assert.Equal(t, int32(1), a.opened.Load())
+	assert.Equal(t, int32(0), wrapped.opened.Load())
+
+	// Nodes are only opened when they're first referenced.
+	require.NoError(t, b.RegisterPipeline(Pipeline{PipelineID: "p2", EventType: "t", Edges: map[NodeID][]NodeID{"formatter": {"a", "wrapped"}}}))
+	assert.Equal(t, int32(1), a.opened.Load())
+	assert.Equal(t, int32(1), wrapped.opened.Load())
+
+	// "a2" is opened before "bad" fails, so the pipeline isn't registered but
+	// "a2" stays open, since it's still registered.
+	a2 := &lifecycleNode{}
+	require.NoError(t, b.RegisterNode("a2", a2))
+	err = b.RegisterPipeline(Pipeline{PipelineID: "p3", EventType: "other", Edges: map[NodeID][]NodeID{"formatter": {"a2", "bad"}}})
+	assert.EqualError(t, err, `cannot register pipeline: unable to open node ID "bad": bad open`)
+	assert.Equal(t, int32(1), a2.opened.Load())
+	assert.Equal(t, int32(0), a2.closed.Load())
+	assert.False(t, b.IsAnyPipelineRegistered("other"))
+
+	// It isn't opened again, and it's closed along with the broker.
+	require.NoError(t, b.RegisterPipeline(Pipeline{PipelineID: "p3", EventType: "other", NodeIDs: []NodeID{"formatter", "a2"}}))
+	assert.Equal(t, int32(1), a2.opened.Load())
+	require.NoError(t, b.Close(context.Background()))
+	assert.Equal(t, int32(1), a2.closed.Load())
+}
+
+// slowOpener is a sink which blocks in Open until it's released.
+type slowOpener struct {
+	lifecycleNode
+	started chan struct{}
+	release chan struct{}
+}
+
+func (n *slowOpener) Open(ctx context.Context) error {
+	n.ctx = ctx
+	close(n.started)
+	<-n.release
+	return nil
+}
+
+func TestBroker_RegisterPipeline_OpenContext(t *testing.T) {
+	t.Parallel()
+
+	type ctxKey struct{}
+	ctx := context.WithValue(context.Background(), ctxKey{}, "value")
+
+	b, err := NewBroker()
+	require.NoError(t, err)
+	node := &slowOpener{started: make(chan struct{}), release: make(chan struct{})}
+	require.NoError(t, b.RegisterNode("formatter", &JSONFormatter{}))
+	require.NoError(t, b.RegisterNode("slow", node))
+
+	var nilCtx context.Context
+	err = b.RegisterPipeline(Pipeline{PipelineID: "p", EventType: "t", NodeIDs: []NodeID{"formatter", "slow"}}, WithOpenContext(nilCtx))
+	assert.ErrorIs(t, err, ErrInvalidParameter)
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- b.RegisterPipeline(Pipeline{PipelineID: "p", EventType: "t", NodeIDs: []NodeID{"formatter", "slow"}}, WithOpenContext(ctx))
+	}()
+	<-node.started
+
+	// The registry can be read while the node is being opened.
+	require.NoError(t, b.Health(context.Background()))
+	assert.Len(t, b.Nodes(), 2)
+	assert.False(t, b.IsAnyPipelineRegistered("t"))
+
+	close(node.release)
+	require.NoError(t, <-errs)
+	assert.True(t, b.IsAnyPipelineRegistered("t"))
+	assert.Equal(t, "value", node.ctx.Value(ctxKey{}))
+}
+
+func TestTransaction_Commit_Open(t *testing.T) {
+	t.Parallel()
+
+	type ctxKey struct{}
+	ctx := context.WithValue(context.Background(), ctxKey{}, "value")
+
+	b, err := NewBroker()
+	require.NoError(t, err)
+	require.NoError(t, b.RegisterNode("formatter", &JSONFormatter{}))
+
+	registered := &lifecycleNode{}
+	require.NoError(t, b.RegisterNode("a0", registered))
+
+	node, bad := &lifecycleNode{}, &lifecycleNode{openErr: errors.New("bad open")}
+	tx := b.Begin()
+	require.NoError(t, tx.RegisterNode("a", node))
+	require.NoError(t, tx.RegisterNode("bad", bad))
+	require.NoError(t, tx.RegisterPipeline(Pipeline{PipelineID: "p", EventType: "t", Edges: map[NodeID][]NodeID{"formatter": {"bad", "a", "a0"}}}))
+	err = tx.Commit(ctx)
+	assert.EqualError(t, err, `eventlogger.(Transaction).Commit: unable to open node ID "bad": bad open`)
+	assert.False(t, b.IsAnyPipelineRegistered("t"))
+	// The node registered by the transaction is closed, whereas the node which
+	// was already registered stays open.
+	assert.Equal(t, int32(1), node.closed.Load())
+	assert.Equal(t, int32(1), registered.opened.Load())
+	assert.Equal(t, int32(0), registered.closed.Load())
+
+	tx = b.Begin()
+	require.NoError(t, tx.RegisterNode("a", node))
+	require.NoError(t, tx.RegisterPipeline(Pipeline{PipelineID: "p", EventType: "t", NodeIDs: []NodeID{"formatter", "a"}}))
+	require.NoError(t, tx.Commit(ctx))
+	assert.True(t, b.IsAnyPipelineRegistered("t"))
+	assert.Equal(t, int32(2), node.opened.Load())
+	assert.Equal(t, "value", node.ctx.Value(ctxKey{}))
+}
+
+func TestBroker_Health(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	b, err := NewBroker()
+	require.NoError(t, err)
+	require.NoError(t, b.RegisterNode("formatter", &JSONFormatter{}))
+	require.NoError(t, b.RegisterNode("a", &lifecycleNode{}))
+	require.NoError(t, b.RegisterNode("b", &lifecycleNode{healthErr: errors.New("b failed")}))
+	require.NoError(t, b.RegisterNode("c", &lifecycleNode{healthErr: errors.New("c failed")}))
+	require.NoError(t, b.RegisterNode("unused", &lifecycleNode{healthErr: errors.New("unused failed")}))
+
+	require.NoError(t, b.Health(ctx))
+
+	require.NoError(t, b.RegisterPipeline(Pipeline{PipelineID: "p", EventType: "t", Edges: map[NodeID][]NodeID{"formatter": {"a", "b", "c"}}}))
+	err = b.Health(ctx)
+	assert.EqualError(t, err, "2 errors occurred:\n"+
+		"\t* node ID \"b\" is unhealthy: b failed\n"+
+		"\t* node ID \"c\" is unhealthy: c failed\n\n")
+
+	require.NoError(t, b.Close(ctx))
+	assert.ErrorIs(t, b.Health(ctx), ErrBrokerClosed)
+}
+
+// slowHealthChecker is a sink which blocks in Health until it's released.
+type slowHealthChecker struct {
+	testActionNode
+	started chan struct{}
+	release chan struct{}
+}
+
+func (n *slowHealthChecker) Health(context.Context) error {
+	n.started <- struct{}{}
+	<-n.release
+	return nil
+}
+
+func TestBroker_Health_Unlocked(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	node := &slowHealthChecker{started: make(chan struct{}), release: make(chan struct{})}
+	b := newTestBroker(t, map[NodeID]Node{"sink": node}, []Pipeline{testPipeline})
+
+	checked := make(chan error)
+	go func() {
+		checked <- b.Health(ctx)
+	}()
+	<-node.started
+
+	// The registry can be changed while a node is being checked.
+	require.NoError(t, b.RegisterNode("other", &testActionNode{}))
+
+	close(node.release)
+	require.NoError(t, <-checked)
+}
@@ -6,6 +6,8 @@ package eventlogger
 import (
 	"context"
 	"fmt"
+	"sort"
+	"time"
 )
 
 // NodeType defines the possible Node type's in the system.
@@ -19,6 +21,39 @@ const (
 	NodeTypeFormatterFilter // A node that formats and then filters the events based on the new format.
 )
 
+// String returns a representation of the NodeType, suitable for use in
+// metrics labels and diagnostics.
+func (t NodeType) String() string {
+	switch t {
+	case NodeTypeFilter:
+		return "filter"
+	case NodeTypeFormatter:
+		return "formatter"
+	case NodeTypeSink:
+		return "sink"
+	case NodeTypeFormatterFilter:
+		return "formatter-filter"
+	default:
+		return fmt.Sprintf("NodeType(%d)", int(t))
+	}
+}
+
+// MarshalText encodes the NodeType using its String representation.
+func (t NodeType) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+// UnmarshalText decodes a NodeType from its String representation.
+func (t *NodeType) UnmarshalText(text []byte) error {
+	for _, nt := range []NodeType{NodeTypeFilter, NodeTypeFormatter, NodeTypeSink, NodeTypeFormatterFilter} {
+		if nt.String() == string(text) {
+			*t = nt
+			return nil
+		}
+	}
+	return fmt.Errorf("%q is not a valid node type: %w", text, ErrInvalidParameter)
+}
+
 // A Node in a graph
 type Node interface {
 	// Process does something with the Event: filter, redaction,
@@ -45,7 +80,10 @@ type NodeController struct {
 // If the Node implements any of the following methods, the NodeController will
 // call them as appropriate/needed:
 //
-//	Close() error
+//	Close(ctx context.Context) error
+//	Open(ctx context.Context) error
+//	Health(ctx context.Context) error
+//	Name() string
 func NewNodeController(n Node) *NodeController {
 	// intentionally not checking the Node for nil.. the caller must ensure it's
 	// valid and the docs make that clear.
@@ -63,6 +101,25 @@ type Closer interface {
 	Close(ctx context.Context) error
 }
 
+// Named is an optional interface for a Node which can describe itself, such as
+// "sink:/var/log/audit", so that operators can identify it.  When a Node
+// implements it (or unwraps to one that does, see NodeUnwrapper), its name is
+// included in warnings, Status, NodeObservation and the Broker's Topology
+// alongside its NodeID.
+type Named interface {
+	Name() string
+}
+
+// Name returns the name of the Node if it implements the Named interface, and
+// if required use the NodeUnwrapper interface to unwrap it first.  It returns
+// "" otherwise.
+func (nc *NodeController) Name() string {
+	if n, ok := unwrapNode[Named](nc.n); ok {
+		return n.Name()
+	}
+	return ""
+}
+
 // Close the Node if it implements the Closer interface, and if required use the
 // NodeUnwrapper interface to unwrap it before closing it.
 func (nc *NodeController) Close(ctx context.Context) error {
@@ -80,9 +137,23 @@ func (nc *NodeController) Close(ctx context.Context) error {
 }
 
 type linkedNode struct {
-	node   Node
-	nodeID NodeID
-	next   []*linkedNode
+	node     Node
+	nodeID   NodeID
+	next     []*linkedNode
+	settings nodeSettings
+
+	// name of the node, see Named
+	name string
+}
+
+// nodeSettings are the options which apply to a Node whenever it's processed,
+// configured when the Node is registered with the Broker.
+type nodeSettings struct {
+	// timeout for the Node to process an event, if any
+	timeout time.Duration
+
+	// retryPolicy for the Node, if any
+	retryPolicy *RetryPolicy
 }
 
 // linkNodes is a convenience function that connects Nodes together into a linked list.
@@ -130,6 +201,108 @@ func linkNodesAndSinks(inner, sinks []Node, nodeIDs, sinkIDs []NodeID) (*linkedN
 	return root, nil
 }
 
+// linkEdges is a convenience function that connects Nodes together into a
+// graph using edges, which map the ID of a node to the IDs of its children.
+// The returned root is the only node which is not a child of another node.
+// Nodes with more than one parent are shared, rather than copied, and the
+// graph is not checked for cycles (see graph.doValidate).
+func linkEdges(nodes map[NodeID]Node, edges map[NodeID][]NodeID) (*linkedNode, error) {
+	if len(edges) == 0 {
+		return nil, fmt.Errorf("no edges given")
+	}
+
+	linked := make(map[NodeID]*linkedNode)
+	link := func(id NodeID) (*linkedNode, error) {
+		if l, ok := linked[id]; ok {
+			return l, nil
+		}
+		n, ok := nodes[id]
+		if !ok {
+			return nil, fmt.Errorf("node ID %q not given", id)
+		}
+		l := &linkedNode{node: n, nodeID: id}
+		linked[id] = l
+		return l, nil
+	}
+
+	// Sort the parents so that nodes are linked (and errors reported) in a
+	// predictable order.
+	parents := make([]NodeID, 0, len(edges))
+	for id := range edges {
+		parents = append(parents, id)
+	}
+	sort.Slice(parents, func(i, j int) bool { return parents[i] < parents[j] })
+
+	children := make(map[NodeID]struct{})
+	for _, id := range parents {
+		parent, err := link(id)
+		if err != nil {
+			return nil, err
+		}
+		seen := make(map[NodeID]struct{}, len(edges[id]))
+		for _, childID := range edges[id] {
+			if _, ok := seen[childID]; ok {
+				return nil, fmt.Errorf("node ID %q is linked to node ID %q more than once", id, childID)
+			}
+			seen[childID] = struct{}{}
+			child, err := link(childID)
+			if err != nil {
+				return nil, err
+			}
+			parent.next = append(parent.next, child)
+			children[childID] = struct{}{}
+		}
+	}
+
+	var roots []NodeID
+	for _, id := range parents {
+		if _, ok := children[id]; !ok {
+			roots = append(roots, id)
+		}
+	}
+
+	switch {
+	case len(roots) == 0:
+		return nil, fmt.Errorf("no root node, every node is a child of another node")
+	case len(roots) > 1:
+		return nil, fmt.Errorf("multiple root nodes: %q", roots)
+	}
+
+	root := linked[roots[0]]
+	if reachable := root.flatten(); len(reachable) != len(linked) {
+		var unreachable []NodeID
+		for id := range linked {
+			if _, ok := reachable[id]; !ok {
+				unreachable = append(unreachable, id)
+			}
+		}
+		sort.Slice(unreachable, func(i, j int) bool { return unreachable[i] < unreachable[j] })
+		return nil, fmt.Errorf("nodes not reachable from root node ID %q: %q", root.nodeID, unreachable)
+	}
+
+	return root, nil
+}
+
+// walk visits every linked node once, even when nodes are shared by several
+// branches.
+func (l *linkedNode) walk(fn func(*linkedNode)) {
+	stack := []*linkedNode{l}
+	visited := make(map[*linkedNode]struct{})
+
+	for len(stack) > 0 {
+		node := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		if _, ok := visited[node]; ok {
+			continue
+		}
+		visited[node] = struct{}{}
+		fn(node)
+
+		stack = append(stack, node.next...)
+	}
+}
+
 // flatten will attempt to visit every linked node and flatten the overall set of node IDs.
 func (l *linkedNode) flatten() map[NodeID]struct{} {
 	stack := []*linkedNode{l}
@@ -0,0 +1,368 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package eventlogger
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// NodeSettingType defines the type of value accepted by a NodeSetting.
+type NodeSettingType string
+
+const (
+	NodeSettingString   NodeSettingType = "string"   // NodeSettingString accepts a string
+	NodeSettingInt      NodeSettingType = "int"      // NodeSettingInt accepts a whole number
+	NodeSettingBool     NodeSettingType = "bool"     // NodeSettingBool accepts a bool
+	NodeSettingDuration NodeSettingType = "duration" // NodeSettingDuration accepts a time.Duration or a string such as "10s"
+	NodeSettingValue    NodeSettingType = "value"    // NodeSettingValue accepts any value, which is checked by the NodeFactory
+)
+
+// NodeSetting describes a setting accepted by a NodeFactory.
+type NodeSetting struct {
+	// Name of the setting
+	Name string
+
+	// Type of value the setting accepts
+	Type NodeSettingType
+
+	// Required is true when the setting must be given
+	Required bool
+
+	// Description of the setting
+	Description string
+}
+
+// NodeFactory creates nodes of a named type from a generic map of settings, so
+// that nodes can be constructed by configuration-driven tooling (see
+// RegisterNodeFactory).
+type NodeFactory struct {
+	// Description of the type of node
+	Description string
+
+	// Settings accepted by New, which is the settings schema of the type of
+	// node.  NewNodeFromSettings rejects settings which aren't listed, are
+	// missing when required or have the wrong type of value before calling
+	// New.
+	Settings []NodeSetting
+
+	// New creates a node from its settings.  The Broker the node will be
+	// registered with is provided for nodes which send events, and may be nil.
+	// Problems with a particular setting should be reported as a
+	// *NodeSettingError.
+	New func(b *Broker, settings NodeSettings) (Node, error)
+}
+
+// NodeSettingError describes a problem with one of the settings given to a
+// NodeFactory.
+type NodeSettingError struct {
+	// Setting which has the problem
+	Setting string
+
+	// Err describing the problem
+	Err error
+}
+
+// Error returns the name and description of the problem with the setting.
+func (e *NodeSettingError) Error() string {
+	return fmt.Sprintf("setting %q: %s", e.Setting, e.Err)
+}
+
+// Unwrap returns the description of the problem.
+func (e *NodeSettingError) Unwrap() error {
+	return e.Err
+}
+
+// NewNodeSettingError creates a NodeSettingError for the setting, using a
+// formatted description.
+func NewNodeSettingError(setting string, format string, a ...interface{}) *NodeSettingError {
+	return &NodeSettingError{Setting: setting, Err: fmt.Errorf(format, a...)}
+}
+
+// NodeSettings are the settings given to a NodeFactory.  Its accessors return
+// the zero value for settings which weren't given, and otherwise rely on the
+// settings having been checked against the NodeFactory's schema by
+// NewNodeFromSettings.
+type NodeSettings map[string]interface{}
+
+// String returns the named string setting.
+func (s NodeSettings) String(name string) string {
+	v, _ := s[name].(string)
+	return v
+}
+
+// Int returns the named int setting.
+func (s NodeSettings) Int(name string) int {
+	i, _ := settingInt(s[name])
+	return i
+}
+
+// Bool returns the named bool setting.
+func (s NodeSettings) Bool(name string) bool {
+	v, _ := s[name].(bool)
+	return v
+}
+
+// Duration returns the named duration setting.
+func (s NodeSettings) Duration(name string) time.Duration {
+	d, _ := settingDuration(s[name])
+	return d
+}
+
+// settingInt converts the value of an int setting, which may have been decoded
+// from JSON as a float64 or json.Number.
+func settingInt(v interface{}) (int, bool) {
+	switch n := v.(type) {
+	case int:
+		return n, true
+	case int32:
+		return int(n), true
+	case int64:
+		return int(n), true
+	case float64:
+		if n != math.Trunc(n) || n > math.MaxInt || n < math.MinInt {
+			return 0, false
+		}
+		return int(n), true
+	case json.Number:
+		i, err := strconv.Atoi(string(n))
+		return i, err == nil
+	default:
+		return 0, false
+	}
+}
+
+// settingDuration converts the value of a duration setting, which may be a
+// time.Duration or a string such as "10s".
+func settingDuration(v interface{}) (time.Duration, bool) {
+	switch d := v.(type) {
+	case time.Duration:
+		return d, true
+	case string:
+		parsed, err := time.ParseDuration(d)
+		return parsed, err == nil
+	default:
+		return 0, false
+	}
+}
+
+// check ensures the setting's value has the expected type.
+func (s NodeSetting) check(v interface{}) error {
+	var ok bool
+	switch s.Type {
+	case NodeSettingString:
+		_, ok = v.(string)
+	case NodeSettingInt:
+		_, ok = settingInt(v)
+	case NodeSettingBool:
+		_, ok = v.(bool)
+	case NodeSettingDuration:
+		var d time.Duration
+		d, ok = settingDuration(v)
+		switch str, isString := v.(string); {
+		case !ok && isString:
+			return NewNodeSettingError(s.Name, "%q is not a valid duration", str)
+		case ok && d < 0:
+			return NewNodeSettingError(s.Name, "cannot be negative")
+		}
+	case NodeSettingValue:
+		ok = true
+	}
+	if !ok {
+		return NewNodeSettingError(s.Name, "expected %s value, not %T", s.Type, v)
+	}
+	return nil
+}
+
+// validate ensures the factory is complete and its settings are well defined.
+func (f NodeFactory) validate() error {
+	if f.New == nil {
+		return fmt.Errorf("missing New func: %w", ErrInvalidParameter)
+	}
+	names := make(map[string]bool, len(f.Settings))
+	for _, s := range f.Settings {
+		switch {
+		case s.Name == "":
+			return fmt.Errorf("missing setting name: %w", ErrInvalidParameter)
+		case names[s.Name]:
+			return fmt.Errorf("setting %q is listed more than once: %w", s.Name, ErrInvalidParameter)
+		}
+		switch s.Type {
+		case NodeSettingString, NodeSettingInt, NodeSettingBool, NodeSettingDuration, NodeSettingValue:
+		default:
+			return fmt.Errorf("setting %q has invalid type %q: %w", s.Name, s.Type, ErrInvalidParameter)
+		}
+		names[s.Name] = true
+	}
+	return nil
+}
+
+// nodeFactories is the registry of node types.
+var nodeFactories = struct {
+	sync.RWMutex
+	m map[string]NodeFactory
+}{m: make(map[string]NodeFactory)}
+
+// RegisterNodeFactory registers the factory for the named type of node, which
+// allows it to be created by NewNodeFromSettings.  Packages providing nodes
+// typically register them from an init func.  It's an error to register the
+// same name more than once.
+func RegisterNodeFactory(name string, f NodeFactory) error {
+	const op = "eventlogger.RegisterNodeFactory"
+	if name == "" {
+		return fmt.Errorf("%s: missing node type name: %w", op, ErrInvalidParameter)
+	}
+	if err := f.validate(); err != nil {
+		return fmt.Errorf("%s: %q: %w", op, name, err)
+	}
+
+	nodeFactories.Lock()
+	defer nodeFactories.Unlock()
+	if _, ok := nodeFactories.m[name]; ok {
+		return fmt.Errorf("%s: node type %q is already registered: %w", op, name, ErrInvalidParameter)
+	}
+	f.Settings = append([]NodeSetting(nil), f.Settings...)
+	nodeFactories.m[name] = f
+	return nil
+}
+
+// MustRegisterNodeFactory is like RegisterNodeFactory, but panics if the
+// factory can't be registered.  It's intended for use from an init func.
+func MustRegisterNodeFactory(name string, f NodeFactory) {
+	if err := RegisterNodeFactory(name, f); err != nil {
+		panic(err)
+	}
+}
+
+// LookupNodeFactory returns the factory registered for the named type of node.
+func LookupNodeFactory(name string) (NodeFactory, bool) {
+	nodeFactories.RLock()
+	defer nodeFactories.RUnlock()
+	f, ok := nodeFactories.m[name]
+	return f, ok
+}
+
+// NodeFactoryNames returns the sorted names of the registered types of node.
+func NodeFactoryNames() []string {
+	nodeFactories.RLock()
+	defer nodeFactories.RUnlock()
+	names := make([]string, 0, len(nodeFactories.m))
+	for name := range nodeFactories.m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewNodeFromSettings creates a node of the named type using its registered
+// factory.  The settings are checked against the factory's schema first, and
+// each problem with them is reported as a *NodeSettingError.  The Broker the
+// node will be registered with may be provided for nodes which send events.
+func NewNodeFromSettings(b *Broker, name string, settings map[string]interface{}) (Node, error) {
+	const op = "eventlogger.NewNodeFromSettings"
+	f, ok := LookupNodeFactory(name)
+	if !ok {
+		return nil, fmt.Errorf("%s: unknown node type %q: %w", op, name, ErrInvalidParameter)
+	}
+
+	known := make(map[string]NodeSetting, len(f.Settings))
+	for _, s := range f.Settings {
+		known[s.Name] = s
+	}
+	for _, k := range sortedSettingNames(settings) {
+		s, ok := known[k]
+		if !ok {
+			return nil, fmt.Errorf("%s: %w", op, NewNodeSettingError(k, "unknown setting"))
+		}
+		if err := s.check(settings[k]); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+	for _, s := range f.Settings {
+		if _, ok := settings[s.Name]; s.Required && !ok {
+			return nil, fmt.Errorf("%s: %w", op, NewNodeSettingError(s.Name, "is required"))
+		}
+	}
+
+	n, err := f.New(b, NodeSettings(settings))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return n, nil
+}
+
+// sortedSettingNames returns the names of the settings, sorted so that
+// problems are reported consistently.
+func sortedSettingNames(settings map[string]interface{}) []string {
+	names := make([]string, 0, len(settings))
+	for k := range settings {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// init registers the node types of this package.  The encrypt filter isn't
+// registered, as the filters/encrypt module depends on a released version of
+// eventlogger which predates the registry (see the config package).
+func init() {
+	MustRegisterNodeFactory("file", NodeFactory{
+		Description: "FileSink writes formatted events to a file, optionally rotating it",
+		Settings: []NodeSetting{
+			{Name: "path", Type: NodeSettingString, Required: true, Description: "directory of the file"},
+			{Name: "file_name", Type: NodeSettingString, Description: "name of the file, defaulting to eventlogger.log"},
+			{Name: "mode", Type: NodeSettingString, Description: "octal file mode, such as \"0600\""},
+			{Name: "max_bytes", Type: NodeSettingInt, Description: "size the file may grow to before it's rotated"},
+			{Name: "max_files", Type: NodeSettingInt, Description: "number of rotated files to keep"},
+			{Name: "max_duration", Type: NodeSettingDuration, Description: "age the file may reach before it's rotated"},
+			{Name: "format", Type: NodeSettingString, Description: "format written to the file, defaulting to json"},
+			{Name: "timestamp_only_on_rotate", Type: NodeSettingBool, Description: "only add a timestamp to the file name when it's rotated"},
+		},
+		New: newFileSinkFromSettings,
+	})
+	MustRegisterNodeFactory("json", NodeFactory{
+		Description: "JSONFormatter formats events as JSON",
+		New: func(*Broker, NodeSettings) (Node, error) {
+			return &JSONFormatter{}, nil
+		},
+	})
+}
+
+// newFileSinkFromSettings creates a FileSink.
+func newFileSinkFromSettings(_ *Broker, s NodeSettings) (Node, error) {
+	switch {
+	case s.String("path") == "":
+		return nil, NewNodeSettingError("path", "is required")
+	case s.Int("max_bytes") < 0:
+		return nil, NewNodeSettingError("max_bytes", "cannot be negative")
+	case s.Int("max_files") < 0:
+		return nil, NewNodeSettingError("max_files", "cannot be negative")
+	}
+
+	var mode os.FileMode
+	if m := s.String("mode"); m != "" {
+		parsed, err := strconv.ParseUint(m, 8, 32)
+		if err != nil {
+			return nil, NewNodeSettingError("mode", "%q is not a valid octal file mode", m)
+		}
+		mode = os.FileMode(parsed)
+	}
+
+	return &FileSink{
+		Path:                  s.String("path"),
+		FileName:              s.String("file_name"),
+		Mode:                  mode,
+		MaxBytes:              s.Int("max_bytes"),
+		MaxFiles:              s.Int("max_files"),
+		MaxDuration:           s.Duration("max_duration"),
+		Format:                s.String("format"),
+		TimestampOnlyOnRotate: s.Bool("timestamp_only_on_rotate"),
+	}, nil
+}
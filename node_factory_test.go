@@ -0,0 +1,180 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package eventlogger
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegisterNodeFactory(t *testing.T) {
+	newNode := func(*Broker, NodeSettings) (Node, error) { return &JSONFormatter{}, nil }
+
+	tests := map[string]struct {
+		name string
+		f    NodeFactory
+	}{
+		"missing-name":       {f: NodeFactory{New: newNode}},
+		"missing-new":        {name: "missing-new"},
+		"already-registered": {name: "json", f: NodeFactory{New: newNode}},
+		"missing-setting-name": {name: "missing-setting-name", f: NodeFactory{
+			Settings: []NodeSetting{{Type: NodeSettingString}},
+			New:      newNode,
+		}},
+		"duplicate-setting": {name: "duplicate-setting", f: NodeFactory{
+			Settings: []NodeSetting{{Name: "a", Type: NodeSettingString}, {Name: "a", Type: NodeSettingInt}},
+			New:      newNode,
+		}},
+		"invalid-setting-type": {name: "invalid-setting-type", f: NodeFactory{
+			Settings: []NodeSetting{{Name: "a", Type: "float"}},
+			New:      newNode,
+		}},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := RegisterNodeFactory(tc.name, tc.f)
+			require.ErrorIs(t, err, ErrInvalidParameter)
+			assert.Panics(t, func() { MustRegisterNodeFactory(tc.name, tc.f) })
+		})
+	}
+
+	require.NoError(t, RegisterNodeFactory("test-factory", NodeFactory{
+		Description: "test",
+		Settings:    []NodeSetting{{Name: "a", Type: NodeSettingString}},
+		New:         newNode,
+	}))
+	f, ok := LookupNodeFactory("test-factory")
+	require.True(t, ok)
+	assert.Equal(t, "test", f.Description)
+	assert.Equal(t, []NodeSetting{{Name: "a", Type: NodeSettingString}}, f.Settings)
+	assert.Subset(t, NodeFactoryNames(), []string{"file", "json", "test-factory"})
+
+	_, ok = LookupNodeFactory("missing")
+	assert.False(t, ok)
+}
+
+func TestNewNodeFromSettings(t *testing.T) {
+	var got NodeSettings
+	require.NoError(t, RegisterNodeFactory("settings-test", NodeFactory{
+		Settings: []NodeSetting{
+			{Name: "name", Type: NodeSettingString, Required: true},
+			{Name: "count", Type: NodeSettingInt},
+			{Name: "enabled", Type: NodeSettingBool},
+			{Name: "wait", Type: NodeSettingDuration},
+			{Name: "value", Type: NodeSettingValue},
+		},
+		New: func(_ *Broker, s NodeSettings) (Node, error) {
+			got = s
+			if s.Int("count") > 10 {
+				return nil, NewNodeSettingError("count", "too many")
+			}
+			return &JSONFormatter{}, nil
+		},
+	}))
+
+	tests := map[string]struct {
+		settings    map[string]interface{}
+		wantSetting string
+		wantErr     string
+	}{
+		"unknown-type": {
+			wantErr: `unknown node type`,
+		},
+		"missing-required": {
+			settings:    map[string]interface{}{"count": 1},
+			wantSetting: "name",
+			wantErr:     `setting "name": is required`,
+		},
+		"unknown-setting": {
+			settings:    map[string]interface{}{"name": "a", "colour": "red"},
+			wantSetting: "colour",
+			wantErr:     `setting "colour": unknown setting`,
+		},
+		"wrong-type": {
+			settings:    map[string]interface{}{"name": 1},
+			wantSetting: "name",
+			wantErr:     `setting "name": expected string value, not int`,
+		},
+		"fractional-int": {
+			settings:    map[string]interface{}{"name": "a", "count": 1.5},
+			wantSetting: "count",
+			wantErr:     `expected int value, not float64`,
+		},
+		"invalid-duration": {
+			settings:    map[string]interface{}{"name": "a", "wait": "soon"},
+			wantSetting: "wait",
+			wantErr:     `"soon" is not a valid duration`,
+		},
+		"negative-duration": {
+			settings:    map[string]interface{}{"name": "a", "wait": -time.Second},
+			wantSetting: "wait",
+			wantErr:     `cannot be negative`,
+		},
+		"factory-error": {
+			settings:    map[string]interface{}{"name": "a", "count": 11},
+			wantSetting: "count",
+			wantErr:     `too many`,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			factory := "settings-test"
+			if name == "unknown-type" {
+				factory = "missing"
+			}
+			_, err := NewNodeFromSettings(nil, factory, tc.settings)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tc.wantErr)
+			var settingErr *NodeSettingError
+			if tc.wantSetting == "" {
+				require.ErrorIs(t, err, ErrInvalidParameter)
+				assert.False(t, errors.As(err, &settingErr))
+				return
+			}
+			require.ErrorAs(t, err, &settingErr)
+			assert.Equal(t, tc.wantSetting, settingErr.Setting)
+		})
+	}
+
+	n, err := NewNodeFromSettings(nil, "settings-test", map[string]interface{}{
+		"name":    "a",
+		"count":   float64(3),
+		"enabled": true,
+		"wait":    "1m",
+		"value":   []int{1},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, &JSONFormatter{}, n)
+	assert.Equal(t, "a", got.String("name"))
+	assert.Equal(t, 3, got.Int("count"))
+	assert.True(t, got.Bool("enabled"))
+	assert.Equal(t, time.Minute, got.Duration("wait"))
+	assert.Equal(t, []int{1}, got["value"])
+	assert.Equal(t, "", got.String("missing"))
+}
+
+func TestNewNodeFromSettings_builtin(t *testing.T) {
+	dir := t.TempDir()
+	n, err := NewNodeFromSettings(nil, "file", map[string]interface{}{
+		"path":         dir,
+		"mode":         "0640",
+		"max_files":    3,
+		"max_duration": "24h",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, &FileSink{Path: dir, Mode: 0o640, MaxFiles: 3, MaxDuration: 24 * time.Hour}, n)
+
+	_, err = NewNodeFromSettings(nil, "file", map[string]interface{}{"path": dir, "mode": "rwx"})
+	var settingErr *NodeSettingError
+	require.ErrorAs(t, err, &settingErr)
+	assert.Equal(t, "mode", settingErr.Setting)
+
+	n, err = NewNodeFromSettings(nil, "json", nil)
+	require.NoError(t, err)
+	assert.Equal(t, &JSONFormatter{}, n)
+}
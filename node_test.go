@@ -235,3 +235,110 @@ func (m *mockCloserWithWrapper) Reopen() error {
 func (m *mockCloserWithWrapper) Type() NodeType {
 	return NodeTypeSink
 }
+
+// TestLinkEdges ensures that we are able to create a graph of linked nodes which
+// fans out into branches, sharing nodes which have more than one parent.
+// NOTE: This test should not be run in parallel as it sets a package level variable
+// on 'deep' to ensure we compare unexported fields too.
+func TestLinkEdges(t *testing.T) {
+	filter, f1, f2 := &Filter{Predicate: nil}, &JSONFormatter{}, &JSONFormatterFilter{}
+	s1, s2 := &FileSink{Path: "1.log"}, &FileSink{Path: "2.log"}
+	nodes := map[NodeID]Node{"filter": filter, "f1": f1, "f2": f2, "s1": s1, "s2": s2}
+
+	root, err := linkEdges(nodes, map[NodeID][]NodeID{
+		"filter": {"f1", "f2"},
+		"f1":     {"s1", "s2"},
+		"f2":     {"s2"},
+	})
+	require.NoError(t, err)
+
+	shared := &linkedNode{node: s2, nodeID: "s2"}
+	expected := &linkedNode{
+		node:   filter,
+		nodeID: "filter",
+		next: []*linkedNode{
+			{node: f1, nodeID: "f1", next: []*linkedNode{{node: s1, nodeID: "s1"}, shared}},
+			{node: f2, nodeID: "f2", next: []*linkedNode{shared}},
+		},
+	}
+
+	deep.CompareUnexportedFields = true
+	t.Cleanup(func() { deep.CompareUnexportedFields = false })
+
+	if diff := deep.Equal(root, expected); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+	require.Same(t, root.next[0].next[1], root.next[1].next[0])
+	require.Len(t, root.flatten(), 5)
+}
+
+// TestLinkEdgesErrors attempts to exercise the linkEdges func such that we hit
+// the error checking on the incoming parameters and the shape of the graph.
+func TestLinkEdgesErrors(t *testing.T) {
+	nodes := map[NodeID]Node{
+		"a": &JSONFormatter{}, "b": &JSONFormatter{}, "c": &JSONFormatter{}, "d": &JSONFormatter{},
+	}
+
+	tests := map[string]struct {
+		edges            map[NodeID][]NodeID
+		wantErrorMessage string
+	}{
+		"no-edges": {
+			edges:            nil,
+			wantErrorMessage: "no edges given",
+		},
+		"unknown-node": {
+			edges:            map[NodeID][]NodeID{"a": {"x"}},
+			wantErrorMessage: `node ID "x" not given`,
+		},
+		"duplicate-child": {
+			edges:            map[NodeID][]NodeID{"a": {"b", "b"}},
+			wantErrorMessage: `node ID "a" is linked to node ID "b" more than once`,
+		},
+		"no-root": {
+			edges:            map[NodeID][]NodeID{"a": {"b"}, "b": {"a"}},
+			wantErrorMessage: "no root node, every node is a child of another node",
+		},
+		"multiple-roots": {
+			edges:            map[NodeID][]NodeID{"a": {"c"}, "b": {"c"}},
+			wantErrorMessage: `multiple root nodes: ["a" "b"]`,
+		},
+		"unreachable": {
+			edges:            map[NodeID][]NodeID{"a": {"b"}, "c": {"d"}, "d": {"c"}},
+			wantErrorMessage: `nodes not reachable from root node ID "a": ["c" "d"]`,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := linkEdges(nodes, tc.edges)
+			require.Error(t, err)
+			require.EqualError(t, err, tc.wantErrorMessage)
+		})
+	}
+}
+
+func TestNodeType_String(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "filter", NodeTypeFilter.String())
+	assert.Equal(t, "formatter", NodeTypeFormatter.String())
+	assert.Equal(t, "sink", NodeTypeSink.String())
+	assert.Equal(t, "formatter-filter", NodeTypeFormatterFilter.String())
+	assert.Equal(t, "NodeType(0)", NodeType(0).String())
+}
+
+func TestNodeType_Text(t *testing.T) {
+	t.Parallel()
+
+	for _, nt := range []NodeType{NodeTypeFilter, NodeTypeFormatter, NodeTypeSink, NodeTypeFormatterFilter} {
+		text, err := nt.MarshalText()
+		require.NoError(t, err)
+		var got NodeType
+		require.NoError(t, got.UnmarshalText(text))
+		assert.Equal(t, nt, got)
+	}
+
+	var got NodeType
+	require.ErrorIs(t, got.UnmarshalText([]byte("router")), ErrInvalidParameter)
+}
@@ -0,0 +1,103 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package eventlogger
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// NodeOutcome describes the result of a Node processing an Event.
+type NodeOutcome string
+
+const (
+	// NodeOutcomePassed means the node processed the event successfully. For
+	// filters and formatters the event continues down the pipeline, sinks have
+	// finished with it.
+	NodeOutcomePassed NodeOutcome = "passed"
+	// NodeOutcomeFiltered means a non-sink node processed the event
+	// successfully and removed it from the pipeline.
+	NodeOutcomeFiltered NodeOutcome = "filtered"
+	// NodeOutcomeError means the node returned an error.
+	NodeOutcomeError NodeOutcome = "error"
+	// NodeOutcomePanicked means the node panicked, and the panic was
+	// recovered (see WithPanicPolicy).
+	NodeOutcomePanicked NodeOutcome = "panicked"
+)
+
+// NodeObservation describes a single call to Node.Process.
+type NodeObservation struct {
+	// EventType of the Event being processed
+	EventType EventType
+
+	// PipelineEventType which the Pipeline is registered for, which is a
+	// pattern (such as "audit.*") when the Event was routed to it by a pattern
+	PipelineEventType EventType
+
+	// PipelineID of the Pipeline the node was processing the Event for, which
+	// is unique for its PipelineEventType
+	PipelineID PipelineID
+
+	// NodeID of the node
+	NodeID NodeID
+
+	// NodeName of the node, if it implements Named
+	NodeName string
+
+	// NodeType of the node
+	NodeType NodeType
+
+	// Duration of the call to Node.Process
+	Duration time.Duration
+
+	// Outcome of the call to Node.Process
+	Outcome NodeOutcome
+
+	// Err returned by Node.Process, only set when Outcome is NodeOutcomeError
+	// or NodeOutcomePanicked (as a *PanicError)
+	Err error
+}
+
+// Observer is notified after every call to Node.Process made by a Broker, which
+// allows metrics such as counts, latency and errors to be recorded per node.
+// ObserveNode is called on the goroutine which processed the node, so
+// implementations must be safe for concurrent use and should not block.
+type Observer interface {
+	ObserveNode(ctx context.Context, o NodeObservation)
+}
+
+// ObserverFunc is an adapter which allows an ordinary func to be used as an
+// Observer.
+type ObserverFunc func(ctx context.Context, o NodeObservation)
+
+// ObserveNode calls f(ctx, o).
+func (f ObserverFunc) ObserveNode(ctx context.Context, o NodeObservation) {
+	f(ctx, o)
+}
+
+// WithObserver configures the option that provides an Observer for the Broker,
+// which is notified after every call to Node.Process.  When no Observer is
+// configured, nodes are not timed.
+func WithObserver(o Observer) Option {
+	return func(opts *options) error {
+		opts.withObserver = o
+		return nil
+	}
+}
+
+// nodeOutcome determines the outcome of a node returning e and err.
+func nodeOutcome(t NodeType, e *Event, err error) NodeOutcome {
+	var panicErr *PanicError
+	switch {
+	case errors.As(err, &panicErr):
+		return NodeOutcomePanicked
+	case err != nil:
+		return NodeOutcomeError
+	case e == nil && t != NodeTypeSink:
+		return NodeOutcomeFiltered
+	default:
+		return NodeOutcomePassed
+	}
+}
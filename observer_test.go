@@ -0,0 +1,78 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package eventlogger
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestBroker_WithObserver ensures that the Observer is notified about every
+// call to Node.Process with the right labels and outcome.
+func TestBroker_WithObserver(t *testing.T) {
+	t.Parallel()
+
+	var l sync.Mutex
+	got := map[NodeID]NodeObservation{}
+	observer := ObserverFunc(func(_ context.Context, o NodeObservation) {
+		l.Lock()
+		defer l.Unlock()
+		got[o.NodeID] = o
+	})
+
+	b, err := NewBroker(WithObserver(observer))
+	require.NoError(t, err)
+
+	sinkErr := errors.New("sink failed")
+	require.NoError(t, b.RegisterNode("filter", &Filter{Predicate: func(e *Event) (bool, error) {
+		return e.Payload != "drop", nil
+	}}))
+	require.NoError(t, b.RegisterNode("json", &JSONFormatter{}))
+	require.NoError(t, b.RegisterNode("good", &testActionNode{}))
+	require.NoError(t, b.RegisterNode("bad", &testActionNode{action: func(context.Context, *Event) (*Event, error) {
+		return nil, sinkErr
+	}}))
+	require.NoError(t, b.RegisterPipeline(Pipeline{
+		PipelineID: "p",
+		EventType:  "t",
+		Edges: map[NodeID][]NodeID{
+			"filter": {"json"},
+			"json":   {"good", "bad"},
+		},
+	}))
+
+	_, err = b.Send(context.Background(), "t", "keep")
+	require.NoError(t, err)
+
+	require.Len(t, got, 4)
+	for id, want := range map[NodeID]struct {
+		nodeType NodeType
+		outcome  NodeOutcome
+		err      error
+	}{
+		"filter": {NodeTypeFilter, NodeOutcomePassed, nil},
+		"json":   {NodeTypeFormatter, NodeOutcomePassed, nil},
+		"good":   {NodeTypeSink, NodeOutcomePassed, nil},
+		"bad":    {NodeTypeSink, NodeOutcomeError, sinkErr},
+	} {
+		o := got[id]
+		assert.Equal(t, EventType("t"), o.EventType, id)
+		assert.Equal(t, PipelineID("p"), o.PipelineID, id)
+		assert.Equal(t, want.nodeType, o.NodeType, id)
+		assert.Equal(t, want.outcome, o.Outcome, id)
+		assert.Equal(t, want.err, o.Err, id)
+		assert.Positive(t, o.Duration, id)
+	}
+
+	got = map[NodeID]NodeObservation{}
+	_, err = b.Send(context.Background(), "t", "drop")
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	assert.Equal(t, NodeOutcomeFiltered, got["filter"].Outcome)
+}
@@ -0,0 +1,7 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package metrics implements an in-memory eventlogger.Observer which counts
+// the calls to every node, by outcome, along with their total duration.  The
+// counters can be rendered in the Prometheus text exposition format.
+package metrics
@@ -0,0 +1,58 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package metrics_test
+
+import (
+	"context"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/hashicorp/eventlogger"
+	"github.com/hashicorp/eventlogger/observers/metrics"
+	"github.com/hashicorp/eventlogger/sinks/writer"
+)
+
+func ExampleObserver() {
+	// Create a broker which reports to an in-memory metrics Observer
+	observer := &metrics.Observer{}
+	b, _ := eventlogger.NewBroker(eventlogger.WithObserver(observer))
+
+	// Marshal to JSON and discard the output
+	if err := b.RegisterNode("json", &eventlogger.JSONFormatter{}); err != nil {
+		// handle error
+	}
+	if err := b.RegisterNode("discard", &writer.Sink{Writer: io.Discard}); err != nil {
+		// handle error
+	}
+	err := b.RegisterPipeline(eventlogger.Pipeline{
+		EventType:  "test-event",
+		PipelineID: "discard-pipeline",
+		NodeIDs:    []eventlogger.NodeID{"json", "discard"},
+	})
+	if err != nil {
+		// handle error
+	}
+
+	for i := 0; i < 3; i++ {
+		if _, err := b.Send(context.Background(), "test-event", i); err != nil {
+			// handle error
+		}
+	}
+
+	// Print the counters, skipping the durations which vary from run to run.
+	var sb strings.Builder
+	if err := observer.WritePrometheus(&sb); err != nil {
+		// handle error
+	}
+	for _, line := range strings.Split(sb.String(), "\n") {
+		if strings.HasPrefix(line, metrics.ProcessedMetricName) {
+			_, _ = os.Stdout.WriteString(line + "\n")
+		}
+	}
+
+	// Output:
+	// eventlogger_node_processed_total{event_type="test-event",pipeline_id="discard-pipeline",node_id="discard",node_type="sink",outcome="passed"} 3
+	// eventlogger_node_processed_total{event_type="test-event",pipeline_id="discard-pipeline",node_id="json",node_name="JSONFormatter",node_type="formatter",outcome="passed"} 3
+}
@@ -0,0 +1,182 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package metrics
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/eventlogger"
+)
+
+const (
+	// ProcessedMetricName is the name of the Prometheus counter of calls to
+	// Node.Process
+	ProcessedMetricName = "eventlogger_node_processed_total"
+
+	// DurationMetricName is the name of the Prometheus summary of the time
+	// spent in Node.Process
+	DurationMetricName = "eventlogger_node_process_duration_seconds"
+)
+
+// Series is the count and total duration of calls to Node.Process for a
+// unique combination of labels.
+type Series struct {
+	EventType  eventlogger.EventType
+	PipelineID eventlogger.PipelineID
+	NodeID     eventlogger.NodeID
+	NodeName   string
+	NodeType   eventlogger.NodeType
+	Outcome    eventlogger.NodeOutcome
+
+	// Count of calls to Node.Process
+	Count uint64
+
+	// Duration is the total time spent in Node.Process
+	Duration time.Duration
+}
+
+// key identifies a Series
+type key struct {
+	eventType  eventlogger.EventType
+	pipelineID eventlogger.PipelineID
+	nodeID     eventlogger.NodeID
+	nodeName   string
+	nodeType   eventlogger.NodeType
+	outcome    eventlogger.NodeOutcome
+}
+
+// Observer is an eventlogger.Observer which keeps counters in memory. The zero
+// value is ready to use.
+type Observer struct {
+	l      sync.Mutex
+	series map[key]*Series
+}
+
+var _ eventlogger.Observer = (*Observer)(nil)
+
+// ObserveNode records the observation.
+func (o *Observer) ObserveNode(_ context.Context, obs eventlogger.NodeObservation) {
+	k := key{
+		eventType:  obs.EventType,
+		pipelineID: obs.PipelineID,
+		nodeID:     obs.NodeID,
+		nodeName:   obs.NodeName,
+		nodeType:   obs.NodeType,
+		outcome:    obs.Outcome,
+	}
+
+	o.l.Lock()
+	defer o.l.Unlock()
+	if o.series == nil {
+		o.series = make(map[key]*Series)
+	}
+	s, ok := o.series[k]
+	if !ok {
+		s = &Series{
+			EventType:  obs.EventType,
+			PipelineID: obs.PipelineID,
+			NodeID:     obs.NodeID,
+			NodeName:   obs.NodeName,
+			NodeType:   obs.NodeType,
+			Outcome:    obs.Outcome,
+		}
+		o.series[k] = s
+	}
+	s.Count++
+	s.Duration += obs.Duration
+}
+
+// Snapshot returns a copy of every Series, sorted by event type, pipeline ID,
+// node ID and outcome.
+func (o *Observer) Snapshot() []Series {
+	o.l.Lock()
+	result := make([]Series, 0, len(o.series))
+	for _, s := range o.series {
+		result = append(result, *s)
+	}
+	o.l.Unlock()
+
+	sort.Slice(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		switch {
+		case a.EventType != b.EventType:
+			return a.EventType < b.EventType
+		case a.PipelineID != b.PipelineID:
+			return a.PipelineID < b.PipelineID
+		case a.NodeID != b.NodeID:
+			return a.NodeID < b.NodeID
+		default:
+			return a.Outcome < b.Outcome
+		}
+	})
+	return result
+}
+
+// Reset discards every Series.
+func (o *Observer) Reset() {
+	o.l.Lock()
+	defer o.l.Unlock()
+	o.series = nil
+}
+
+// WritePrometheus writes a snapshot of the counters to w in the Prometheus
+// text exposition format.
+func (o *Observer) WritePrometheus(w io.Writer) error {
+	return WritePrometheus(w, o.Snapshot())
+}
+
+// WritePrometheus writes the series to w in the Prometheus text exposition
+// format, as a counter named ProcessedMetricName and a summary named
+// DurationMetricName.
+func WritePrometheus(w io.Writer, series []Series) error {
+	bw := bufio.NewWriter(w)
+
+	fmt.Fprintf(bw, "# HELP %s Number of events processed by a node.\n", ProcessedMetricName)
+	fmt.Fprintf(bw, "# TYPE %s counter\n", ProcessedMetricName)
+	for _, s := range series {
+		fmt.Fprintf(bw, "%s{%s} %d\n", ProcessedMetricName, labels(s), s.Count)
+	}
+
+	fmt.Fprintf(bw, "# HELP %s Time spent processing events by a node.\n", DurationMetricName)
+	fmt.Fprintf(bw, "# TYPE %s summary\n", DurationMetricName)
+	for _, s := range series {
+		l := labels(s)
+		fmt.Fprintf(bw, "%s_sum{%s} %g\n", DurationMetricName, l, s.Duration.Seconds())
+		fmt.Fprintf(bw, "%s_count{%s} %d\n", DurationMetricName, l, s.Count)
+	}
+
+	return bw.Flush()
+}
+
+// labels renders the Series labels in the Prometheus text exposition format.
+// The node_name label is omitted when the node doesn't have a name, which
+// Prometheus treats the same as an empty value.
+func labels(s Series) string {
+	var name string
+	if s.NodeName != "" {
+		name = fmt.Sprintf(`,node_name="%s"`, escape(s.NodeName))
+	}
+	return fmt.Sprintf(`event_type="%s",pipeline_id="%s",node_id="%s"%s,node_type="%s",outcome="%s"`,
+		escape(string(s.EventType)),
+		escape(string(s.PipelineID)),
+		escape(string(s.NodeID)),
+		name,
+		escape(s.NodeType.String()),
+		escape(string(s.Outcome)),
+	)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// escape a label value as required by the Prometheus text exposition format.
+func escape(v string) string {
+	return labelEscaper.Replace(v)
+}
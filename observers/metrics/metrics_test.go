@@ -0,0 +1,103 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/eventlogger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestObserver_ObserveNode(t *testing.T) {
+	ctx := context.Background()
+	o := &Observer{}
+
+	sink := eventlogger.NodeObservation{
+		EventType:  "audit",
+		PipelineID: "file",
+		NodeID:     "sink",
+		NodeType:   eventlogger.NodeTypeSink,
+		Duration:   time.Millisecond,
+		Outcome:    eventlogger.NodeOutcomePassed,
+	}
+	o.ObserveNode(ctx, sink)
+	o.ObserveNode(ctx, sink)
+	failed := sink
+	failed.Outcome = eventlogger.NodeOutcomeError
+	failed.Err = errors.New("write failed")
+	o.ObserveNode(ctx, failed)
+	o.ObserveNode(ctx, eventlogger.NodeObservation{
+		EventType:  "audit",
+		PipelineID: "file",
+		NodeID:     "filter",
+		NodeType:   eventlogger.NodeTypeFilter,
+		Duration:   time.Millisecond,
+		Outcome:    eventlogger.NodeOutcomeFiltered,
+	})
+
+	got := o.Snapshot()
+	require.Len(t, got, 3)
+	assert.Equal(t, eventlogger.NodeID("filter"), got[0].NodeID)
+	assert.Equal(t, Series{
+		EventType:  "audit",
+		PipelineID: "file",
+		NodeID:     "sink",
+		NodeType:   eventlogger.NodeTypeSink,
+		Outcome:    eventlogger.NodeOutcomeError,
+		Count:      1,
+		Duration:   time.Millisecond,
+	}, got[1])
+	assert.Equal(t, uint64(2), got[2].Count)
+	assert.Equal(t, 2*time.Millisecond, got[2].Duration)
+
+	o.Reset()
+	assert.Empty(t, o.Snapshot())
+}
+
+func TestWritePrometheus(t *testing.T) {
+	series := []Series{
+		{
+			EventType:  `audit"quoted"`,
+			PipelineID: `back\slash`,
+			NodeID:     "sink",
+			NodeType:   eventlogger.NodeTypeSink,
+			Outcome:    eventlogger.NodeOutcomePassed,
+			Count:      3,
+			Duration:   1500 * time.Millisecond,
+		},
+		{
+			EventType:  "audit",
+			PipelineID: "file",
+			NodeID:     "file",
+			NodeName:   "sink:/var/log/audit",
+			NodeType:   eventlogger.NodeTypeSink,
+			Outcome:    eventlogger.NodeOutcomePassed,
+			Count:      1,
+			Duration:   time.Second,
+		},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, WritePrometheus(&buf, series))
+
+	labels := `event_type="audit\"quoted\"",pipeline_id="back\\slash",node_id="sink",node_type="sink",outcome="passed"`
+	named := `event_type="audit",pipeline_id="file",node_id="file",node_name="sink:/var/log/audit",node_type="sink",outcome="passed"`
+	want := "# HELP eventlogger_node_processed_total Number of events processed by a node.\n" +
+		"# TYPE eventlogger_node_processed_total counter\n" +
+		"eventlogger_node_processed_total{" + labels + "} 3\n" +
+		"eventlogger_node_processed_total{" + named + "} 1\n" +
+		"# HELP eventlogger_node_process_duration_seconds Time spent processing events by a node.\n" +
+		"# TYPE eventlogger_node_process_duration_seconds summary\n" +
+		"eventlogger_node_process_duration_seconds_sum{" + labels + "} 1.5\n" +
+		"eventlogger_node_process_duration_seconds_count{" + labels + "} 3\n" +
+		"eventlogger_node_process_duration_seconds_sum{" + named + "} 1\n" +
+		"eventlogger_node_process_duration_seconds_count{" + named + "} 1\n"
+	assert.Equal(t, want, buf.String())
+}
@@ -0,0 +1,78 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package eventlogger
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+)
+
+// PanicPolicy is used to specify what the Broker should do when a node panics
+// while processing an event.
+type PanicPolicy string
+
+const (
+	// PanicRecover recovers the panic, so that the node fails with a
+	// *PanicError and the rest of the graph carries on.
+	PanicRecover PanicPolicy = "Recover"
+	// PanicRepanic doesn't recover the panic, which crashes the process with
+	// the node's original stack trace.  This is intended for debugging.
+	PanicRepanic PanicPolicy = "Repanic"
+)
+
+// PanicError is the error of a node which panicked while processing an
+// event, when the Broker recovers panics (see WithPanicPolicy).
+type PanicError struct {
+	// NodeID of the node which panicked
+	NodeID NodeID
+
+	// Value passed to panic
+	Value interface{}
+
+	// Stack trace of the goroutine which panicked
+	Stack []byte
+}
+
+// Error describes the panic, without the stack trace.  As with the other
+// errors of nodes, the node is identified by the NodeError which wraps it.
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panicked: %v", e.Value)
+}
+
+// Unwrap returns the value passed to panic, if it was an error.
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// WithPanicPolicy configures the option that determines what the Broker does
+// when a node panics (default: PanicRecover).
+func WithPanicPolicy(policy PanicPolicy) Option {
+	return func(o *options) error {
+		var err error
+
+		switch policy {
+		case PanicRecover, PanicRepanic:
+			o.withPanicPolicy = policy
+		default:
+			err = fmt.Errorf("'%s' is not a valid panic policy: %w", policy, ErrInvalidParameter)
+		}
+
+		return err
+	}
+}
+
+// callNode calls Process on the node, recovering any panic as a *PanicError
+// unless the graph's PanicPolicy is PanicRepanic.
+func (g *graph) callNode(ctx context.Context, node *linkedNode, e *Event) (result *Event, err error) {
+	if g.panicPolicy != PanicRepanic {
+		defer func() {
+			if v := recover(); v != nil {
+				result, err = nil, &PanicError{NodeID: node.nodeID, Value: v, Stack: debug.Stack()}
+			}
+		}()
+	}
+	return node.node.Process(ctx, e)
+}
@@ -0,0 +1,123 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package eventlogger
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBroker_NodePanics(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	errPanic := errors.New("panic value")
+
+	tests := map[string]struct {
+		value   interface{}
+		opts    []Option
+		wantErr string
+	}{
+		"string": {
+			value:   "boom",
+			wantErr: `node ID "filter": panicked: boom`,
+		},
+		"error": {
+			value:   errPanic,
+			wantErr: `node ID "filter": panicked: panic value`,
+		},
+		"with-timeout": {
+			value:   "boom",
+			opts:    []Option{WithNodeTimeout(time.Minute)},
+			wantErr: `node ID "filter": panicked: boom`,
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var l sync.Mutex
+			var observed []NodeObservation
+			b, err := NewBroker(WithObserver(ObserverFunc(func(_ context.Context, o NodeObservation) {
+				l.Lock()
+				defer l.Unlock()
+				observed = append(observed, o)
+			})))
+			require.NoError(t, err)
+
+			filter := &Filter{Predicate: func(*Event) (bool, error) { panic(tc.value) }}
+			require.NoError(t, b.RegisterNode("filter", filter, tc.opts...))
+			require.NoError(t, b.RegisterNode("formatter", &JSONFormatter{}))
+			require.NoError(t, b.RegisterNode("sink", &testActionNode{}))
+			require.NoError(t, b.RegisterNode("other-sink", &testActionNode{}))
+			require.NoError(t, b.RegisterPipeline(Pipeline{PipelineID: "panics", EventType: "t", NodeIDs: []NodeID{"filter", "formatter", "sink"}}))
+			require.NoError(t, b.RegisterPipeline(Pipeline{PipelineID: "ok", EventType: "t", NodeIDs: []NodeID{"formatter", "other-sink"}}))
+
+			status, err := b.Send(ctx, "t", "payload")
+			require.NoError(t, err)
+			assert.Equal(t, []NodeID{"other-sink"}, status.CompleteSinks())
+
+			require.Len(t, status.Warnings, 1)
+			assert.EqualError(t, status.Warnings[0], tc.wantErr)
+			var nodeErr *NodeError
+			require.ErrorAs(t, status.Warnings[0], &nodeErr)
+			assert.Equal(t, PipelineID("panics"), nodeErr.PipelineID)
+			var panicErr *PanicError
+			require.ErrorAs(t, status.Warnings[0], &panicErr)
+			assert.Equal(t, NodeID("filter"), panicErr.NodeID)
+			assert.Equal(t, tc.value, panicErr.Value)
+			assert.Contains(t, string(panicErr.Stack), "TestBroker_NodePanics")
+			if err, ok := tc.value.(error); ok {
+				assert.ErrorIs(t, status.Warnings[0], err)
+			}
+
+			l.Lock()
+			defer l.Unlock()
+			var outcomes []NodeOutcome
+			for _, o := range observed {
+				if o.NodeID == "filter" {
+					outcomes = append(outcomes, o.Outcome)
+					assert.ErrorAs(t, o.Err, &panicErr)
+				}
+			}
+			assert.Equal(t, []NodeOutcome{NodeOutcomePanicked}, outcomes)
+		})
+	}
+}
+
+func TestGraph_callNode_Repanic(t *testing.T) {
+	t.Parallel()
+
+	node := &linkedNode{nodeID: "n", node: &testActionNode{
+		action: func(context.Context, *Event) (*Event, error) { panic("boom") },
+	}}
+
+	g := &graph{panicPolicy: PanicRepanic}
+	assert.PanicsWithValue(t, "boom", func() {
+		_, _ = g.callNode(context.Background(), node, &Event{})
+	})
+
+	g = &graph{panicPolicy: PanicRecover}
+	_, err := g.callNode(context.Background(), node, &Event{})
+	assert.EqualError(t, err, `panicked: boom`)
+}
+
+func TestWithPanicPolicy(t *testing.T) {
+	t.Parallel()
+
+	b, err := NewBroker(WithPanicPolicy(PanicRepanic))
+	require.NoError(t, err)
+	assert.Equal(t, PanicRepanic, b.panicPolicy)
+
+	_, err = NewBroker(WithPanicPolicy("Ignore"))
+	require.ErrorIs(t, err, ErrInvalidParameter)
+	assert.EqualError(t, err, "cannot create broker: 'Ignore' is not a valid panic policy: invalid parameter")
+}
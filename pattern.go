@@ -0,0 +1,70 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package eventlogger
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// AllEventTypes is the pattern which matches every EventType, so that a
+// pipeline registered for it receives every Event sent to the Broker.
+const AllEventTypes EventType = "*"
+
+// patternSuffix ends a hierarchical pattern, such as "audit.*" which matches
+// "audit.login" and "audit.login.failed", but not "audit".
+const patternSuffix = ".*"
+
+// IsPattern reports whether the EventType is a pattern which matches other
+// EventTypes: either AllEventTypes or a hierarchical prefix ending in ".*".
+// Pipelines may be registered for a pattern, but events can't be sent with one.
+// Other EventTypes containing "*", such as "a*b", aren't patterns.
+func (t EventType) IsPattern() bool {
+	return t == AllEventTypes || strings.HasSuffix(string(t), patternSuffix)
+}
+
+// Matches reports whether the pattern matches the EventType.  An EventType
+// which isn't a pattern only matches itself.
+func (t EventType) Matches(other EventType) bool {
+	switch {
+	case t == AllEventTypes:
+		return true
+	case strings.HasSuffix(string(t), patternSuffix):
+		prefix := t[:len(t)-1]
+		return len(other) > len(prefix) && strings.HasPrefix(string(other), string(prefix))
+	default:
+		return t == other
+	}
+}
+
+// validatePattern checks that the EventType is either a valid pattern, or
+// isn't a pattern at all.
+func validatePattern(t EventType) error {
+	if !t.IsPattern() || t == AllEventTypes {
+		return nil
+	}
+	prefix := strings.TrimSuffix(string(t), patternSuffix)
+	if prefix == "" || strings.Contains(prefix, "*") || strings.HasSuffix(prefix, ".") {
+		return fmt.Errorf("event type %q is not a valid pattern, it must be %q or end in %q", t, AllEventTypes, patternSuffix)
+	}
+	return nil
+}
+
+// routedPattern is a graph registered for a pattern.
+type routedPattern struct {
+	pattern EventType
+	graph   *graph
+}
+
+// sortPatterns sorts patterns from the most to the least specific: longer
+// prefixes sort first, so that AllEventTypes is last.
+func sortPatterns(patterns []routedPattern) {
+	sort.Slice(patterns, func(i, j int) bool {
+		if len(patterns[i].pattern) != len(patterns[j].pattern) {
+			return len(patterns[i].pattern) > len(patterns[j].pattern)
+		}
+		return patterns[i].pattern < patterns[j].pattern
+	})
+}
@@ -0,0 +1,361 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package eventlogger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventType_Matches(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		pattern EventType
+		matches []EventType
+		misses  []EventType
+	}{
+		"all": {
+			pattern: AllEventTypes,
+			matches: []EventType{"audit", "audit.login", "a"},
+		},
+		"hierarchical": {
+			pattern: "audit.*",
+			matches: []EventType{"audit.login", "audit.login.failed"},
+			misses:  []EventType{"audit", "audit.", "auditing.login", "other.audit.login"},
+		},
+		"exact": {
+			pattern: "audit",
+			matches: []EventType{"audit"},
+			misses:  []EventType{"audit.login", "aud"},
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			for _, et := range tc.matches {
+				assert.True(t, tc.pattern.Matches(et), et)
+			}
+			for _, et := range tc.misses {
+				assert.False(t, tc.pattern.Matches(et), et)
+			}
+		})
+	}
+
+	assert.True(t, AllEventTypes.IsPattern())
+	assert.True(t, EventType("audit.*").IsPattern())
+	assert.False(t, EventType("audit").IsPattern())
+	// Only "*" and a trailing ".*" make a pattern, so these are literal.
+	assert.False(t, EventType("a*b").IsPattern())
+	assert.False(t, EventType("audit*").IsPattern())
+	assert.True(t, EventType("a*b").Matches("a*b"))
+	assert.False(t, EventType("a*b").Matches("axb"))
+}
+
+func TestBroker_RegisterPipeline_Pattern(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		eventType EventType
+		nodeIDs   []NodeID
+		wantErr   string
+	}{
+		"all": {
+			eventType: AllEventTypes,
+			nodeIDs:   []NodeID{"formatter", "sink"},
+		},
+		"hierarchical": {
+			eventType: "audit.login.*",
+			nodeIDs:   []NodeID{"formatter", "sink"},
+		},
+		"literal-no-separator": {
+			eventType: "audit*",
+			nodeIDs:   []NodeID{"formatter", "sink"},
+		},
+		"literal-infix": {
+			eventType: "audit.*.login",
+			nodeIDs:   []NodeID{"formatter", "sink"},
+		},
+		"infix-prefix": {
+			eventType: "audit.*.login.*",
+			nodeIDs:   []NodeID{"formatter", "sink"},
+			wantErr:   `event type "audit.*.login.*" is not a valid pattern, it must be "*" or end in ".*"`,
+		},
+		"empty-prefix": {
+			eventType: ".*",
+			nodeIDs:   []NodeID{"formatter", "sink"},
+			wantErr:   `event type ".*" is not a valid pattern, it must be "*" or end in ".*"`,
+		},
+		"empty-segment": {
+			eventType: "audit..*",
+			nodeIDs:   []NodeID{"formatter", "sink"},
+			wantErr:   `event type "audit..*" is not a valid pattern, it must be "*" or end in ".*"`,
+		},
+		"sink-without-formatter": {
+			eventType: AllEventTypes,
+			nodeIDs:   []NodeID{"filter", "sink"},
+			wantErr:   "sink node without preceding formatter or formatter filter",
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			b, err := NewBroker()
+			require.NoError(t, err)
+			require.NoError(t, b.RegisterNode("filter", &Filter{Predicate: func(*Event) (bool, error) { return true, nil }}))
+			require.NoError(t, b.RegisterNode("formatter", &JSONFormatter{}))
+			require.NoError(t, b.RegisterNode("sink", &testActionNode{}))
+
+			err = b.RegisterPipeline(Pipeline{PipelineID: "p", EventType: tc.eventType, NodeIDs: tc.nodeIDs})
+			if tc.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+// patternTestNodes returns a sink which fails, "bad", and the sinks "exact",
+// "prefix" and "all", which record the event types they receive along with a
+// func returning (and resetting) what they've received.
+func patternTestNodes() (map[NodeID]Node, func() map[PipelineID][]EventType) {
+	var l sync.Mutex
+	received := make(map[PipelineID][]EventType)
+	nodes := map[NodeID]Node{
+		"bad": &testActionNode{
+			action: func(context.Context, *Event) (*Event, error) { return nil, errors.New("bad sink") },
+		},
+	}
+	for _, id := range []PipelineID{"exact", "prefix", "all"} {
+		nodes[NodeID(id)] = &testActionNode{
+			action: func(_ context.Context, e *Event) (*Event, error) {
+				l.Lock()
+				defer l.Unlock()
+				received[id] = append(received[id], e.Type)
+				return nil, nil
+			},
+		}
+	}
+
+	return nodes, func() map[PipelineID][]EventType {
+		l.Lock()
+		defer l.Unlock()
+		got := received
+		received = make(map[PipelineID][]EventType)
+		return got
+	}
+}
+
+func TestBroker_Send_Pattern(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	nodes, received := patternTestNodes()
+	b := newTestBroker(t, nodes, nil)
+	require.NoError(t, b.RegisterPipeline(Pipeline{PipelineID: "exact", EventType: "audit.login", NodeIDs: []NodeID{"formatter", "exact"}}))
+	require.NoError(t, b.RegisterPipeline(Pipeline{PipelineID: "prefix", EventType: "audit.*", NodeIDs: []NodeID{"formatter", "prefix"}}))
+	require.NoError(t, b.RegisterPipeline(Pipeline{PipelineID: "all", EventType: AllEventTypes, NodeIDs: []NodeID{"formatter", "all"}}))
+
+	status, err := b.Send(ctx, "audit.login", "payload")
+	require.NoError(t, err)
+	assert.Equal(t, map[PipelineID][]EventType{
+		"exact":  {"audit.login"},
+		"prefix": {"audit.login"},
+		"all":    {"audit.login"},
+	}, received())
+	assert.ElementsMatch(t, []NodeID{"exact", "prefix", "all"}, status.CompleteSinks())
+	var pipelines []string
+	for _, r := range status.Pipelines() {
+		pipelines = append(pipelines, fmt.Sprintf("%s/%s", r.EventType, r.PipelineID))
+	}
+	assert.Equal(t, []string{"*/all", "audit.login/exact", "audit.*/prefix"}, pipelines)
+
+	_, err = b.Send(ctx, "audit.logout", "payload")
+	require.NoError(t, err)
+	assert.Equal(t, map[PipelineID][]EventType{
+		"prefix": {"audit.logout"},
+		"all":    {"audit.logout"},
+	}, received())
+
+	_, err = b.Send(ctx, "audit", "payload")
+	require.NoError(t, err)
+	assert.Equal(t, map[PipelineID][]EventType{"all": {"audit"}}, received())
+
+	_, err = b.Send(ctx, "audit.*", "payload")
+	require.ErrorIs(t, err, ErrInvalidParameter)
+	assert.EqualError(t, err, "cannot send event with pattern EventType audit.*: invalid parameter")
+
+	_, err = b.Send(ctx, "other", "payload")
+	require.NoError(t, err)
+	assert.Equal(t, map[PipelineID][]EventType{"all": {"other"}}, received())
+
+	// As with an EventType, the pattern is still known once its pipeline is
+	// removed, so events are routed to it but not processed.
+	require.NoError(t, b.RemovePipeline(AllEventTypes, "all"))
+	_, err = b.Send(ctx, "other", "payload")
+	require.NoError(t, err)
+	assert.Empty(t, received())
+
+	nodes, _ = patternTestNodes()
+	b2 := newTestBroker(t, nodes, nil)
+	require.NoError(t, b2.RegisterPipeline(Pipeline{PipelineID: "prefix", EventType: "audit.*", NodeIDs: []NodeID{"formatter", "prefix"}}))
+	_, err = b2.Send(ctx, "other", "payload")
+	assert.EqualError(t, err, "no graph for EventType other")
+}
+
+func TestBroker_Send_PatternPipelineResults(t *testing.T) {
+	t.Parallel()
+
+	// Pipeline IDs are only unique for their EventType or pattern, so the
+	// results, warnings and observations identify which one the pipeline is
+	// registered for.
+	var l sync.Mutex
+	observed := map[NodeID]NodeObservation{}
+	observer := ObserverFunc(func(_ context.Context, o NodeObservation) {
+		l.Lock()
+		defer l.Unlock()
+		observed[o.NodeID] = o
+	})
+	nodes, _ := patternTestNodes()
+	b := newTestBroker(t, nodes, nil, WithObserver(observer))
+	require.NoError(t, b.RegisterPipeline(Pipeline{PipelineID: "p", EventType: "audit.login", NodeIDs: []NodeID{"formatter", "exact"}}))
+	require.NoError(t, b.RegisterPipeline(Pipeline{PipelineID: "p", EventType: "audit.*", NodeIDs: []NodeID{"formatter", "bad"}}))
+
+	status, err := b.Send(context.Background(), "audit.login", "payload")
+	require.NoError(t, err)
+	results := status.Pipelines()
+	require.Len(t, results, 2)
+	assert.Equal(t, EventType("audit.*"), results[0].EventType)
+	assert.Equal(t, PipelineFailed, results[0].Outcome)
+	assert.Equal(t, EventType("audit.login"), results[1].EventType)
+	assert.Equal(t, PipelineDelivered, results[1].Outcome)
+	for _, r := range results {
+		assert.Equal(t, PipelineID("p"), r.PipelineID)
+	}
+
+	require.Len(t, status.Warnings, 1)
+	var nodeErr *NodeError
+	require.ErrorAs(t, status.Warnings[0], &nodeErr)
+	assert.Equal(t, EventType("audit.login"), nodeErr.EventType)
+	assert.Equal(t, EventType("audit.*"), nodeErr.PipelineEventType)
+
+	l.Lock()
+	defer l.Unlock()
+	for id, want := range map[NodeID]EventType{"exact": "audit.login", "bad": "audit.*"} {
+		assert.Equal(t, EventType("audit.login"), observed[id].EventType, id)
+		assert.Equal(t, want, observed[id].PipelineEventType, id)
+	}
+}
+
+func TestBroker_Send_PatternThresholds(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	tests := map[string]struct {
+		setup   func(t *testing.T, b *Broker)
+		wantErr string
+	}{
+		"failing-wildcard-without-threshold": {
+			setup: func(t *testing.T, b *Broker) {
+				require.NoError(t, b.RegisterPipeline(Pipeline{PipelineID: "exact", EventType: "audit.login", NodeIDs: []NodeID{"formatter", "exact"}}))
+				require.NoError(t, b.RegisterPipeline(Pipeline{PipelineID: "all", EventType: AllEventTypes, NodeIDs: []NodeID{"formatter", "bad"}}))
+				require.NoError(t, b.SetSuccessThresholdSinks("audit.login", 1))
+			},
+		},
+		"failing-wildcard-with-threshold": {
+			setup: func(t *testing.T, b *Broker) {
+				require.NoError(t, b.RegisterPipeline(Pipeline{PipelineID: "exact", EventType: "audit.login", NodeIDs: []NodeID{"formatter", "exact"}}))
+				require.NoError(t, b.RegisterPipeline(Pipeline{PipelineID: "all", EventType: AllEventTypes, NodeIDs: []NodeID{"formatter", "bad"}}))
+				require.NoError(t, b.SetSuccessThresholdSinks("audit.login", 1))
+				require.NoError(t, b.SetSuccessThresholdSinks(AllEventTypes, 1))
+			},
+			wantErr: "event not processed by enough 'sink' nodes",
+		},
+		// The threshold of the exact type isn't met by the pattern's pipelines.
+		"failing-exact": {
+			setup: func(t *testing.T, b *Broker) {
+				require.NoError(t, b.RegisterPipeline(Pipeline{PipelineID: "exact", EventType: "audit.login", NodeIDs: []NodeID{"formatter", "bad"}}))
+				require.NoError(t, b.RegisterPipeline(Pipeline{PipelineID: "prefix", EventType: "audit.*", NodeIDs: []NodeID{"formatter", "prefix"}}))
+				require.NoError(t, b.SetSuccessThresholdSinks("audit.login", 1))
+			},
+			wantErr: "event not processed by enough 'sink' nodes",
+		},
+		"required-pattern-pipeline": {
+			setup: func(t *testing.T, b *Broker) {
+				require.NoError(t, b.RegisterPipeline(Pipeline{PipelineID: "exact", EventType: "audit.login", NodeIDs: []NodeID{"formatter", "exact"}}))
+				require.NoError(t, b.RegisterPipeline(Pipeline{PipelineID: "prefix", EventType: "audit.*", NodeIDs: []NodeID{"formatter", "bad"}}, WithRequiredPipeline()))
+			},
+			wantErr: `required pipeline failed: pipeline ID "prefix"`,
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			nodes, _ := patternTestNodes()
+			b := newTestBroker(t, nodes, nil)
+			tc.setup(t, b)
+
+			status, err := b.Send(ctx, "audit.login", "payload")
+			require.Len(t, status.Warnings, 1)
+			if tc.wantErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, tc.wantErr)
+		})
+	}
+}
+
+func TestBroker_PatternSettings(t *testing.T) {
+	t.Parallel()
+
+	b, err := NewBroker()
+	require.NoError(t, err)
+
+	wantErr := `event type "audit..*" is not a valid pattern, it must be "*" or end in ".*"`
+	assert.EqualError(t, b.SetSuccessThreshold("audit..*", 1), wantErr)
+	assert.EqualError(t, b.SetSuccessThresholdSinks("audit..*", 1), wantErr)
+	assert.EqualError(t, b.SetDeadLetterEventType("audit..*", "dead"), wantErr)
+	assert.EqualError(t, b.SetDeadLetterEventType("audit.*", "dead.*"), `dead-letter event type "dead.*" cannot be a pattern`)
+
+	require.NoError(t, b.SetSuccessThreshold("audit.*", 1))
+	threshold, ok := b.SuccessThreshold("audit.*")
+	require.True(t, ok)
+	assert.Equal(t, 1, threshold)
+}
+
+func TestBroker_IsAnyPipelineRegistered_Pattern(t *testing.T) {
+	t.Parallel()
+
+	nodes, _ := patternTestNodes()
+	b := newTestBroker(t, nodes, nil)
+	assert.False(t, b.IsAnyPipelineRegistered("audit.login"))
+
+	require.NoError(t, b.RegisterPipeline(Pipeline{PipelineID: "prefix", EventType: "audit.*", NodeIDs: []NodeID{"formatter", "prefix"}}))
+	assert.True(t, b.IsAnyPipelineRegistered("audit.login"))
+	assert.False(t, b.IsAnyPipelineRegistered("audit"))
+	assert.False(t, b.IsAnyPipelineRegistered("other"))
+
+	require.NoError(t, b.RegisterPipeline(Pipeline{PipelineID: "all", EventType: AllEventTypes, NodeIDs: []NodeID{"formatter", "all"}}))
+	assert.True(t, b.IsAnyPipelineRegistered("other"))
+
+	require.NoError(t, b.RemovePipeline(AllEventTypes, "all"))
+	assert.False(t, b.IsAnyPipelineRegistered("other"))
+}
@@ -0,0 +1,122 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package eventlogger
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+)
+
+// PayloadTypeError is the error returned when sending an Event whose payload
+// doesn't match the payload type registered for its EventType (see
+// RegisterPayloadType).  It wraps ErrInvalidPayloadType.
+type PayloadTypeError struct {
+	// EventType of the Event
+	EventType EventType
+
+	// Want is the payload type registered for the EventType
+	Want reflect.Type
+
+	// Got is the type of the payload, or nil when the payload was nil
+	Got reflect.Type
+}
+
+// Error describes the mismatched types.
+func (e *PayloadTypeError) Error() string {
+	return fmt.Sprintf("%s: payload of type %v does not match type %v registered for EventType %q", ErrInvalidPayloadType, e.Got, e.Want, e.EventType)
+}
+
+// Unwrap returns ErrInvalidPayloadType.
+func (e *PayloadTypeError) Unwrap() error {
+	return ErrInvalidPayloadType
+}
+
+// RegisterPayloadType binds the EventType to the payload type T, so that the
+// Broker rejects events of the type whose payload isn't a T with a
+// *PayloadTypeError.  When T is an interface type, payloads which implement it
+// are accepted.  EventTypes without a payload type accept any payload.
+//
+// A payload type can't be registered for a pattern, or replaced by a different
+// type once registered.
+func RegisterPayloadType[T any](b *Broker, t EventType) error {
+	switch {
+	case b == nil:
+		return fmt.Errorf("broker cannot be nil: %w", ErrInvalidParameter)
+	case t == "":
+		return fmt.Errorf("event type cannot be empty: %w", ErrInvalidParameter)
+	case t.IsPattern():
+		return fmt.Errorf("cannot register payload type for pattern EventType %q: %w", t, ErrInvalidParameter)
+	}
+
+	want := reflect.TypeOf((*T)(nil)).Elem()
+	if existing, loaded := b.payloadTypes.LoadOrStore(t, want); loaded && existing != want {
+		return fmt.Errorf("payload type %v is already registered for EventType %q: %w", existing, t, ErrInvalidParameter)
+	}
+	return nil
+}
+
+// PayloadType returns the payload type registered for the EventType, along
+// with a boolean indicating whether one was registered.
+func (b *Broker) PayloadType(t EventType) (reflect.Type, bool) {
+	want, ok := b.payloadTypes.Load(t)
+	if !ok {
+		return nil, false
+	}
+	return want.(reflect.Type), true
+}
+
+// Send is a type-safe alternative to Broker.Send for an EventType whose
+// payload type has been registered (see RegisterPayloadType): the payload is a
+// T at compile time, and T must match the registered type.
+//
+// Accepted options: the same as Broker.SendWithOptions.
+func Send[T any](ctx context.Context, b *Broker, t EventType, payload T, opt ...Option) (Status, error) {
+	if err := checkTypedSend[T](b, t); err != nil {
+		return Status{}, fmt.Errorf("cannot send event: %w", err)
+	}
+	return b.SendWithOptions(ctx, t, payload, opt...)
+}
+
+// SendAsync is a type-safe alternative to Broker.SendAsync, see Send.
+//
+// Accepted options: the same as Broker.SendWithOptions.
+func SendAsync[T any](ctx context.Context, b *Broker, t EventType, payload T, opt ...Option) (*SendFuture, error) {
+	if err := checkTypedSend[T](b, t); err != nil {
+		return nil, fmt.Errorf("cannot send event: %w", err)
+	}
+	return b.SendAsync(ctx, t, payload, opt...)
+}
+
+// checkTypedSend checks that T is the payload type registered for the
+// EventType.
+func checkTypedSend[T any](b *Broker, t EventType) error {
+	got := reflect.TypeOf((*T)(nil)).Elem()
+	want, ok := b.PayloadType(t)
+	switch {
+	case !ok:
+		return fmt.Errorf("no payload type registered for EventType %q: %w", t, ErrInvalidPayloadType)
+	case got != want:
+		return &PayloadTypeError{EventType: t, Want: want, Got: got}
+	}
+	return nil
+}
+
+// checkPayloadType checks the payload against the payload type registered
+// for the EventType, if any.
+func (b *Broker) checkPayloadType(t EventType, payload interface{}) error {
+	want, ok := b.PayloadType(t)
+	if !ok {
+		return nil
+	}
+
+	got := reflect.TypeOf(payload)
+	switch {
+	case got == want:
+		return nil
+	case got != nil && want.Kind() == reflect.Interface && got.Implements(want):
+		return nil
+	}
+	return &PayloadTypeError{EventType: t, Want: want, Got: got}
+}
@@ -0,0 +1,170 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package eventlogger
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testLogin struct {
+	User string
+}
+
+func (l testLogin) String() string { return l.User }
+
+func TestRegisterPayloadType(t *testing.T) {
+	t.Parallel()
+
+	b, err := NewBroker()
+	require.NoError(t, err)
+
+	_, ok := b.PayloadType("login")
+	assert.False(t, ok)
+
+	require.NoError(t, RegisterPayloadType[testLogin](b, "login"))
+	// Registering the same type again is allowed.
+	require.NoError(t, RegisterPayloadType[testLogin](b, "login"))
+	got, ok := b.PayloadType("login")
+	require.True(t, ok)
+	assert.Equal(t, reflect.TypeOf(testLogin{}), got)
+
+	tests := map[string]struct {
+		register func() error
+		wantErr  string
+	}{
+		"different-type": {
+			register: func() error { return RegisterPayloadType[*testLogin](b, "login") },
+			wantErr:  `payload type eventlogger.testLogin is already registered for EventType "login": invalid parameter`,
+		},
+		"pattern": {
+			register: func() error { return RegisterPayloadType[testLogin](b, "audit.*") },
+			wantErr:  `cannot register payload type for pattern EventType "audit.*": invalid parameter`,
+		},
+		"empty-event-type": {
+			register: func() error { return RegisterPayloadType[testLogin](b, "") },
+			wantErr:  "event type cannot be empty: invalid parameter",
+		},
+		"nil-broker": {
+			register: func() error { return RegisterPayloadType[testLogin](nil, "login") },
+			wantErr:  "broker cannot be nil: invalid parameter",
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			err := tc.register()
+			require.ErrorIs(t, err, ErrInvalidParameter)
+			assert.EqualError(t, err, tc.wantErr)
+		})
+	}
+}
+
+func TestBroker_Send_PayloadType(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	got := make(chan *Event, 1)
+	b := newTestBroker(t, map[NodeID]Node{"sink": receivingSink(got)}, []Pipeline{testPipeline})
+	require.NoError(t, b.RegisterPipeline(Pipeline{PipelineID: "p", EventType: "login", NodeIDs: []NodeID{"formatter", "sink"}}))
+	require.NoError(t, b.RegisterPipeline(Pipeline{PipelineID: "p", EventType: "stringer", NodeIDs: []NodeID{"formatter", "sink"}}))
+	require.NoError(t, RegisterPayloadType[testLogin](b, "login"))
+	require.NoError(t, RegisterPayloadType[fmt.Stringer](b, "stringer"))
+
+	tests := map[string]struct {
+		eventType EventType
+		payload   interface{}
+		wantGot   reflect.Type
+	}{
+		"exact": {
+			eventType: "login",
+			payload:   testLogin{User: "alice"},
+		},
+		"pointer": {
+			eventType: "login",
+			payload:   &testLogin{User: "alice"},
+			wantGot:   reflect.TypeOf(&testLogin{}),
+		},
+		"other": {
+			eventType: "login",
+			payload:   "alice",
+			wantGot:   reflect.TypeOf(""),
+		},
+		"nil": {
+			eventType: "login",
+		},
+		"implements-interface": {
+			eventType: "stringer",
+			payload:   &testLogin{User: "alice"},
+		},
+		"nil-interface": {
+			eventType: "stringer",
+		},
+		// Event types without a payload type accept any payload.
+		"unregistered": {
+			eventType: "t",
+			payload:   42,
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			_, err := b.Send(ctx, tc.eventType, tc.payload)
+			if tc.wantGot == nil && tc.payload != nil {
+				require.NoError(t, err)
+				assert.Equal(t, tc.payload, (<-got).Payload)
+				return
+			}
+
+			require.ErrorIs(t, err, ErrInvalidPayloadType)
+			var typeErr *PayloadTypeError
+			require.ErrorAs(t, err, &typeErr)
+			assert.Equal(t, tc.eventType, typeErr.EventType)
+			assert.Equal(t, tc.wantGot, typeErr.Got)
+			want, _ := b.PayloadType(tc.eventType)
+			assert.Equal(t, want, typeErr.Want)
+		})
+	}
+
+	_, err := b.Send(ctx, "login", "alice")
+	assert.EqualError(t, err, `cannot send event: invalid payload type: payload of type string does not match type eventlogger.testLogin registered for EventType "login"`)
+}
+
+func TestSend_Generic(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	got := make(chan *Event, 1)
+	b := newTestBroker(t, map[NodeID]Node{"sink": receivingSink(got)}, []Pipeline{testPipeline}, WithAsyncQueue(1, 1))
+	t.Cleanup(func() { _ = b.StopAsync(context.Background()) })
+	require.NoError(t, b.RegisterPipeline(Pipeline{PipelineID: "p", EventType: "login", NodeIDs: []NodeID{"formatter", "sink"}}))
+	require.NoError(t, RegisterPayloadType[testLogin](b, "login"))
+
+	_, err := Send(ctx, b, "login", testLogin{User: "alice"})
+	require.NoError(t, err)
+	assert.Equal(t, testLogin{User: "alice"}, (<-got).Payload)
+
+	f, err := SendAsync(ctx, b, "login", testLogin{User: "bob"})
+	require.NoError(t, err)
+	_, err = f.Wait(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, testLogin{User: "bob"}, (<-got).Payload)
+
+	// T must be the registered type.
+	_, err = Send(ctx, b, "login", &testLogin{User: "alice"})
+	require.ErrorIs(t, err, ErrInvalidPayloadType)
+	assert.EqualError(t, err, `cannot send event: invalid payload type: payload of type *eventlogger.testLogin does not match type eventlogger.testLogin registered for EventType "login"`)
+
+	_, err = SendAsync(ctx, b, "t", "payload")
+	require.ErrorIs(t, err, ErrInvalidPayloadType)
+	assert.EqualError(t, err, `cannot send event: no payload type registered for EventType "t": invalid payload type`)
+}
@@ -0,0 +1,100 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package eventlogger
+
+import (
+	"fmt"
+)
+
+// SaturationPolicy is used to specify what the Broker should do when a node is
+// ready to process an event, but every worker in its pool is busy.
+type SaturationPolicy string
+
+const (
+	// SaturationCallerRuns processes the node using the goroutine which was
+	// handing it to the pool: the goroutine which called Send for the first
+	// node of a pipeline, or the worker which processed the node's parent.
+	// Sending slows down to match the rate at which nodes are processed, and
+	// no events are dropped, but Send doesn't return until a node it's
+	// processing has returned, even once its context is done.
+	SaturationCallerRuns SaturationPolicy = "CallerRuns"
+	// SaturationReject doesn't process the node, or any nodes which follow it
+	// in the pipeline.  A warning wrapping ErrWorkerPoolSaturated is added to
+	// the Status (and the node is dead-lettered, when configured).
+	SaturationReject SaturationPolicy = "Reject"
+)
+
+// workerPool limits the number of goroutines which process nodes at once.
+// Workers are started on demand and exit once they've processed a node, so a
+// Broker with a pool doesn't need to be stopped.
+type workerPool struct {
+	// workers holds a value for each busy worker
+	workers chan struct{}
+	policy  SaturationPolicy
+}
+
+// newWorkerPool creates a workerPool which runs at most size funcs at once.
+func newWorkerPool(size int, policy SaturationPolicy) *workerPool {
+	return &workerPool{
+		workers: make(chan struct{}, size),
+		policy:  policy,
+	}
+}
+
+// run calls fn using a worker if one is free.  Otherwise fn is handled
+// according to the pool's SaturationPolicy: it's either called by the caller,
+// or not at all in which case false is returned.
+func (p *workerPool) run(fn func()) bool {
+	select {
+	case p.workers <- struct{}{}:
+		go func() {
+			defer func() { <-p.workers }()
+			fn()
+		}()
+		return true
+	default:
+	}
+
+	if p.policy == SaturationReject {
+		return false
+	}
+	fn()
+	return true
+}
+
+// WithWorkerPool configures the option that limits the number of goroutines
+// which the Broker starts to process nodes to size, regardless of how many events
+// are being sent.  What happens when every worker is busy depends on the
+// SaturationPolicy configured via WithSaturationPolicy (default:
+// SaturationCallerRuns).  By default, every node of every event is processed
+// by its own goroutine.
+//
+// Nodes with a timeout (see WithNodeTimeout and WithPipelineTimeout) still use
+// an additional goroutine, so that they can be abandoned.
+func WithWorkerPool(size int) Option {
+	return func(o *options) error {
+		if size < 1 {
+			return fmt.Errorf("worker pool size must be greater than 0: %w", ErrInvalidParameter)
+		}
+		o.withWorkerPoolSize = size
+		return nil
+	}
+}
+
+// WithSaturationPolicy configures the option that determines what the Broker
+// does when every worker in its pool is busy, see WithWorkerPool.
+func WithSaturationPolicy(policy SaturationPolicy) Option {
+	return func(o *options) error {
+		var err error
+
+		switch policy {
+		case SaturationCallerRuns, SaturationReject:
+			o.withSaturationPolicy = policy
+		default:
+			err = fmt.Errorf("'%s' is not a valid saturation policy: %w", policy, ErrInvalidParameter)
+		}
+
+		return err
+	}
+}
@@ -0,0 +1,224 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package eventlogger
+
+import (
+	"context"
+	"runtime"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithWorkerPool(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		opts    []Option
+		wantErr string
+	}{
+		"valid": {
+			opts: []Option{WithWorkerPool(1), WithSaturationPolicy(SaturationReject)},
+		},
+		"zero-size": {
+			opts:    []Option{WithWorkerPool(0)},
+			wantErr: "cannot create broker: worker pool size must be greater than 0: invalid parameter",
+		},
+		"bad-policy": {
+			opts:    []Option{WithSaturationPolicy("Wait")},
+			wantErr: "cannot create broker: 'Wait' is not a valid saturation policy: invalid parameter",
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			b, err := NewBroker(tc.opts...)
+			if tc.wantErr != "" {
+				require.EqualError(t, err, tc.wantErr)
+				require.Nil(t, b)
+				return
+			}
+			require.NoError(t, err)
+			require.NotNil(t, b.pool)
+		})
+	}
+}
+
+func TestBroker_WorkerPool_CallerRuns(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var processed atomic.Int32
+	sink := blockingSink(started, release, &processed)
+	b := newTestBroker(t, map[NodeID]Node{"sink": sink}, []Pipeline{testPipeline}, WithWorkerPool(1))
+	require.NoError(t, b.SetSuccessThresholdSinks("t", 1))
+
+	errs := make(chan error, 2)
+	send := func() {
+		_, err := b.Send(ctx, "t", "payload")
+		errs <- err
+	}
+
+	// The first event occupies the only worker, so the second is processed by
+	// the goroutine sending it.
+	go send()
+	<-started
+	go send()
+	<-started
+
+	// Neither Send returns until the sink has processed its event.
+	select {
+	case err := <-errs:
+		t.Fatalf("send returned before the sink finished: %v", err)
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	close(release)
+	require.NoError(t, <-errs)
+	require.NoError(t, <-errs)
+	assert.Equal(t, int32(2), processed.Load())
+}
+
+func TestBroker_WorkerPool_Reject(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var processed atomic.Int32
+	sink := blockingSink(started, release, &processed)
+	b := newTestBroker(t, map[NodeID]Node{"sink": sink}, []Pipeline{testPipeline}, WithWorkerPool(1), WithSaturationPolicy(SaturationReject))
+	require.NoError(t, b.SetSuccessThresholdSinks("t", 1))
+
+	type result struct {
+		status Status
+		err    error
+	}
+	first := make(chan result, 1)
+	go func() {
+		s, err := b.Send(ctx, "t", "first")
+		first <- result{s, err}
+	}()
+	<-started
+
+	status, err := b.Send(ctx, "t", "second")
+	require.Error(t, err)
+	require.Len(t, status.Warnings, 1)
+	assert.ErrorIs(t, status.Warnings[0], ErrWorkerPoolSaturated)
+	assert.EqualError(t, status.Warnings[0], `node ID "formatter" (JSONFormatter): worker pool saturated: not processed in pipeline ID "p"`)
+	assert.Empty(t, status.CompleteSinks())
+
+	close(release)
+	r := <-first
+	require.NoError(t, r.err)
+	assert.Equal(t, []NodeID{"sink"}, r.status.CompleteSinks())
+	assert.Equal(t, int32(1), processed.Load())
+}
+
+// TestBroker_WorkerPool_Load sends events concurrently through a pipeline which
+// fans out to several sinks, measuring the number of goroutines and the
+// latency of Send with and without a worker pool.  It isn't run in parallel,
+// so that other tests don't affect the number of goroutines.
+func TestBroker_WorkerPool_Load(t *testing.T) {
+	const (
+		senders = 50
+		events  = 20
+		sinks   = 4
+		size    = 8
+	)
+
+	measure := func(t *testing.T, opt ...Option) (int, []time.Duration) {
+		ctx := context.Background()
+		b, err := NewBroker(opt...)
+		require.NoError(t, err)
+		require.NoError(t, b.RegisterNode("formatter", &JSONFormatter{}))
+
+		edges := map[NodeID][]NodeID{}
+		var processed atomic.Int32
+		for i := 0; i < sinks; i++ {
+			id := NodeID("sink" + string(rune('a'+i)))
+			require.NoError(t, b.RegisterNode(id, &testActionNode{
+				nodeType: NodeTypeSink,
+				action: func(context.Context, *Event) (*Event, error) {
+					time.Sleep(100 * time.Microsecond)
+					processed.Add(1)
+					return nil, nil
+				},
+			}))
+			edges["formatter"] = append(edges["formatter"], id)
+		}
+		require.NoError(t, b.RegisterPipeline(Pipeline{PipelineID: "p", EventType: "t", Edges: edges}))
+		require.NoError(t, b.SetSuccessThresholdSinks("t", sinks))
+
+		baseline := runtime.NumGoroutine()
+		var peak atomic.Int64
+		stop := make(chan struct{})
+		sampled := make(chan struct{})
+		go func() {
+			defer close(sampled)
+			for {
+				if n := int64(runtime.NumGoroutine()); n > peak.Load() {
+					peak.Store(n)
+				}
+				select {
+				case <-stop:
+					return
+				case <-time.After(50 * time.Microsecond):
+				}
+			}
+		}()
+
+		var l sync.Mutex
+		var latencies []time.Duration
+		var wg sync.WaitGroup
+		for i := 0; i < senders; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < events; j++ {
+					start := time.Now()
+					_, err := b.Send(ctx, "t", j)
+					d := time.Since(start)
+					assert.NoError(t, err)
+
+					l.Lock()
+					latencies = append(latencies, d)
+					l.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+		close(stop)
+		<-sampled
+
+		require.Equal(t, int32(senders*events*sinks), processed.Load())
+		require.NoError(t, b.Close(ctx))
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+		return int(peak.Load()) - baseline, latencies
+	}
+
+	percentile := func(latencies []time.Duration, p int) time.Duration {
+		return latencies[(len(latencies)-1)*p/100]
+	}
+
+	unbounded, unboundedLatencies := measure(t)
+	bounded, boundedLatencies := measure(t, WithWorkerPool(size))
+
+	t.Logf("without pool: peak goroutines=%d, p50=%s, p99=%s", unbounded, percentile(unboundedLatencies, 50), percentile(unboundedLatencies, 99))
+	t.Logf("with pool of %d: peak goroutines=%d, p50=%s, p99=%s", size, bounded, percentile(boundedLatencies, 50), percentile(boundedLatencies, 99))
+
+	// Besides the senders and the workers, there's only the goroutine sampling
+	// the number of goroutines.
+	assert.LessOrEqual(t, bounded, senders+size+1)
+}
@@ -0,0 +1,178 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package eventlogger
+
+import (
+	"fmt"
+)
+
+// registry holds the nodes and graphs registered with a Broker.  It's
+// separate from the Broker so that a Transaction can stage changes to a copy
+// of it.
+type registry struct {
+	nodes  map[NodeID]*nodeUsage
+	graphs map[EventType]*graph
+
+	// observer is notified after every call to Node.Process, when configured.
+	observer Observer
+
+	// pool processes nodes, when configured.
+	pool *workerPool
+
+	// panicPolicy determines whether panics from nodes are recovered.
+	panicPolicy PanicPolicy
+}
+
+// graph returns the graph for the EventType, creating it if required.
+// This function assumes that the caller holds a lock.
+func (r *registry) graph(t EventType) *graph {
+	g, ok := r.graphs[t]
+	if !ok {
+		g = r.newGraph(t)
+		r.graphs[t] = g
+	}
+	return g
+}
+
+// newGraph creates an empty graph for the EventType, without adding it.
+func (r *registry) newGraph(t EventType) *graph {
+	return &graph{eventType: t, observer: r.observer, pool: r.pool, panicPolicy: r.panicPolicy}
+}
+
+// registerNode assigns a node ID to a node, see Broker.RegisterNode.
+// This function assumes that the caller holds a lock.
+func (r *registry) registerNode(id NodeID, node Node, opts options) error {
+	nr := &nodeUsage{
+		node:               node,
+		referenceCount:     0,
+		registrationPolicy: opts.withNodeRegistrationPolicy,
+		settings: nodeSettings{
+			timeout:     opts.withNodeTimeout,
+			retryPolicy: opts.withNodeRetryPolicy,
+		},
+	}
+
+	// Check if this node is already registered, if so maintain reference count
+	existing, exists := r.nodes[id]
+	if exists {
+		switch existing.registrationPolicy {
+		case AllowOverwrite:
+			nr.referenceCount = existing.referenceCount
+		case DenyOverwrite:
+			return fmt.Errorf("node ID %q is already registered, configured policy prevents overwriting", id)
+		}
+	}
+
+	r.nodes[id] = nr
+
+	return nil
+}
+
+// registerPipeline adds a pipeline, see Broker.RegisterPipeline.  The
+// pipeline must already have been validated.
+// This function assumes that the caller holds a lock.
+func (r *registry) registerPipeline(def Pipeline, opts options) error {
+	p, err := r.linkPipeline(def, opts)
+	if err != nil {
+		return err
+	}
+	r.storePipeline(r.graph(def.EventType), def.PipelineID, p)
+	return nil
+}
+
+// linkPipeline links the nodes of a pipeline, ready for it to be stored by
+// storePipeline.  The pipeline must already have been validated.
+// This function assumes that the caller holds a lock.
+func (r *registry) linkPipeline(def Pipeline, opts options) (*registeredPipeline, error) {
+	// The graph is only added once the pipeline is stored, so that an invalid
+	// pipeline doesn't leave an empty graph behind.
+	g, ok := r.graphs[def.EventType]
+	if !ok {
+		g = r.newGraph(def.EventType)
+	}
+	var err error
+
+	// Get the configured policy
+	pol := AllowOverwrite
+	g.roots.Range(func(key PipelineID, v *registeredPipeline) bool {
+		if key == def.PipelineID {
+			pol = v.registrationPolicy
+			return false
+		}
+		return true
+	})
+
+	if pol == DenyOverwrite {
+		return nil, fmt.Errorf("pipeline ID %q is already registered, configured policy prevents overwriting", def.PipelineID)
+	}
+
+	// Gather the registered nodes, so they can be referenced for this pipeline.
+	nodes := make(map[NodeID]Node)
+	for _, n := range def.nodeIDs() {
+		nodeUsage, ok := r.nodes[n]
+		if !ok {
+			return nil, fmt.Errorf("node ID %q not registered", n)
+		}
+		nodes[n] = nodeUsage.node
+	}
+
+	var root *linkedNode
+	switch {
+	case len(def.Edges) > 0:
+		root, err = linkEdges(nodes, def.Edges)
+	default:
+		linear := make([]Node, len(def.NodeIDs))
+		for i, n := range def.NodeIDs {
+			linear[i] = nodes[n]
+		}
+		root, err = linkNodes(linear, def.NodeIDs)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = g.doValidate(nil, root, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	requiredSinks, err := linkRequiredSinks(root, opts.withRequiredSinks)
+	if err != nil {
+		return nil, err
+	}
+
+	// Apply the options each node was registered with, and name it.
+	root.walk(func(l *linkedNode) {
+		l.settings = r.nodes[l.nodeID].settings
+		l.name = NewNodeController(l.node).Name()
+	})
+
+	// Create the pipeline registration using the optional policy (or default).
+	pipelineReg := &registeredPipeline{
+		rootNode:           root,
+		registrationPolicy: opts.withPipelineRegistrationPolicy,
+		timeout:            opts.withPipelineTimeout,
+		required:           opts.withRequiredPipeline,
+		requiredSinks:      requiredSinks,
+	}
+
+	return pipelineReg, nil
+}
+
+// storePipeline stores a pipeline linked by linkPipeline in the graph, which
+// must not be routed to yet (see replaceGraphs).
+// This function assumes that the caller holds a lock.
+func (r *registry) storePipeline(g *graph, id PipelineID, p *registeredPipeline) {
+	// Store the pipeline and then update the reference count of the nodes in that pipeline.
+	// Nodes which appear more than once (e.g. in several branches) are only
+	// counted once, matching the nodes which are released by RemovePipelineAndNodes.
+	g.roots.Store(id, p)
+	for id := range p.rootNode.flatten() {
+		nodeUsage, ok := r.nodes[id]
+		// We can be optimistic about this as linkPipeline would have already errored.
+		if ok {
+			nodeUsage.referenceCount++
+		}
+	}
+}
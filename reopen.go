@@ -0,0 +1,188 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package eventlogger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+// ContextReopener is an optional interface for a Node which can use a context
+// when reopening, such as to bound how long it takes.  When a Node implements
+// it, the Broker calls ReopenContext rather than Reopen.
+type ContextReopener interface {
+	ReopenContext(ctx context.Context) error
+}
+
+// Reopen the Node, using ReopenContext if it implements the ContextReopener
+// interface (if required using the NodeUnwrapper interface to unwrap it), or
+// Reopen otherwise.
+func (nc *NodeController) Reopen(ctx context.Context) error {
+	if r, ok := unwrapNode[ContextReopener](nc.n); ok {
+		return r.ReopenContext(ctx)
+	}
+	return nc.n.Reopen()
+}
+
+// WithReopenConcurrency configures the option that determines how many nodes
+// may be reopened at once (default: 1, which reopens them in turn).
+func WithReopenConcurrency(n int) Option {
+	return func(o *options) error {
+		if n < 1 {
+			return fmt.Errorf("reopen concurrency must be greater than 0: %w", ErrInvalidParameter)
+		}
+		o.withReopenConcurrency = n
+		return nil
+	}
+}
+
+// Reopen calls every registered Node's Reopen() function.  The intention is to
+// ask all nodes to reopen any files they have open.  This is typically used as
+// part of log rotation: after rotating, the rotator sends a signal to the
+// application, which then would invoke this method.  Another typically use-case
+// is to have all Nodes reevaluated any external configuration they might have.
+//
+// Each node is reopened exactly once, regardless of how many pipelines
+// reference it, using ReopenContext when it implements ContextReopener.  Every
+// node is reopened even when others fail, and the errors are aggregated (as
+// multierror.Error) in node ID order, each identifying its node.  The nodes are
+// reopened without holding the Broker's lock, so that a slow node doesn't
+// block registering nodes and pipelines.
+func (b *Broker) Reopen(ctx context.Context) error {
+	return b.ReopenWithOptions(ctx)
+}
+
+// ReopenWithOptions is Reopen, with options which determine how the nodes are
+// reopened.
+//
+// Accepted options: WithReopenConcurrency.
+func (b *Broker) ReopenWithOptions(ctx context.Context, opt ...Option) error {
+	opts, err := getOpts(opt...)
+	if err != nil {
+		return fmt.Errorf("cannot reopen nodes: %w", err)
+	}
+
+	b.lock.RLock()
+	nodes := make(map[NodeID]Node, len(b.nodes))
+	for id, usage := range b.nodes {
+		nodes[id] = usage.node
+	}
+	b.lock.RUnlock()
+
+	return reopenNodes(ctx, nodes, opts.withReopenConcurrency)
+}
+
+// ReopenPipeline reopens each node of the pipeline exactly once, see Reopen.
+//
+// Accepted options: WithReopenConcurrency.
+func (b *Broker) ReopenPipeline(ctx context.Context, t EventType, id PipelineID, opt ...Option) error {
+	switch {
+	case t == "":
+		return errors.New("event type cannot be empty")
+	case id == "":
+		return errors.New("pipeline ID cannot be empty")
+	}
+
+	opts, err := getOpts(opt...)
+	if err != nil {
+		return fmt.Errorf("cannot reopen pipeline: %w", err)
+	}
+
+	nodes, err := b.pipelineNodes(t, id)
+	if err != nil {
+		return err
+	}
+	return reopenNodes(ctx, nodes, opts.withReopenConcurrency)
+}
+
+// pipelineNodes returns the nodes of the pipeline by ID.
+func (b *Broker) pipelineNodes(t EventType, id PipelineID) (map[NodeID]Node, error) {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+
+	g, ok := b.graphs[t]
+	if !ok {
+		return nil, fmt.Errorf("no graph for EventType %s", t)
+	}
+	p, ok := g.roots.Load(id)
+	if !ok {
+		return nil, fmt.Errorf("pipeline ID %q not found for EventType %s", id, t)
+	}
+	return p.nodes(), nil
+}
+
+// ReopenNode reopens the registered node, see Reopen.
+func (b *Broker) ReopenNode(ctx context.Context, id NodeID) error {
+	b.lock.RLock()
+	usage, ok := b.nodes[id]
+	b.lock.RUnlock()
+
+	if !ok {
+		return fmt.Errorf("unable to reopen node ID %q: %w", id, ErrNodeNotFound)
+	}
+	return reopenNodes(ctx, map[NodeID]Node{id: usage.node}, 1)
+}
+
+// nodes returns the nodes of the pipeline by ID.
+func (p *registeredPipeline) nodes() map[NodeID]Node {
+	nodes := make(map[NodeID]Node)
+	p.rootNode.walk(func(l *linkedNode) {
+		nodes[l.nodeID] = l.node
+	})
+	return nodes
+}
+
+// reopenNodes reopens the nodes, at most concurrency at a time, and returns
+// their errors in node ID order.  Nodes which haven't been started when ctx is
+// done aren't reopened, and fail with the context's error.
+func reopenNodes(ctx context.Context, nodes map[NodeID]Node, concurrency int) error {
+	return forEachNode(ctx, nodes, concurrency, "unable to reopen node ID %q: %w", func(nc *NodeController) error {
+		return nc.Reopen(ctx)
+	})
+}
+
+// forEachNode calls fn for each of the nodes, at most concurrency at a time,
+// and returns their errors (as multierror.Error) in node ID order, each
+// formatted with the node's ID and error.  Nodes which haven't been started
+// when ctx is done are skipped, and fail with the context's error.
+func forEachNode(ctx context.Context, nodes map[NodeID]Node, concurrency int, format string, fn func(nc *NodeController) error) error {
+	ids := make([]NodeID, 0, len(nodes))
+	for id := range nodes {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	errs := make([]error, len(ids))
+	workers := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, id := range ids {
+		select {
+		case workers <- struct{}{}:
+		case <-ctx.Done():
+			errs[i] = ctx.Err()
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, n Node) {
+			defer wg.Done()
+			defer func() { <-workers }()
+			errs[i] = fn(NewNodeController(n))
+		}(i, nodes[id])
+	}
+	wg.Wait()
+
+	var errors *multierror.Error
+	for i, err := range errs {
+		if err != nil {
+			errors = multierror.Append(errors, fmt.Errorf(format, ids[i], err))
+		}
+	}
+	return errors.ErrorOrNil()
+}
@@ -0,0 +1,245 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package eventlogger
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingReopener is a sink which counts how many times it's reopened, and
+// optionally fails or blocks until released.
+type countingReopener struct {
+	testActionNode
+	reopened atomic.Int32
+	err      error
+	release  chan struct{}
+	active   *atomic.Int32
+	peak     *atomic.Int32
+}
+
+func (r *countingReopener) Reopen() error {
+	r.reopened.Add(1)
+	if r.active != nil {
+		n := r.active.Add(1)
+		defer r.active.Add(-1)
+		for {
+			peak := r.peak.Load()
+			if n <= peak || r.peak.CompareAndSwap(peak, n) {
+				break
+			}
+		}
+	}
+	if r.release != nil {
+		<-r.release
+	}
+	return r.err
+}
+
+// contextReopener records the context it was reopened with.
+type contextReopener struct {
+	testActionNode
+	ctx context.Context
+}
+
+func (r *contextReopener) Reopen() error {
+	return errors.New("Reopen called rather than ReopenContext")
+}
+
+func (r *contextReopener) ReopenContext(ctx context.Context) error {
+	r.ctx = ctx
+	return nil
+}
+
+// reopenTestNodes returns the sinks "a" and "d", and "b" and "c" which fail to
+// reopen.
+func reopenTestNodes() map[NodeID]*countingReopener {
+	return map[NodeID]*countingReopener{
+		"a": {},
+		"b": {err: errors.New("b failed")},
+		"c": {err: errors.New("c failed")},
+		"d": {},
+	}
+}
+
+// reopenTestPipelines share the sinks "a" and "c" between the pipelines "p1"
+// and "p2" of type "t", and don't reference "d".
+var reopenTestPipelines = []Pipeline{
+	{PipelineID: "p1", EventType: "t", Edges: map[NodeID][]NodeID{"formatter": {"a", "b", "c"}}},
+	{PipelineID: "p2", EventType: "t", Edges: map[NodeID][]NodeID{"formatter": {"a", "c"}}},
+	{PipelineID: "p3", EventType: "other", NodeIDs: []NodeID{"formatter", "a"}},
+}
+
+func TestBroker_Reopen(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	nodes := reopenTestNodes()
+	b := newTestBroker(t, nodes, reopenTestPipelines)
+
+	err := b.Reopen(ctx)
+	require.Error(t, err)
+	assert.Equal(t, "2 errors occurred:\n"+
+		"\t* unable to reopen node ID \"b\": b failed\n"+
+		"\t* unable to reopen node ID \"c\": c failed\n\n", err.Error())
+
+	// Every node is reopened once, including "d" which isn't in a pipeline.
+	for id, n := range nodes {
+		assert.Equal(t, int32(1), n.reopened.Load(), id)
+	}
+}
+
+func TestBroker_ReopenPipeline(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	nodes := reopenTestNodes()
+	b := newTestBroker(t, nodes, reopenTestPipelines)
+
+	err := b.ReopenPipeline(ctx, "t", "p2")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `unable to reopen node ID "c": c failed`)
+	assert.Equal(t, int32(1), nodes["a"].reopened.Load())
+	assert.Equal(t, int32(0), nodes["b"].reopened.Load())
+	assert.Equal(t, int32(1), nodes["c"].reopened.Load())
+
+	require.NoError(t, b.ReopenPipeline(ctx, "other", "p3"))
+	assert.Equal(t, int32(2), nodes["a"].reopened.Load())
+
+	assert.EqualError(t, b.ReopenPipeline(ctx, "t", "missing"), `pipeline ID "missing" not found for EventType t`)
+	assert.EqualError(t, b.ReopenPipeline(ctx, "missing", "p1"), "no graph for EventType missing")
+	assert.EqualError(t, b.ReopenPipeline(ctx, "t", ""), "pipeline ID cannot be empty")
+	assert.EqualError(t, b.ReopenPipeline(ctx, "t", "p1", WithReopenConcurrency(0)), "cannot reopen pipeline: reopen concurrency must be greater than 0: invalid parameter")
+}
+
+func TestBroker_ReopenNode(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	nodes := reopenTestNodes()
+	b := newTestBroker(t, nodes, reopenTestPipelines)
+
+	require.NoError(t, b.ReopenNode(ctx, "d"))
+	assert.Equal(t, int32(1), nodes["d"].reopened.Load())
+
+	err := b.ReopenNode(ctx, "b")
+	assert.EqualError(t, err, "1 error occurred:\n\t* unable to reopen node ID \"b\": b failed\n\n")
+
+	err = b.ReopenNode(ctx, "missing")
+	require.ErrorIs(t, err, ErrNodeNotFound)
+	assert.EqualError(t, err, `unable to reopen node ID "missing": node not found`)
+}
+
+func TestBroker_Reopen_Concurrency(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	var active, peak atomic.Int32
+	release := make(chan struct{})
+	b, err := NewBroker()
+	require.NoError(t, err)
+	for _, id := range []NodeID{"a", "b", "c", "d", "e"} {
+		require.NoError(t, b.RegisterNode(id, &countingReopener{release: release, active: &active, peak: &peak}))
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		assert.NoError(t, b.ReopenWithOptions(ctx, WithReopenConcurrency(3)))
+	}()
+	require.Eventually(t, func() bool { return active.Load() == 3 }, time.Second, time.Millisecond)
+	close(release)
+	wg.Wait()
+	assert.Equal(t, int32(3), peak.Load())
+}
+
+func TestBroker_Reopen_Unlocked(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	var active, peak atomic.Int32
+	release := make(chan struct{})
+	nodes := map[NodeID]*countingReopener{"a": {release: release, active: &active, peak: &peak}}
+	b := newTestBroker(t, nodes, []Pipeline{{PipelineID: "p", EventType: "t", NodeIDs: []NodeID{"formatter", "a"}}})
+
+	reopens := map[string]func() error{
+		"broker":   func() error { return b.Reopen(ctx) },
+		"pipeline": func() error { return b.ReopenPipeline(ctx, "t", "p") },
+		"node":     func() error { return b.ReopenNode(ctx, "a") },
+	}
+	for name, reopen := range reopens {
+		reopened := make(chan error)
+		go func() {
+			reopened <- reopen()
+		}()
+		require.Eventually(t, func() bool { return active.Load() == 1 }, time.Second, time.Millisecond, name)
+
+		// The registry can be changed while a node is being reopened.
+		require.NoError(t, b.RegisterNode(NodeID("other-"+name), &testActionNode{}), name)
+
+		release <- struct{}{}
+		require.NoError(t, <-reopened, name)
+	}
+}
+
+func TestBroker_Reopen_Context(t *testing.T) {
+	t.Parallel()
+
+	type ctxKey struct{}
+	ctx := context.WithValue(context.Background(), ctxKey{}, "value")
+
+	node := &contextReopener{}
+	b, err := NewBroker()
+	require.NoError(t, err)
+	require.NoError(t, b.RegisterNode("direct", node))
+	// A circuit breaker passes the context to the node it wraps.
+	wrapped := &contextReopener{}
+	cb, err := NewCircuitBreaker(wrapped, 1, time.Second)
+	require.NoError(t, err)
+	require.NoError(t, b.RegisterNode("wrapped", cb))
+
+	require.NoError(t, b.Reopen(ctx))
+	assert.Equal(t, "value", node.ctx.Value(ctxKey{}))
+	assert.Equal(t, "value", wrapped.ctx.Value(ctxKey{}))
+}
+
+func TestBroker_Reopen_Cancelled(t *testing.T) {
+	t.Parallel()
+
+	var active, peak atomic.Int32
+	release := make(chan struct{})
+	b, err := NewBroker()
+	require.NoError(t, err)
+	blocking := &countingReopener{release: release, active: &active, peak: &peak}
+	other := &countingReopener{}
+	require.NoError(t, b.RegisterNode("a", blocking))
+	require.NoError(t, b.RegisterNode("b", other))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 1)
+	go func() {
+		errs <- b.Reopen(ctx)
+	}()
+
+	// "b" isn't started while "a" is being reopened, and then the context is
+	// done.
+	require.Eventually(t, func() bool { return active.Load() == 1 }, time.Second, time.Millisecond)
+	cancel()
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+
+	err = <-errs
+	require.ErrorIs(t, err, context.Canceled)
+	assert.EqualError(t, err, "1 error occurred:\n\t* unable to reopen node ID \"b\": context canceled\n\n")
+	assert.Equal(t, int32(1), blocking.reopened.Load())
+	assert.Equal(t, int32(0), other.reopened.Load())
+}
@@ -0,0 +1,180 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package eventlogger
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// DeliveryPolicy describes what's required for an Event of an EventType to be
+// sent successfully.
+type DeliveryPolicy struct {
+	// SuccessThreshold of the event type (see Broker.SetSuccessThreshold)
+	SuccessThreshold int
+
+	// SuccessThresholdSinks of the event type (see
+	// Broker.SetSuccessThresholdSinks)
+	SuccessThresholdSinks int
+
+	// RequiredPipelines which must succeed (see WithRequiredPipeline), sorted
+	RequiredPipelines []PipelineID
+
+	// RequiredSinks of each pipeline which must succeed (see
+	// WithRequiredSinks), sorted
+	RequiredSinks map[PipelineID][]NodeID
+}
+
+// WithRequiredPipeline configures the option that marks a pipeline as
+// required, when registering a pipeline.  Send returns an error wrapping
+// ErrRequiredPipelineFailed whenever a node of a required pipeline fails to
+// process an event, or the pipeline doesn't finish before the context passed
+// to Send is done, regardless of the success thresholds.  A pipeline which
+// filters out an event has succeeded.
+func WithRequiredPipeline() Option {
+	return func(o *options) error {
+		o.withRequiredPipeline = true
+		return nil
+	}
+}
+
+// WithRequiredSinks configures the option that marks sinks of a pipeline as
+// required, when registering a pipeline.  Send returns an error wrapping
+// ErrRequiredPipelineFailed whenever a required sink, or a node between the
+// start of the pipeline and the sink, fails to process an event, regardless of
+// the success thresholds.  Other branches of the pipeline may fail.
+func WithRequiredSinks(ids ...NodeID) Option {
+	return func(o *options) error {
+		if len(ids) == 0 {
+			return fmt.Errorf("required sinks cannot be empty: %w", ErrInvalidParameter)
+		}
+		for _, id := range ids {
+			if id == "" {
+				return fmt.Errorf("required sink node ID cannot be empty: %w", ErrInvalidParameter)
+			}
+		}
+		o.withRequiredSinks = ids
+		return nil
+	}
+}
+
+// DeliveryPolicy returns the DeliveryPolicy of the EventType, along with a
+// boolean indicating whether the EventType was registered with the broker.
+func (b *Broker) DeliveryPolicy(t EventType) (DeliveryPolicy, bool) {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+
+	g, ok := b.graphs[t]
+	if !ok {
+		return DeliveryPolicy{}, false
+	}
+
+	policy := DeliveryPolicy{
+		SuccessThreshold:      g.successThreshold,
+		SuccessThresholdSinks: g.successThresholdSinks,
+	}
+	g.roots.Range(func(id PipelineID, p *registeredPipeline) bool {
+		if p.required {
+			policy.RequiredPipelines = append(policy.RequiredPipelines, id)
+		}
+		if len(p.requiredSinks) > 0 {
+			if policy.RequiredSinks == nil {
+				policy.RequiredSinks = make(map[PipelineID][]NodeID)
+			}
+			policy.RequiredSinks[id] = p.requiredSinkIDs()
+		}
+		return true
+	})
+	return policy, true
+}
+
+// linkRequiredSinks finds the required sinks of the pipeline, mapping the ID of
+// each to the IDs of the nodes which the event passes through to reach it,
+// including the sink itself.
+func linkRequiredSinks(root *linkedNode, ids []NodeID) (map[NodeID]map[NodeID]struct{}, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	linked := make(map[NodeID]*linkedNode)
+	parents := make(map[NodeID][]NodeID)
+	root.walk(func(l *linkedNode) {
+		linked[l.nodeID] = l
+		for _, child := range l.next {
+			parents[child.nodeID] = append(parents[child.nodeID], l.nodeID)
+		}
+	})
+
+	required := make(map[NodeID]map[NodeID]struct{}, len(ids))
+	for _, id := range ids {
+		l, ok := linked[id]
+		if !ok || l.node.Type() != NodeTypeSink {
+			return nil, fmt.Errorf("required node ID %q is not a sink of the pipeline: %w", id, ErrInvalidParameter)
+		}
+
+		path := make(map[NodeID]struct{})
+		stack := []NodeID{id}
+		for len(stack) > 0 {
+			n := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if _, ok := path[n]; ok {
+				continue
+			}
+			path[n] = struct{}{}
+			stack = append(stack, parents[n]...)
+		}
+		required[id] = path
+	}
+	return required, nil
+}
+
+// requiredSinkIDs returns the IDs of the pipeline's required sinks, sorted.
+func (p *registeredPipeline) requiredSinkIDs() []NodeID {
+	if len(p.requiredSinks) == 0 {
+		return nil
+	}
+	ids := make([]NodeID, 0, len(p.requiredSinks))
+	for id := range p.requiredSinks {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// requiredError returns an error identifying each required pipeline or sink
+// which failed, according to the results of the pipelines.
+func requiredError(roots []snapshotEntry[PipelineID, *registeredPipeline], results []PipelineResult) error {
+	var errs []error
+	for _, p := range roots {
+		if !p.value.required && len(p.value.requiredSinks) == 0 {
+			continue
+		}
+
+		var failed []PipelineResult
+		for _, r := range results {
+			if r.PipelineID == p.key && (r.Outcome == PipelineFailed || r.Outcome == PipelineCancelled) {
+				failed = append(failed, r)
+			}
+		}
+		if len(failed) == 0 {
+			continue
+		}
+
+		if p.value.required {
+			errs = append(errs, fmt.Errorf("%w: pipeline ID %q", ErrRequiredPipelineFailed, p.key))
+			continue
+		}
+		for _, id := range p.value.requiredSinkIDs() {
+			for _, r := range failed {
+				// A result without a node means the pipeline didn't finish.
+				if _, ok := p.value.requiredSinks[id][r.NodeID]; ok || r.NodeID == "" {
+					errs = append(errs, fmt.Errorf("%w: sink node ID %q in pipeline ID %q", ErrRequiredPipelineFailed, id, p.key))
+					break
+				}
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,176 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package eventlogger
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// requiredTestNodes returns a filter which removes every event and the sinks
+// "ok" and "bad", which fails.
+func requiredTestNodes() map[NodeID]Node {
+	return map[NodeID]Node{
+		"filter": &Filter{Predicate: func(*Event) (bool, error) { return false, nil }},
+		"ok":     &testActionNode{},
+		"bad": &testActionNode{
+			action: func(context.Context, *Event) (*Event, error) { return nil, errors.New("bad sink") },
+		},
+	}
+}
+
+func TestBroker_RequiredPipeline(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	tests := map[string]struct {
+		nodeIDs []NodeID
+		opts    []Option
+		wantErr string
+	}{
+		"required-failed": {
+			nodeIDs: []NodeID{"formatter", "bad"},
+			opts:    []Option{WithRequiredPipeline()},
+			wantErr: `required pipeline failed: pipeline ID "audit"`,
+		},
+		"required-delivered": {
+			nodeIDs: []NodeID{"formatter", "ok"},
+			opts:    []Option{WithRequiredPipeline()},
+		},
+		"required-filtered": {
+			nodeIDs: []NodeID{"filter", "formatter", "bad"},
+			opts:    []Option{WithRequiredPipeline()},
+		},
+		"optional-failed": {
+			nodeIDs: []NodeID{"formatter", "bad"},
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			b := newTestBroker(t, requiredTestNodes(), nil)
+			require.NoError(t, b.RegisterPipeline(Pipeline{PipelineID: "audit", EventType: "t", NodeIDs: tc.nodeIDs}, tc.opts...))
+			require.NoError(t, b.RegisterPipeline(Pipeline{PipelineID: "debug", EventType: "t", NodeIDs: []NodeID{"formatter", "ok"}}))
+			// The threshold is met by the optional pipeline.
+			require.NoError(t, b.SetSuccessThresholdSinks("t", 1))
+
+			_, err := b.Send(ctx, "t", "payload")
+			if tc.wantErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorIs(t, err, ErrRequiredPipelineFailed)
+			assert.EqualError(t, err, tc.wantErr)
+		})
+	}
+}
+
+func TestBroker_RequiredSinks(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	b := newTestBroker(t, requiredTestNodes(), nil)
+	require.NoError(t, b.RegisterNode("other-formatter", &JSONFormatter{}))
+	// The "ok" sink is required, so the failure of the "bad" sink on another
+	// branch doesn't matter.
+	require.NoError(t, b.RegisterPipeline(Pipeline{
+		PipelineID: "optional-branch",
+		EventType:  "t",
+		Edges:      map[NodeID][]NodeID{"formatter": {"ok", "bad"}},
+	}, WithRequiredSinks("ok")))
+
+	_, err := b.Send(ctx, "t", "payload")
+	require.NoError(t, err)
+
+	require.NoError(t, b.RegisterPipeline(Pipeline{
+		PipelineID: "required-branch",
+		EventType:  "t",
+		Edges:      map[NodeID][]NodeID{"formatter": {"ok", "other-formatter"}, "other-formatter": {"bad"}},
+	}, WithRequiredSinks("bad")))
+
+	status, err := b.Send(ctx, "t", "payload")
+	require.ErrorIs(t, err, ErrRequiredPipelineFailed)
+	assert.EqualError(t, err, `required pipeline failed: sink node ID "bad" in pipeline ID "required-branch"`)
+	assert.Len(t, status.Warnings, 2)
+
+	policy, ok := b.DeliveryPolicy("t")
+	require.True(t, ok)
+	assert.Equal(t, DeliveryPolicy{
+		RequiredSinks: map[PipelineID][]NodeID{
+			"optional-branch": {"ok"},
+			"required-branch": {"bad"},
+		},
+	}, policy)
+
+	pipelines := b.Pipelines("t")
+	require.Len(t, pipelines, 2)
+	assert.Equal(t, []NodeID{"bad"}, pipelines[1].RequiredSinks)
+}
+
+func TestBroker_RequiredSinks_Invalid(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		opt     Option
+		wantErr string
+	}{
+		"no-sinks": {
+			opt:     WithRequiredSinks(),
+			wantErr: "cannot register pipeline: required sinks cannot be empty: invalid parameter",
+		},
+		"empty-sink": {
+			opt:     WithRequiredSinks(""),
+			wantErr: "cannot register pipeline: required sink node ID cannot be empty: invalid parameter",
+		},
+		"not-in-pipeline": {
+			opt:     WithRequiredSinks("bad"),
+			wantErr: `required node ID "bad" is not a sink of the pipeline: invalid parameter`,
+		},
+		"not-a-sink": {
+			opt:     WithRequiredSinks("formatter"),
+			wantErr: `required node ID "formatter" is not a sink of the pipeline: invalid parameter`,
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			b := newTestBroker(t, requiredTestNodes(), nil)
+			err := b.RegisterPipeline(Pipeline{PipelineID: "p", EventType: "t", NodeIDs: []NodeID{"formatter", "ok"}}, tc.opt)
+			require.ErrorIs(t, err, ErrInvalidParameter)
+			assert.EqualError(t, err, tc.wantErr)
+		})
+	}
+}
+
+func TestBroker_DeliveryPolicy(t *testing.T) {
+	t.Parallel()
+
+	b := newTestBroker(t, requiredTestNodes(), nil)
+	_, ok := b.DeliveryPolicy("t")
+	assert.False(t, ok)
+
+	require.NoError(t, b.RegisterPipeline(Pipeline{PipelineID: "b", EventType: "t", NodeIDs: []NodeID{"formatter", "ok"}}, WithRequiredPipeline()))
+	require.NoError(t, b.RegisterPipeline(Pipeline{PipelineID: "a", EventType: "t", NodeIDs: []NodeID{"formatter", "bad"}}, WithRequiredPipeline()))
+	require.NoError(t, b.RegisterPipeline(Pipeline{PipelineID: "c", EventType: "t", NodeIDs: []NodeID{"formatter", "ok"}}))
+	require.NoError(t, b.SetSuccessThreshold("t", 1))
+	require.NoError(t, b.SetSuccessThresholdSinks("t", 2))
+
+	policy, ok := b.DeliveryPolicy("t")
+	require.True(t, ok)
+	assert.Equal(t, DeliveryPolicy{
+		SuccessThreshold:      1,
+		SuccessThresholdSinks: 2,
+		RequiredPipelines:     []PipelineID{"a", "b"},
+	}, policy)
+}
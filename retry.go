@@ -0,0 +1,127 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package eventlogger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+const (
+	// DefaultRetryInitialBackoff is used when RetryPolicy.InitialBackoff is unset
+	DefaultRetryInitialBackoff = 10 * time.Millisecond
+
+	// DefaultRetryMultiplier is used when RetryPolicy.Multiplier is unset
+	DefaultRetryMultiplier = 2.0
+)
+
+// RetryPolicy determines whether, and how often, a node's Process is retried
+// when it returns an error (see WithNodeRetryPolicy).  The delay between
+// attempts grows exponentially from InitialBackoff by Multiplier, up to
+// MaxBackoff, and is reduced by a random amount up to Jitter.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of times Process is called for an
+	// event, including the first attempt, and must be at least 1.
+	MaxAttempts int
+
+	// InitialBackoff is the delay before the first retry.  If unset,
+	// DefaultRetryInitialBackoff is used.
+	InitialBackoff time.Duration
+
+	// MaxBackoff is the maximum delay between attempts.  If unset, the delay
+	// is not capped.
+	MaxBackoff time.Duration
+
+	// Multiplier is applied to the delay after each retry and must be at
+	// least 1.  If unset, DefaultRetryMultiplier is used.
+	Multiplier float64
+
+	// Jitter is the fraction (between 0 and 1) of each delay which is
+	// randomized, so that nodes which fail together don't retry in lockstep.
+	Jitter float64
+
+	// Retryable determines whether an error returned by Process should be
+	// retried.  If unset, DefaultRetryable is used.
+	Retryable func(error) bool
+}
+
+// DefaultRetryable retries every error, except those caused by a context
+// ending (including timeouts), those which wrap ErrInvalidParameter or
+// ErrCircuitOpen and panics (see PanicError), since trying again won't help.
+func DefaultRetryable(err error) bool {
+	var panicErr *PanicError
+	switch {
+	case errors.As(err, &panicErr):
+		return false
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return false
+	case errors.Is(err, ErrInvalidParameter), errors.Is(err, ErrCircuitOpen):
+		return false
+	default:
+		return true
+	}
+}
+
+// validate ensures the RetryPolicy makes sense.
+func (p RetryPolicy) validate() error {
+	switch {
+	case p.MaxAttempts < 1:
+		return fmt.Errorf("max attempts must be at least 1: %w", ErrInvalidParameter)
+	case p.InitialBackoff < 0:
+		return fmt.Errorf("initial backoff cannot be negative: %w", ErrInvalidParameter)
+	case p.MaxBackoff < 0:
+		return fmt.Errorf("max backoff cannot be negative: %w", ErrInvalidParameter)
+	case p.Multiplier != 0 && p.Multiplier < 1:
+		return fmt.Errorf("multiplier must be at least 1: %w", ErrInvalidParameter)
+	case p.Jitter < 0 || p.Jitter > 1:
+		return fmt.Errorf("jitter must be between 0 and 1: %w", ErrInvalidParameter)
+	}
+	return nil
+}
+
+// retryable determines whether the error should be retried.
+func (p *RetryPolicy) retryable(err error) bool {
+	if p.Retryable != nil {
+		return p.Retryable(err)
+	}
+	return DefaultRetryable(err)
+}
+
+// backoff returns the delay before the next attempt, following the given
+// (1-based) attempt.
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	initial, multiplier := p.InitialBackoff, p.Multiplier
+	if initial == 0 {
+		initial = DefaultRetryInitialBackoff
+	}
+	if multiplier == 0 {
+		multiplier = DefaultRetryMultiplier
+	}
+
+	d := float64(initial) * math.Pow(multiplier, float64(attempt-1))
+	if p.MaxBackoff > 0 && d > float64(p.MaxBackoff) {
+		d = float64(p.MaxBackoff)
+	}
+	if p.Jitter > 0 {
+		d -= d * p.Jitter * rand.Float64()
+	}
+	return time.Duration(d)
+}
+
+// WithNodeRetryPolicy configures the option that determines how a node is
+// retried when it fails to process an event, when registering a node.  By
+// default, nodes are not retried.
+func WithNodeRetryPolicy(p RetryPolicy) Option {
+	return func(o *options) error {
+		if err := p.validate(); err != nil {
+			return fmt.Errorf("invalid retry policy: %w", err)
+		}
+		o.withNodeRetryPolicy = &p
+		return nil
+	}
+}
@@ -0,0 +1,249 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package eventlogger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// failingSink returns a sink node which returns err for its first failures
+// calls to Process, counting every call in attempts.
+func failingSink(failures int32, err error, attempts *atomic.Int32) *testActionNode {
+	return &testActionNode{
+		nodeType: NodeTypeSink,
+		action: func(ctx context.Context, e *Event) (*Event, error) {
+			if attempts.Add(1) <= failures {
+				return nil, err
+			}
+			return nil, nil
+		},
+	}
+}
+
+func TestBroker_RetryPolicy(t *testing.T) {
+	t.Parallel()
+
+	errTransient := errors.New("transient")
+	fast := RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}
+
+	tests := map[string]struct {
+		policy       *RetryPolicy
+		failures     int32
+		err          error
+		wantAttempts int32
+		wantErr      error
+	}{
+		"no-policy": {
+			failures:     1,
+			err:          errTransient,
+			wantAttempts: 1,
+			wantErr:      errTransient,
+		},
+		"recovers": {
+			policy:       &fast,
+			failures:     2,
+			err:          errTransient,
+			wantAttempts: 3,
+		},
+		"exhausted": {
+			policy:       &fast,
+			failures:     5,
+			err:          errTransient,
+			wantAttempts: 3,
+			wantErr:      errTransient,
+		},
+		"not-retryable": {
+			policy:       &fast,
+			failures:     5,
+			err:          fmt.Errorf("bad event: %w", ErrInvalidParameter),
+			wantAttempts: 1,
+			wantErr:      ErrInvalidParameter,
+		},
+		"custom-classifier": {
+			policy: &RetryPolicy{
+				MaxAttempts:    3,
+				InitialBackoff: time.Millisecond,
+				Retryable:      func(err error) bool { return !errors.Is(err, errTransient) },
+			},
+			failures:     5,
+			err:          errTransient,
+			wantAttempts: 1,
+			wantErr:      errTransient,
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var opts []Option
+			if tc.policy != nil {
+				opts = append(opts, WithNodeRetryPolicy(*tc.policy))
+			}
+			var attempts atomic.Int32
+			b, err := NewBroker()
+			require.NoError(t, err)
+			require.NoError(t, b.RegisterNode("formatter", &JSONFormatter{}))
+			require.NoError(t, b.RegisterNode("sink", failingSink(tc.failures, tc.err, &attempts), opts...))
+			require.NoError(t, b.RegisterPipeline(Pipeline{
+				PipelineID: "p",
+				EventType:  "t",
+				NodeIDs:    []NodeID{"formatter", "sink"},
+			}))
+
+			s, err := b.Send(context.Background(), "t", "payload")
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantAttempts, attempts.Load())
+			switch tc.wantErr {
+			case nil:
+				assert.Empty(t, s.Warnings)
+				assert.Equal(t, []NodeID{"sink"}, s.CompleteSinks())
+			default:
+				require.Len(t, s.Warnings, 1)
+				assert.ErrorIs(t, s.Warnings[0], tc.wantErr)
+				assert.Empty(t, s.CompleteSinks())
+			}
+		})
+	}
+}
+
+func TestBroker_RetryPolicy_PipelineTimeout(t *testing.T) {
+	t.Parallel()
+
+	var attempts atomic.Int32
+	b, err := NewBroker()
+	require.NoError(t, err)
+	require.NoError(t, b.RegisterNode("formatter", &JSONFormatter{}))
+	require.NoError(t, b.RegisterNode("sink", failingSink(100, errors.New("transient"), &attempts),
+		WithNodeRetryPolicy(RetryPolicy{MaxAttempts: 100, InitialBackoff: time.Hour})))
+	require.NoError(t, b.RegisterPipeline(Pipeline{
+		PipelineID: "p",
+		EventType:  "t",
+		NodeIDs:    []NodeID{"formatter", "sink"},
+	}, WithPipelineTimeout(10*time.Millisecond)))
+
+	// The backoff is abandoned once the pipeline's timeout has passed.
+	s, err := b.Send(context.Background(), "t", "payload")
+	require.NoError(t, err)
+	require.Len(t, s.Warnings, 1)
+	assert.EqualError(t, s.Warnings[0], `node ID "sink": transient`)
+	assert.Equal(t, int32(1), attempts.Load())
+}
+
+func TestBroker_RetryPolicy_Observer(t *testing.T) {
+	t.Parallel()
+
+	var observed []NodeOutcome
+	observer := ObserverFunc(func(_ context.Context, o NodeObservation) {
+		if o.NodeID == "sink" {
+			observed = append(observed, o.Outcome)
+		}
+	})
+
+	var attempts atomic.Int32
+	b, err := NewBroker(WithObserver(observer))
+	require.NoError(t, err)
+	require.NoError(t, b.RegisterNode("formatter", &JSONFormatter{}))
+	require.NoError(t, b.RegisterNode("sink", failingSink(1, errors.New("transient"), &attempts),
+		WithNodeRetryPolicy(RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond})))
+	require.NoError(t, b.RegisterPipeline(Pipeline{
+		PipelineID: "p",
+		EventType:  "t",
+		NodeIDs:    []NodeID{"formatter", "sink"},
+	}))
+
+	_, err = b.Send(context.Background(), "t", "payload")
+	require.NoError(t, err)
+	assert.Equal(t, []NodeOutcome{NodeOutcomeError, NodeOutcomePassed}, observed)
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	t.Parallel()
+
+	p := &RetryPolicy{MaxAttempts: 5}
+	assert.Equal(t, DefaultRetryInitialBackoff, p.backoff(1))
+	assert.Equal(t, 2*DefaultRetryInitialBackoff, p.backoff(2))
+	assert.Equal(t, 4*DefaultRetryInitialBackoff, p.backoff(3))
+
+	p = &RetryPolicy{MaxAttempts: 5, InitialBackoff: time.Second, MaxBackoff: 5 * time.Second, Multiplier: 3}
+	assert.Equal(t, time.Second, p.backoff(1))
+	assert.Equal(t, 3*time.Second, p.backoff(2))
+	assert.Equal(t, 5*time.Second, p.backoff(3))
+
+	p = &RetryPolicy{MaxAttempts: 5, InitialBackoff: time.Second, Jitter: 0.5}
+	for i := 0; i < 10; i++ {
+		d := p.backoff(1)
+		assert.GreaterOrEqual(t, d, 500*time.Millisecond)
+		assert.LessOrEqual(t, d, time.Second)
+	}
+}
+
+func TestDefaultRetryable(t *testing.T) {
+	t.Parallel()
+
+	assert.True(t, DefaultRetryable(errors.New("transient")))
+	assert.False(t, DefaultRetryable(fmt.Errorf("wrapped: %w", context.Canceled)))
+	assert.False(t, DefaultRetryable(fmt.Errorf("%w: too slow: %w", ErrTimeout, context.DeadlineExceeded)))
+	assert.False(t, DefaultRetryable(&NodeError{Err: &PanicError{NodeID: "n", Value: "boom"}}))
+	assert.False(t, DefaultRetryable(fmt.Errorf("bad: %w", ErrInvalidParameter)))
+}
+
+func TestWithNodeRetryPolicy(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		policy  RetryPolicy
+		wantErr string
+	}{
+		"valid": {
+			policy: RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: time.Second, Multiplier: 1.5, Jitter: 0.2},
+		},
+		"no-attempts": {
+			policy:  RetryPolicy{},
+			wantErr: "cannot register node: invalid retry policy: max attempts must be at least 1: invalid parameter",
+		},
+		"negative-initial-backoff": {
+			policy:  RetryPolicy{MaxAttempts: 1, InitialBackoff: -1},
+			wantErr: "cannot register node: invalid retry policy: initial backoff cannot be negative: invalid parameter",
+		},
+		"negative-max-backoff": {
+			policy:  RetryPolicy{MaxAttempts: 1, MaxBackoff: -1},
+			wantErr: "cannot register node: invalid retry policy: max backoff cannot be negative: invalid parameter",
+		},
+		"small-multiplier": {
+			policy:  RetryPolicy{MaxAttempts: 1, Multiplier: 0.5},
+			wantErr: "cannot register node: invalid retry policy: multiplier must be at least 1: invalid parameter",
+		},
+		"bad-jitter": {
+			policy:  RetryPolicy{MaxAttempts: 1, Jitter: 1.5},
+			wantErr: "cannot register node: invalid retry policy: jitter must be between 0 and 1: invalid parameter",
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			b, err := NewBroker()
+			require.NoError(t, err)
+			err = b.RegisterNode("sink", &testSink{}, WithNodeRetryPolicy(tc.policy))
+			switch tc.wantErr {
+			case "":
+				require.NoError(t, err)
+			default:
+				require.EqualError(t, err, tc.wantErr)
+			}
+		})
+	}
+}
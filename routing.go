@@ -0,0 +1,144 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package eventlogger
+
+import (
+	"context"
+)
+
+// routingTable is an immutable snapshot of the graph registered for each
+// EventType and pattern, which the Broker swaps atomically whenever its graphs
+// change so that events can be routed without locking.
+type routingTable struct {
+	exact map[EventType]*graph
+
+	// patterns are sorted from the most to the least specific.
+	patterns []routedPattern
+
+	// deadLetterTypes are the dead-letter EventTypes of the graphs, which
+	// aren't routed to patterns so that pipelines registered for patterns
+	// (such as AllEventTypes) don't receive dead letters.
+	deadLetterTypes map[EventType]struct{}
+}
+
+// publishRoutes swaps in a new routingTable for the Broker's graphs.
+// This function assumes that the caller holds a lock.
+func (b *Broker) publishRoutes() {
+	routes := &routingTable{
+		exact:           make(map[EventType]*graph, len(b.graphs)),
+		deadLetterTypes: make(map[EventType]struct{}),
+	}
+	for t, g := range b.graphs {
+		if g.deadLetterType != "" {
+			routes.deadLetterTypes[g.deadLetterType] = struct{}{}
+		}
+		if t.IsPattern() {
+			routes.patterns = append(routes.patterns, routedPattern{pattern: t, graph: g})
+			continue
+		}
+		routes.exact[t] = g
+	}
+	sortPatterns(routes.patterns)
+	b.routes.Store(routes)
+}
+
+// match returns the graphs which events of the EventType are routed to: the
+// graph registered for the EventType, followed by the graphs of the patterns
+// which match it, from the most to the least specific.  Dead-letter EventTypes
+// only match the graph registered for them.
+func (r *routingTable) match(t EventType) []*graph {
+	var graphs []*graph
+	if g, ok := r.exact[t]; ok {
+		graphs = append(graphs, g)
+	}
+	if _, ok := r.deadLetterTypes[t]; ok {
+		return graphs
+	}
+	for _, p := range r.patterns {
+		if p.pattern.Matches(t) {
+			graphs = append(graphs, p.graph)
+		}
+	}
+	return graphs
+}
+
+// route returns the graphs for the EventType (see routingTable.match), having
+// admitted the caller to each of them.  The caller must leave each graph's
+// admission, which graph.begin does once the graph has processed the event.
+func (b *Broker) route(t EventType) ([]*graph, bool) {
+	for {
+		routes := b.routes.Load()
+		if routes == nil {
+			return nil, false
+		}
+		graphs := routes.match(t)
+		if len(graphs) == 0 {
+			return nil, false
+		}
+		admitted := 0
+		for _, g := range graphs {
+			if !g.admission.enter() {
+				break
+			}
+			admitted++
+		}
+		if admitted == len(graphs) {
+			return graphs, true
+		}
+		// A graph has been replaced since the routing table was loaded, so
+		// load the latest one.
+		for _, g := range graphs[:admitted] {
+			g.admission.leave()
+		}
+	}
+}
+
+// replaceGraphs swaps in the graphs for the EventTypes, and retires the graphs
+// they replace so that waitForDraining can wait for the events which they're
+// processing.
+// This function assumes that the caller holds a lock.
+func (b *Broker) replaceGraphs(graphs map[EventType]*graph) {
+	var replaced []*graph
+	for t, g := range graphs {
+		if old, ok := b.graphs[t]; ok && old != g {
+			replaced = append(replaced, old)
+		}
+		b.graphs[t] = g
+	}
+	b.publishRoutes()
+	b.retireGraphs(replaced...)
+}
+
+// retireGraphs stops the graphs admitting events, once they're no longer
+// routed to, and records them as draining.
+// This function assumes that the caller holds a lock.
+func (b *Broker) retireGraphs(graphs ...*graph) {
+	remaining := b.draining[:0]
+	for _, g := range b.draining {
+		select {
+		case <-g.admission.done():
+		default:
+			remaining = append(remaining, g)
+		}
+	}
+	for _, g := range graphs {
+		g.admission.close()
+		remaining = append(remaining, g)
+	}
+	b.draining = remaining
+}
+
+// waitForDraining waits until every retired graph has finished processing the
+// events it admitted.  The graphs to wait for must be read while holding a
+// lock, but the caller must not hold a lock while waiting.
+func waitForDraining(ctx context.Context, graphs []*graph) error {
+	for _, g := range graphs {
+		select {
+		case <-g.admission.done():
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
@@ -0,0 +1,277 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package eventlogger
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshotMap(t *testing.T) {
+	t.Parallel()
+
+	var m snapshotMap[string, int]
+	_, ok := m.Load("a")
+	assert.False(t, ok)
+
+	m.Store("c", 3)
+	m.Store("a", 1)
+	m.Store("b", 2)
+	before := m.snapshot()
+	m.Store("b", 20)
+	m.Delete("c")
+	m.Delete("missing")
+
+	var keys []string
+	var values []int
+	m.Range(func(k string, v int) bool {
+		keys = append(keys, k)
+		values = append(values, v)
+		return true
+	})
+	assert.Equal(t, []string{"a", "b"}, keys)
+	assert.Equal(t, []int{1, 20}, values)
+
+	// Earlier snapshots are unaffected by changes.
+	assert.Len(t, before.entries, 3)
+	assert.Equal(t, 2, before.entries[1].value)
+
+	var copied snapshotMap[string, int]
+	copied.copyFrom(&m)
+	copied.Store("d", 4)
+	v, ok := copied.Load("a")
+	require.True(t, ok)
+	assert.Equal(t, 1, v)
+	_, ok = m.Load("d")
+	assert.False(t, ok)
+}
+
+func TestAdmission(t *testing.T) {
+	t.Parallel()
+
+	var a admission
+	require.True(t, a.enter())
+	require.True(t, a.enter())
+	a.leave()
+
+	a.close()
+	a.close()
+	assert.True(t, a.closed())
+	assert.False(t, a.enter())
+	select {
+	case <-a.done():
+		t.Fatal("done before every admitted caller left")
+	default:
+	}
+
+	a.leave()
+	select {
+	case <-a.done():
+	case <-time.After(time.Second):
+		t.Fatal("not done after every admitted caller left")
+	}
+
+	var unused admission
+	unused.close()
+	<-unused.done()
+}
+
+func TestBroker_RoutesDuringChanges(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	b, err := NewBroker()
+	require.NoError(t, err)
+	require.NoError(t, b.RegisterNode("formatter", &JSONFormatter{}))
+	require.NoError(t, b.RegisterNode("sink", &FileSink{Path: t.TempDir()}))
+	require.NoError(t, b.RegisterPipeline(Pipeline{PipelineID: "p", EventType: "t", NodeIDs: []NodeID{"formatter", "sink"}}))
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				status, err := b.Send(ctx, "t", "payload")
+				assert.NoError(t, err)
+				assert.Equal(t, []NodeID{"sink"}, status.CompleteSinks())
+			}
+		}()
+	}
+
+	// Changing thresholds replaces the graph, which must not drop events.
+	for i := 0; i < 100; i++ {
+		require.NoError(t, b.SetSuccessThreshold("t", i%2))
+		require.NoError(t, b.SetSuccessThresholdSinks("t", 1))
+	}
+	close(stop)
+	wg.Wait()
+
+	threshold, ok := b.SuccessThreshold("t")
+	require.True(t, ok)
+	assert.Equal(t, 1, threshold)
+	require.NoError(t, b.Close(ctx))
+}
+
+func TestBroker_PipelineChangesReplaceGraphs(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	b, err := NewBroker()
+	require.NoError(t, err)
+	require.NoError(t, b.RegisterNode("formatter", &JSONFormatter{}))
+	require.NoError(t, b.RegisterNode("sink", &testActionNode{}))
+	require.NoError(t, b.RegisterPipeline(Pipeline{PipelineID: "p1", EventType: "t", NodeIDs: []NodeID{"formatter", "sink"}}))
+
+	pipelines := func(g *graph) []PipelineID {
+		var ids []PipelineID
+		g.roots.Range(func(id PipelineID, _ *registeredPipeline) bool {
+			ids = append(ids, id)
+			return true
+		})
+		return ids
+	}
+
+	// Each change replaces the graph which events are routed to, leaving the
+	// graph which in-flight events are using unchanged.
+	tests := []struct {
+		change func() error
+		want   []PipelineID
+	}{
+		{
+			change: func() error {
+				return b.RegisterPipeline(Pipeline{PipelineID: "p2", EventType: "t", NodeIDs: []NodeID{"formatter", "sink"}})
+			},
+			want: []PipelineID{"p1", "p2"},
+		},
+		{
+			change: func() error { return b.RemovePipeline("t", "p1") },
+			want:   []PipelineID{"p2"},
+		},
+		{
+			change: func() error {
+				_, err := b.RemovePipelineAndNodes(ctx, "t", "p2")
+				return err
+			},
+			want: nil,
+		},
+	}
+	for _, tc := range tests {
+		routed := b.routes.Load().exact["t"]
+		before := pipelines(routed)
+		require.NoError(t, tc.change())
+		assert.Equal(t, before, pipelines(routed))
+
+		replaced := b.routes.Load().exact["t"]
+		assert.NotSame(t, routed, replaced)
+		assert.Equal(t, tc.want, pipelines(replaced))
+	}
+}
+
+// discardNode is a node which does nothing, for benchmarks.
+type discardNode struct {
+	typ NodeType
+}
+
+func (n discardNode) Process(_ context.Context, e *Event) (*Event, error) {
+	if n.typ == NodeTypeSink {
+		return nil, nil
+	}
+	return e, nil
+}
+
+func (discardNode) Reopen() error    { return nil }
+func (n discardNode) Type() NodeType { return n.typ }
+
+// newDiscardBroker creates a Broker with several pipelines for the EventType
+// "t" which do nothing, so that routing dominates.
+func newDiscardBroker(b *testing.B) *Broker {
+	broker, err := NewBroker()
+	require.NoError(b, err)
+	require.NoError(b, broker.RegisterNode("formatter", discardNode{typ: NodeTypeFormatter}))
+	require.NoError(b, broker.RegisterNode("sink", discardNode{typ: NodeTypeSink}))
+	for _, id := range []PipelineID{"p1", "p2", "p3", "p4"} {
+		require.NoError(b, broker.RegisterPipeline(Pipeline{PipelineID: id, EventType: "t", NodeIDs: []NodeID{"formatter", "sink"}}))
+	}
+	return broker
+}
+
+// BenchmarkBroker_Send measures sending events concurrently through several
+// pipelines which do nothing.
+func BenchmarkBroker_Send(b *testing.B) {
+	ctx := context.Background()
+	broker := newDiscardBroker(b)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := broker.Send(ctx, "t", nil); err != nil {
+				b.Error(err)
+			}
+		}
+	})
+}
+
+// BenchmarkRoute compares routing an event using the Broker's routingTable
+// with the RWMutex and sync.Map lookup which it replaced.
+func BenchmarkRoute(b *testing.B) {
+	broker := newDiscardBroker(b)
+
+	b.Run("snapshot", func(b *testing.B) {
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				graphs, ok := broker.route("t")
+				if !ok {
+					b.Fatal("no route")
+				}
+				for _, g := range graphs {
+					for _, p := range g.roots.snapshot().entries {
+						_ = p.value.rootNode
+					}
+					g.admission.leave()
+				}
+			}
+		})
+	})
+
+	b.Run("locked", func(b *testing.B) {
+		var roots sync.Map
+		g := broker.graphs["t"]
+		g.roots.Range(func(id PipelineID, p *registeredPipeline) bool {
+			roots.Store(id, p)
+			return true
+		})
+		graphs := map[EventType]*sync.Map{"t": &roots}
+		var lock sync.RWMutex
+
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				lock.RLock()
+				m, ok := graphs["t"]
+				lock.RUnlock()
+				if !ok {
+					b.Fatal("no route")
+				}
+				m.Range(func(_, v interface{}) bool {
+					_ = v.(*registeredPipeline).rootNode
+					return true
+				})
+			}
+		})
+	})
+}
@@ -21,7 +21,10 @@ type ChannelSink struct {
 	timeoutDuration time.Duration
 }
 
-var _ eventlogger.Node = &ChannelSink{}
+var (
+	_ eventlogger.Node  = &ChannelSink{}
+	_ eventlogger.Named = &ChannelSink{}
+)
 
 // newChannelSink creates a ChannelSink
 // The time.Duration value is used to set a timeout on the consumer for sending events
@@ -70,5 +70,5 @@ func ExampleChannelSink() {
 	fmt.Println(string(output.Formatted["json"]))
 
 	// Output:
-	// {"created_at":"2009-11-17T20:34:58.651387237Z","event_type":"test-event","payload":{"coworkers":["alice","eve"],"name":"bob","pronouns":["they","them"],"role":"user"}}
+	// {"created_at":"2009-11-17T20:34:58.651387237Z","event_type":"test-event","sequence":1,"payload":{"coworkers":["alice","eve"],"name":"bob","pronouns":["they","them"],"role":"user"}}
 }
@@ -0,0 +1,37 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package channel
+
+import (
+	"github.com/hashicorp/eventlogger"
+)
+
+func init() {
+	eventlogger.MustRegisterNodeFactory("channel", eventlogger.NodeFactory{
+		Description: "ChannelSink sends events to a channel",
+		Settings: []eventlogger.NodeSetting{
+			{Name: "channel", Type: eventlogger.NodeSettingValue, Required: true, Description: "the chan<- *eventlogger.Event to send events to"},
+			{Name: "timeout", Type: eventlogger.NodeSettingDuration, Required: true, Description: "time to wait for each send"},
+		},
+		New: newChannelSinkFromSettings,
+	})
+}
+
+// newChannelSinkFromSettings creates a ChannelSink.  The channel can only be
+// given programmatically, since it's a Go value.
+func newChannelSinkFromSettings(_ *eventlogger.Broker, s eventlogger.NodeSettings) (eventlogger.Node, error) {
+	var c chan<- *eventlogger.Event
+	switch v := s["channel"].(type) {
+	case chan *eventlogger.Event:
+		c = v
+	case chan<- *eventlogger.Event:
+		c = v
+	default:
+		return nil, eventlogger.NewNodeSettingError("channel", "expected a chan<- *eventlogger.Event, not %T", v)
+	}
+	if s.Duration("timeout") <= 0 {
+		return nil, eventlogger.NewNodeSettingError("timeout", "must be greater than 0")
+	}
+	return NewChannelSink(c, s.Duration("timeout"))
+}
@@ -0,0 +1,38 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package channel
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hashicorp/eventlogger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewChannelSinkFromSettings(t *testing.T) {
+	c := make(chan *eventlogger.Event)
+	n, err := eventlogger.NewNodeFromSettings(nil, "channel", map[string]interface{}{"channel": c, "timeout": "5s"})
+	require.NoError(t, err)
+	sink, ok := n.(*ChannelSink)
+	require.True(t, ok)
+	assert.Equal(t, 5*time.Second, sink.timeoutDuration)
+
+	tests := map[string]struct {
+		settings    map[string]interface{}
+		wantSetting string
+	}{
+		"wrong-channel": {settings: map[string]interface{}{"channel": make(chan int), "timeout": "5s"}, wantSetting: "channel"},
+		"zero-timeout":  {settings: map[string]interface{}{"channel": c, "timeout": "0s"}, wantSetting: "timeout"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := eventlogger.NewNodeFromSettings(nil, "channel", tc.settings)
+			var settingErr *eventlogger.NodeSettingError
+			require.ErrorAs(t, err, &settingErr)
+			assert.Equal(t, tc.wantSetting, settingErr.Setting)
+		})
+	}
+}
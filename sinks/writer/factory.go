@@ -0,0 +1,41 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package writer
+
+import (
+	"io"
+	"os"
+
+	"github.com/hashicorp/eventlogger"
+)
+
+func init() {
+	eventlogger.MustRegisterNodeFactory("writer", eventlogger.NodeFactory{
+		Description: "Sink writes formatted events to stdout or stderr",
+		Settings: []eventlogger.NodeSetting{
+			{Name: "output", Type: eventlogger.NodeSettingString, Required: true, Description: "either \"stdout\" or \"stderr\""},
+			{Name: "format", Type: eventlogger.NodeSettingString, Description: "format written, defaulting to json"},
+		},
+		New: newSinkFromSettings,
+	})
+}
+
+// newSinkFromSettings creates a Sink which writes to either "stdout" or
+// "stderr".
+func newSinkFromSettings(_ *eventlogger.Broker, s eventlogger.NodeSettings) (eventlogger.Node, error) {
+	var w io.Writer
+	switch output := s.String("output"); output {
+	case "stdout":
+		w = os.Stdout
+	case "stderr":
+		w = os.Stderr
+	default:
+		return nil, eventlogger.NewNodeSettingError("output", "%q is not a valid output, must be \"stdout\" or \"stderr\"", output)
+	}
+
+	return &Sink{
+		Format: s.String("format"),
+		Writer: w,
+	}, nil
+}
@@ -0,0 +1,24 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package writer
+
+import (
+	"os"
+	"testing"
+
+	"github.com/hashicorp/eventlogger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewSinkFromSettings(t *testing.T) {
+	n, err := eventlogger.NewNodeFromSettings(nil, "writer", map[string]interface{}{"output": "stderr", "format": "cloudevents-json"})
+	require.NoError(t, err)
+	assert.Equal(t, &Sink{Writer: os.Stderr, Format: "cloudevents-json"}, n)
+
+	_, err = eventlogger.NewNodeFromSettings(nil, "writer", map[string]interface{}{"output": "/dev/null"})
+	var settingErr *eventlogger.NodeSettingError
+	require.ErrorAs(t, err, &settingErr)
+	assert.Equal(t, "output", settingErr.Setting)
+}
@@ -0,0 +1,96 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package eventlogger
+
+import (
+	"cmp"
+	"slices"
+	"sync/atomic"
+)
+
+// snapshotMap is a map which is copied whenever it's changed, so that readers
+// can use an immutable snapshot of it without locking or type assertions.
+// Changes must be serialized by the caller.
+type snapshotMap[K cmp.Ordered, V any] struct {
+	current atomic.Pointer[snapshot[K, V]]
+}
+
+// snapshot is an immutable copy of a snapshotMap's entries, sorted by key.
+type snapshot[K cmp.Ordered, V any] struct {
+	entries []snapshotEntry[K, V]
+}
+
+// snapshotEntry is a key and its value.
+type snapshotEntry[K cmp.Ordered, V any] struct {
+	key   K
+	value V
+}
+
+// snapshot returns the current snapshot of the map, which is never nil.
+func (m *snapshotMap[K, V]) snapshot() *snapshot[K, V] {
+	if s := m.current.Load(); s != nil {
+		return s
+	}
+	return &snapshot[K, V]{}
+}
+
+// find returns the index of the key in the snapshot's entries, or where it
+// would be inserted.
+func (s *snapshot[K, V]) find(key K) (int, bool) {
+	return slices.BinarySearchFunc(s.entries, key, func(e snapshotEntry[K, V], k K) int {
+		return cmp.Compare(e.key, k)
+	})
+}
+
+// Load returns the value stored for the key.
+func (m *snapshotMap[K, V]) Load(key K) (V, bool) {
+	s := m.snapshot()
+	if i, ok := s.find(key); ok {
+		return s.entries[i].value, true
+	}
+	var zero V
+	return zero, false
+}
+
+// Range calls f for each key and value in order, until f returns false.
+func (m *snapshotMap[K, V]) Range(f func(key K, value V) bool) {
+	for _, e := range m.snapshot().entries {
+		if !f(e.key, e.value) {
+			return
+		}
+	}
+}
+
+// Store sets the value for the key.
+func (m *snapshotMap[K, V]) Store(key K, value V) {
+	s := m.snapshot()
+	i, ok := s.find(key)
+	entries := make([]snapshotEntry[K, V], 0, len(s.entries)+1)
+	entries = append(entries, s.entries[:i]...)
+	entries = append(entries, snapshotEntry[K, V]{key: key, value: value})
+	if ok {
+		i++
+	}
+	entries = append(entries, s.entries[i:]...)
+	m.current.Store(&snapshot[K, V]{entries: entries})
+}
+
+// Delete removes the key.
+func (m *snapshotMap[K, V]) Delete(key K) {
+	s := m.snapshot()
+	i, ok := s.find(key)
+	if !ok {
+		return
+	}
+	entries := make([]snapshotEntry[K, V], 0, len(s.entries)-1)
+	entries = append(entries, s.entries[:i]...)
+	entries = append(entries, s.entries[i+1:]...)
+	m.current.Store(&snapshot[K, V]{entries: entries})
+}
+
+// copyFrom makes the map share the current snapshot of another map, which is
+// safe since snapshots are immutable.
+func (m *snapshotMap[K, V]) copyFrom(other *snapshotMap[K, V]) {
+	m.current.Store(other.current.Load())
+}
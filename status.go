@@ -0,0 +1,160 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package eventlogger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// NodeError is an error from a node which failed to process an Event, which
+// identifies the node.  The Warnings of a Status include a NodeError for each
+// node which failed, which can be found using errors.As.
+type NodeError struct {
+	// EventType of the Event being processed
+	EventType EventType
+
+	// PipelineEventType which the Pipeline is registered for, which is a
+	// pattern (such as "audit.*") when the Event was routed to it by a pattern
+	PipelineEventType EventType
+
+	// PipelineID of the Pipeline the node was processing the Event for, which
+	// is unique for its PipelineEventType
+	PipelineID PipelineID
+
+	// NodeID of the node
+	NodeID NodeID
+
+	// NodeName of the node, if it implements Named
+	NodeName string
+
+	// Err returned by the node, or describing why it couldn't process the
+	// Event
+	Err error
+}
+
+// Error returns the message of the underlying error, prefixed with the node's
+// ID and name (if any) so that logged warnings identify the node, such as:
+//
+//	node ID "audit" (sink:/var/log/audit): write failed
+func (e *NodeError) Error() string {
+	if e.NodeName != "" {
+		return fmt.Sprintf("node ID %q (%s): %s", e.NodeID, e.NodeName, e.Err)
+	}
+	return fmt.Sprintf("node ID %q: %s", e.NodeID, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *NodeError) Unwrap() error {
+	return e.Err
+}
+
+// PipelineOutcome describes how a Pipeline finished processing an Event.
+type PipelineOutcome string
+
+const (
+	// PipelineDelivered means the Event reached a sink, which processed it
+	// successfully.
+	PipelineDelivered PipelineOutcome = "delivered"
+	// PipelineFiltered means a node removed the Event from the pipeline before
+	// it reached a sink.
+	PipelineFiltered PipelineOutcome = "filtered"
+	// PipelineFailed means a node failed to process the Event.
+	PipelineFailed PipelineOutcome = "failed"
+	// PipelineCancelled means the context passed to Send was done before the
+	// pipeline finished processing the Event.
+	PipelineCancelled PipelineOutcome = "cancelled"
+)
+
+// PipelineResult describes how a Pipeline finished processing an Event.  A
+// pipeline which fans out into branches has a result for each branch.
+type PipelineResult struct {
+	// EventType which the Pipeline is registered for, which is a pattern
+	// (such as "audit.*") when the Event was routed to it by a pattern
+	EventType EventType
+
+	// PipelineID of the Pipeline, which is unique for its EventType
+	PipelineID PipelineID
+
+	// NodeID of the terminal node: the sink which the Event was delivered to,
+	// or the node which filtered the Event or failed.  It's empty when the
+	// pipeline was cancelled before a node finished with the Event.
+	NodeID NodeID
+
+	// NodeName of the terminal node, if it implements Named
+	NodeName string
+
+	// Outcome of the pipeline
+	Outcome PipelineOutcome
+
+	// Duration from the pipeline starting to process the Event until the
+	// terminal node finished, or until Send returned when the pipeline was
+	// cancelled.
+	Duration time.Duration
+
+	// Err from the terminal node, only set when Outcome is PipelineFailed or
+	// PipelineCancelled.
+	Err error
+}
+
+// Pipelines returns the result of each Pipeline which the Event was sent to,
+// sorted by pipeline ID, EventType and then terminal node ID.
+func (s Status) Pipelines() []PipelineResult {
+	return s.pipelines
+}
+
+// branchResult describes the branch of the pipelineRun which finished at node.
+// An error caused by ctx being done means the pipeline was cancelled.
+func branchResult(ctx context.Context, run *pipelineRun, node *linkedNode, e *Event, err error) PipelineResult {
+	r := PipelineResult{
+		EventType:  run.eventType,
+		PipelineID: run.id,
+		NodeID:     node.nodeID,
+		NodeName:   node.name,
+		Duration:   time.Since(run.start),
+		Err:        err,
+	}
+
+	switch {
+	case err != nil && ctx.Err() != nil && (errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)):
+		r.Outcome = PipelineCancelled
+	case err != nil:
+		r.Outcome = PipelineFailed
+	case e == nil && node.node.Type() != NodeTypeSink:
+		r.Outcome = PipelineFiltered
+	default:
+		r.Outcome = PipelineDelivered
+	}
+	return r
+}
+
+// sortPipelineResults sorts results by pipeline ID, EventType and then terminal
+// node ID.
+func sortPipelineResults(results []PipelineResult) {
+	sort.SliceStable(results, func(i, j int) bool {
+		switch {
+		case results[i].PipelineID != results[j].PipelineID:
+			return results[i].PipelineID < results[j].PipelineID
+		case results[i].EventType != results[j].EventType:
+			return results[i].EventType < results[j].EventType
+		}
+		return results[i].NodeID < results[j].NodeID
+	})
+}
+
+// mergeStatuses combines the statuses of the graphs which processed an Event.
+func mergeStatuses(statuses []Status) Status {
+	var merged Status
+	for _, s := range statuses {
+		merged.complete = append(merged.complete, s.complete...)
+		merged.completeSinks = append(merged.completeSinks, s.completeSinks...)
+		merged.Warnings = append(merged.Warnings, s.Warnings...)
+		merged.pipelines = append(merged.pipelines, s.pipelines...)
+	}
+	sortPipelineResults(merged.pipelines)
+	return merged
+}
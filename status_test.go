@@ -0,0 +1,186 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package eventlogger
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatus_Pipelines(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	errSink := errors.New("sink failed")
+
+	b, err := NewBroker()
+	require.NoError(t, err)
+	require.NoError(t, b.RegisterNode("formatter", &JSONFormatter{}))
+	require.NoError(t, b.RegisterNode("filter", &Filter{Predicate: func(*Event) (bool, error) { return false, nil }}))
+	require.NoError(t, b.RegisterNode("sink", &testSink{}))
+	require.NoError(t, b.RegisterNode("other-sink", &testSink{}))
+	require.NoError(t, b.RegisterNode("bad-sink", &testActionNode{
+		action: func(context.Context, *Event) (*Event, error) { return nil, errSink },
+	}))
+	require.NoError(t, b.RegisterPipeline(Pipeline{PipelineID: "delivered", EventType: "t", NodeIDs: []NodeID{"formatter", "sink"}}))
+	require.NoError(t, b.RegisterPipeline(Pipeline{PipelineID: "filtered", EventType: "t", NodeIDs: []NodeID{"filter", "formatter", "sink"}}))
+	require.NoError(t, b.RegisterPipeline(Pipeline{PipelineID: "failed", EventType: "t", NodeIDs: []NodeID{"formatter", "bad-sink"}}))
+	require.NoError(t, b.RegisterPipeline(Pipeline{
+		PipelineID: "branches",
+		EventType:  "t",
+		Edges:      map[NodeID][]NodeID{"formatter": {"other-sink", "bad-sink"}},
+	}))
+
+	status, err := b.Send(ctx, "t", "payload")
+	require.NoError(t, err)
+
+	type result struct {
+		pipeline PipelineID
+		node     NodeID
+		outcome  PipelineOutcome
+		err      error
+	}
+	var got []result
+	for _, r := range status.Pipelines() {
+		assert.Positive(t, r.Duration)
+		got = append(got, result{r.PipelineID, r.NodeID, r.Outcome, r.Err})
+	}
+	assert.Equal(t, []result{
+		{"branches", "bad-sink", PipelineFailed, errSink},
+		{"branches", "other-sink", PipelineDelivered, nil},
+		{"delivered", "sink", PipelineDelivered, nil},
+		{"failed", "bad-sink", PipelineFailed, errSink},
+		{"filtered", "filter", PipelineFiltered, nil},
+	}, got)
+
+	// The existing accessors still work.
+	assert.ElementsMatch(t, []NodeID{"other-sink", "sink", "filter"}, status.Complete())
+	assert.ElementsMatch(t, []NodeID{"other-sink", "sink"}, status.CompleteSinks())
+
+	require.Len(t, status.Warnings, 2)
+	var pipelines []PipelineID
+	for _, w := range status.Warnings {
+		assert.EqualError(t, w, `node ID "bad-sink": sink failed`)
+		assert.ErrorIs(t, w, errSink)
+
+		var nodeErr *NodeError
+		require.ErrorAs(t, w, &nodeErr)
+		assert.Equal(t, EventType("t"), nodeErr.EventType)
+		assert.Equal(t, NodeID("bad-sink"), nodeErr.NodeID)
+		pipelines = append(pipelines, nodeErr.PipelineID)
+	}
+	assert.ElementsMatch(t, []PipelineID{"branches", "failed"}, pipelines)
+}
+
+func TestStatus_Pipelines_Cancelled(t *testing.T) {
+	t.Parallel()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+
+	b, err := NewBroker()
+	require.NoError(t, err)
+	require.NoError(t, b.RegisterNode("formatter", &JSONFormatter{}))
+	// The sink returns as soon as its context is done.
+	require.NoError(t, b.RegisterNode("cancelled-sink", &testActionNode{
+		action: func(ctx context.Context, _ *Event) (*Event, error) {
+			started <- struct{}{}
+			<-ctx.Done()
+			return nil, ctx.Err()
+		},
+	}))
+	// The sink ignores its context.
+	require.NoError(t, b.RegisterNode("blocked-sink", &testActionNode{
+		action: func(context.Context, *Event) (*Event, error) {
+			started <- struct{}{}
+			<-release
+			return nil, nil
+		},
+	}))
+	require.NoError(t, b.RegisterPipeline(Pipeline{PipelineID: "p1", EventType: "t", NodeIDs: []NodeID{"formatter", "cancelled-sink"}}))
+	require.NoError(t, b.RegisterPipeline(Pipeline{PipelineID: "p2", EventType: "t", NodeIDs: []NodeID{"formatter", "blocked-sink"}}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-started
+		<-started
+		cancel()
+	}()
+
+	status, err := b.Send(ctx, "t", "payload")
+	require.NoError(t, err)
+
+	results := status.Pipelines()
+	require.Len(t, results, 2)
+	for _, r := range results {
+		assert.Equal(t, PipelineCancelled, r.Outcome)
+		assert.ErrorIs(t, r.Err, context.Canceled)
+		assert.Equal(t, EventType("t"), r.EventType)
+	}
+
+	// The cancelled sink may or may not have returned before Send.
+	assert.Equal(t, PipelineID("p2"), results[1].PipelineID)
+	assert.Empty(t, results[1].NodeID)
+	assert.Positive(t, results[1].Duration)
+	if results[0].NodeID != "" {
+		assert.Equal(t, NodeID("cancelled-sink"), results[0].NodeID)
+	}
+}
+
+// namedNode is a sink which fails, and has a name.
+type namedNode struct {
+	testActionNode
+	name string
+}
+
+func (n *namedNode) Name() string {
+	return n.name
+}
+
+func TestStatus_NodeNames(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	var observations []NodeObservation
+	b, err := NewBroker(WithObserver(ObserverFunc(func(_ context.Context, o NodeObservation) {
+		observations = append(observations, o)
+	})))
+	require.NoError(t, err)
+	sink := &namedNode{
+		testActionNode: testActionNode{action: func(context.Context, *Event) (*Event, error) { return nil, errors.New("write failed") }},
+		name:           "sink:/var/log/audit",
+	}
+	// The circuit breaker is unwrapped to name the sink.
+	cb, err := NewCircuitBreaker(sink, 10, time.Second)
+	require.NoError(t, err)
+	require.NoError(t, b.RegisterNode("json", &JSONFormatter{}))
+	require.NoError(t, b.RegisterNode("audit", cb))
+	require.NoError(t, b.RegisterPipeline(Pipeline{PipelineID: "p", EventType: "t", NodeIDs: []NodeID{"json", "audit"}}))
+
+	status, err := b.Send(ctx, "t", "payload")
+	require.NoError(t, err)
+
+	require.Len(t, status.Warnings, 1)
+	var nodeErr *NodeError
+	require.ErrorAs(t, status.Warnings[0], &nodeErr)
+	assert.Equal(t, NodeID("audit"), nodeErr.NodeID)
+	assert.Equal(t, "sink:/var/log/audit", nodeErr.NodeName)
+	assert.EqualError(t, status.Warnings[0], `node ID "audit" (sink:/var/log/audit): write failed`)
+
+	require.Len(t, status.Pipelines(), 1)
+	assert.Equal(t, "sink:/var/log/audit", status.Pipelines()[0].NodeName)
+
+	require.Len(t, observations, 2)
+	assert.Equal(t, "JSONFormatter", observations[0].NodeName)
+	assert.Equal(t, "sink:/var/log/audit", observations[1].NodeName)
+
+	assert.Equal(t, "sink:/var/log/audit", NewNodeController(cb).Name())
+	assert.Empty(t, NewNodeController(&testActionNode{}).Name())
+}
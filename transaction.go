@@ -0,0 +1,358 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package eventlogger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+// Transaction stages changes to the nodes and pipelines registered with a
+// Broker, so that they can be applied atomically by Commit: either every
+// change is applied, or none are.  Events sent while a Transaction is being
+// committed are routed using either the previous or the new configuration,
+// never a mixture of the two.
+//
+// A Transaction is not safe for concurrent use.
+type Transaction struct {
+	b         *Broker
+	changes   []stagedChange
+	committed bool
+}
+
+// stagedChange is a change made by a Transaction, which is applied to the
+// staged copy of the Broker's registry during Commit.
+type stagedChange struct {
+	// desc describes the change, for errors
+	desc string
+
+	apply func(s *stagedRegistry) error
+}
+
+// retiredNode is a node which is no longer registered with the Broker, but
+// which may still be processing events.
+type retiredNode struct {
+	id   NodeID
+	node Node
+}
+
+// Begin starts a Transaction, which stages changes to the Broker until it's
+// committed.
+func (b *Broker) Begin() *Transaction {
+	return &Transaction{b: b}
+}
+
+// RegisterNode stages the registration of a node, see Broker.RegisterNode.
+// Invalid parameters are reported immediately, and anything else is reported
+// by Commit.
+func (tx *Transaction) RegisterNode(id NodeID, node Node, opt ...Option) error {
+	if id == "" {
+		return fmt.Errorf("unable to register node, node ID cannot be empty: %w", ErrInvalidParameter)
+	}
+	opts, err := getOpts(opt...)
+	if err != nil {
+		return fmt.Errorf("cannot register node: %w", err)
+	}
+
+	tx.changes = append(tx.changes, stagedChange{
+		desc: fmt.Sprintf("register node ID %q", id),
+		apply: func(s *stagedRegistry) error {
+			return s.registerNode(id, node, opts)
+		},
+	})
+	return nil
+}
+
+// RemoveNode stages the removal of a node, which must not be referenced by any
+// pipeline once the preceding changes have been applied.  The node is closed
+// by Commit.
+func (tx *Transaction) RemoveNode(id NodeID) error {
+	if id == "" {
+		return fmt.Errorf("unable to remove node, node ID cannot be empty: %w", ErrInvalidParameter)
+	}
+
+	tx.changes = append(tx.changes, stagedChange{
+		desc: fmt.Sprintf("remove node ID %q", id),
+		apply: func(s *stagedRegistry) error {
+			n, ok := s.nodes[id]
+			switch {
+			case !ok:
+				return fmt.Errorf("%w: %q", ErrNodeNotFound, id)
+			case n.referenceCount > 0:
+				return fmt.Errorf("cannot remove node, as it is still in use by 1 or more pipelines: %q", id)
+			}
+			s.retire(id)
+			return nil
+		},
+	})
+	return nil
+}
+
+// RegisterPipeline stages the registration of a pipeline, see
+// Broker.RegisterPipeline.  The pipeline's nodes may be registered by the same
+// Transaction.  As with Broker.RegisterPipeline, overwriting a pipeline
+// doesn't release the nodes of the pipeline it replaces, so remove the
+// pipeline first when it should.
+func (tx *Transaction) RegisterPipeline(def Pipeline, opt ...Option) error {
+	if err := def.validate(); err != nil {
+		return err
+	}
+	opts, err := getOpts(opt...)
+	if err != nil {
+		return fmt.Errorf("cannot register pipeline: %w", err)
+	}
+
+	tx.changes = append(tx.changes, stagedChange{
+		desc: fmt.Sprintf("register pipeline ID %q for event type %q", def.PipelineID, def.EventType),
+		apply: func(s *stagedRegistry) error {
+			s.replaceGraph(def.EventType)
+			return s.registerPipeline(def, opts)
+		},
+	})
+	return nil
+}
+
+// RemovePipeline stages the removal of a pipeline.  Unlike
+// Broker.RemovePipeline, the pipeline's nodes are released, so that they can be
+// removed by the same Transaction.
+func (tx *Transaction) RemovePipeline(t EventType, id PipelineID) error {
+	return tx.removePipeline(t, id, false)
+}
+
+// RemovePipelineAndNodes stages the removal of a pipeline along with any of its
+// nodes which are no longer referenced by another pipeline, see
+// Broker.RemovePipelineAndNodes.  The removed nodes are closed by Commit.
+func (tx *Transaction) RemovePipelineAndNodes(t EventType, id PipelineID) error {
+	return tx.removePipeline(t, id, true)
+}
+
+// removePipeline stages the removal of a pipeline, releasing its nodes and
+// optionally removing those which are no longer referenced.
+func (tx *Transaction) removePipeline(t EventType, id PipelineID, removeNodes bool) error {
+	switch {
+	case t == "":
+		return fmt.Errorf("event type cannot be empty: %w", ErrInvalidParameter)
+	case id == "":
+		return fmt.Errorf("pipeline ID cannot be empty: %w", ErrInvalidParameter)
+	}
+
+	tx.changes = append(tx.changes, stagedChange{
+		desc: fmt.Sprintf("remove pipeline ID %q for event type %q", id, t),
+		apply: func(s *stagedRegistry) error {
+			if _, ok := s.graphs[t]; !ok {
+				return fmt.Errorf("no graph for EventType %s", t)
+			}
+			g := s.replaceGraph(t)
+			ids, err := g.roots.Nodes(id)
+			if err != nil {
+				return fmt.Errorf("unable to retrieve all nodes referenced by pipeline ID %q: %w", id, err)
+			}
+			g.roots.Delete(id)
+
+			for _, nodeID := range ids {
+				n, ok := s.nodes[nodeID]
+				if !ok {
+					continue
+				}
+				if n.referenceCount > 0 {
+					n.referenceCount--
+				}
+				if removeNodes && n.referenceCount == 0 {
+					s.retire(nodeID)
+				}
+			}
+			return nil
+		},
+	})
+	return nil
+}
+
+// Commit validates and applies every staged change to the Broker atomically,
+// in the order they were staged.  If any change can't be applied, an error
+// identifying it is returned and the Broker is left unchanged.  Nodes which
+// implement Opener are opened using ctx once every change has been applied,
+// when they're first referenced by a pipeline, and if any of them fails to
+// open the Broker is left unchanged, except that nodes which were already
+// registered and have been opened stay open.  They're opened without blocking
+// the methods which read the registry, such as Health.
+//
+// Once the changes are applied, Commit waits for any events which were being
+// processed by the previous configuration and then closes the nodes which
+// were removed, aggregating any errors (as multierror.Error).  If ctx is done
+// first, an error is returned and the removed nodes are closed by Broker.Close
+// instead.
+func (tx *Transaction) Commit(ctx context.Context) error {
+	const op = "eventlogger.(Transaction).Commit"
+	if tx.committed {
+		return fmt.Errorf("%s: transaction has already been committed: %w", op, ErrInvalidParameter)
+	}
+
+	b := tx.b
+	s, draining, err := tx.apply(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if len(s.retired) == 0 {
+		return nil
+	}
+
+	if err := waitForDraining(ctx, draining); err != nil {
+		b.lock.Lock()
+		b.retired = append(b.retired, s.retired...)
+		b.lock.Unlock()
+		return fmt.Errorf("%s: changes were committed, but events are still being sent so removed nodes will be closed by Broker.Close: %w", op, err)
+	}
+
+	var errs *multierror.Error
+	for _, r := range s.retired {
+		nc := NewNodeController(r.node)
+		if err := nc.Close(ctx); err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("unable to close node ID %q: %w", r.id, err))
+		}
+	}
+	return errs.ErrorOrNil()
+}
+
+// apply stages the changes, opens the nodes they reference and then swaps the
+// staged registry into the Broker.  It returns the staged registry and the
+// graphs which may still be processing events using the retired nodes.  The
+// nodes are opened without holding the Broker's lock, so that they don't block
+// the methods which read the registry; writeLock prevents the registry from
+// changing meanwhile.
+func (tx *Transaction) apply(ctx context.Context) (*stagedRegistry, []*graph, error) {
+	b := tx.b
+	b.writeLock.Lock()
+	defer b.writeLock.Unlock()
+
+	b.lock.Lock()
+	if b.closed {
+		b.lock.Unlock()
+		return nil, nil, ErrBrokerClosed
+	}
+
+	s := newStagedRegistry(&b.registry)
+	for _, c := range tx.changes {
+		if err := c.apply(s); err != nil {
+			b.lock.Unlock()
+			return nil, nil, fmt.Errorf("unable to %s: %w", c.desc, err)
+		}
+	}
+	if err := s.validate(); err != nil {
+		b.lock.Unlock()
+		return nil, nil, err
+	}
+	unopened := s.unopenedNodes(s.referencedNodes())
+	b.lock.Unlock()
+
+	opened, err := openNodes(ctx, unopened)
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if err != nil {
+		return nil, nil, errors.Join(err, b.keepOpened(ctx, opened))
+	}
+	markOpened(opened)
+	b.nodes = s.nodes
+	graphs := make(map[EventType]*graph, len(s.cloned))
+	for t := range s.cloned {
+		graphs[t] = s.graphs[t]
+	}
+	b.replaceGraphs(graphs)
+	tx.committed = true
+	// Every graph which has been replaced (not just by this Transaction) may
+	// still be processing events using the retired nodes.
+	return s, append([]*graph(nil), b.draining...), nil
+}
+
+// stagedRegistry is a copy of a Broker's registry which a Transaction's
+// changes are applied to.  Graphs are copied before they're changed, so that
+// events being sent are unaffected until the registry is swapped into the
+// Broker.
+type stagedRegistry struct {
+	registry
+
+	// cloned are the event types whose graph has been copied (or created)
+	cloned map[EventType]struct{}
+
+	// retired are the nodes which have been removed
+	retired []retiredNode
+}
+
+// newStagedRegistry creates a stagedRegistry from the Broker's registry.
+func newStagedRegistry(r *registry) *stagedRegistry {
+	s := &stagedRegistry{
+		registry: registry{
+			nodes:       make(map[NodeID]*nodeUsage, len(r.nodes)),
+			graphs:      make(map[EventType]*graph, len(r.graphs)),
+			observer:    r.observer,
+			pool:        r.pool,
+			panicPolicy: r.panicPolicy,
+		},
+		cloned: make(map[EventType]struct{}),
+	}
+	for id, n := range r.nodes {
+		copied := *n
+		s.nodes[id] = &copied
+	}
+	for t, g := range r.graphs {
+		s.graphs[t] = g
+	}
+	return s
+}
+
+// replaceGraph ensures the graph for the EventType is a copy (or new), which
+// can be changed, and returns it.
+func (s *stagedRegistry) replaceGraph(t EventType) *graph {
+	if _, ok := s.cloned[t]; !ok {
+		s.cloned[t] = struct{}{}
+		if g, ok := s.graphs[t]; ok {
+			s.graphs[t] = g.clone()
+		}
+	}
+	return s.graph(t)
+}
+
+// retire removes the node, so that it's closed once the Transaction is
+// committed.
+func (s *stagedRegistry) retire(id NodeID) {
+	s.retired = append(s.retired, retiredNode{id: id, node: s.nodes[id].node})
+	delete(s.nodes, id)
+}
+
+// validate ensures every node referenced by the changed graphs is still
+// registered.
+func (s *stagedRegistry) validate() error {
+	var err error
+	for t := range s.cloned {
+		s.graphs[t].roots.Range(func(id PipelineID, p *registeredPipeline) bool {
+			for nodeID := range p.rootNode.flatten() {
+				if _, ok := s.nodes[nodeID]; !ok {
+					err = errors.Join(err, fmt.Errorf("pipeline ID %q for event type %q references node ID %q which isn't registered", id, t, nodeID))
+				}
+			}
+			return true
+		})
+	}
+	return err
+}
+
+// referencedNodes returns the nodes referenced by the pipelines of the changed
+// graphs.
+func (s *stagedRegistry) referencedNodes() map[NodeID]Node {
+	nodes := make(map[NodeID]Node)
+	for t := range s.cloned {
+		s.graphs[t].roots.Range(func(_ PipelineID, p *registeredPipeline) bool {
+			for id, n := range p.nodes() {
+				nodes[id] = n
+			}
+			return true
+		})
+	}
+	return nodes
+}
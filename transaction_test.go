@@ -0,0 +1,269 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package eventlogger
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransaction_Commit(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	b, err := NewBroker()
+	require.NoError(t, err)
+	old, other := &countingCloser{}, &countingCloser{}
+	require.NoError(t, b.RegisterNode("formatter", &JSONFormatter{}))
+	require.NoError(t, b.RegisterNode("old", old))
+	require.NoError(t, b.RegisterNode("other", other))
+	require.NoError(t, b.RegisterPipeline(Pipeline{PipelineID: "p1", EventType: "t", NodeIDs: []NodeID{"formatter", "old"}}))
+	require.NoError(t, b.RegisterPipeline(Pipeline{PipelineID: "p2", EventType: "t", NodeIDs: []NodeID{"formatter", "other"}}))
+
+	// Replace both pipelines with one which uses a new sink.
+	replacement := &countingCloser{}
+	tx := b.Begin()
+	require.NoError(t, tx.RegisterNode("new", replacement))
+	require.NoError(t, tx.RemovePipeline("t", "p1"))
+	require.NoError(t, tx.RemoveNode("old"))
+	require.NoError(t, tx.RegisterPipeline(Pipeline{PipelineID: "p3", EventType: "t", NodeIDs: []NodeID{"formatter", "new"}}))
+	require.NoError(t, tx.RemovePipelineAndNodes("t", "p2"))
+
+	// Nothing changes until the transaction is committed.
+	_, err = b.Send(ctx, "t", "before")
+	require.NoError(t, err)
+	assert.Equal(t, 1, old.count)
+	assert.Equal(t, 1, other.count)
+	assert.Len(t, b.Pipelines("t"), 2)
+
+	require.NoError(t, tx.Commit(ctx))
+	assert.Equal(t, int32(1), old.closed.Load())
+	assert.Equal(t, int32(1), other.closed.Load())
+	assert.Equal(t, []PipelineInfo{{
+		ID:                 "p3",
+		EventType:          "t",
+		NodeIDs:            []NodeID{"formatter", "new"},
+		Edges:              map[NodeID][]NodeID{"formatter": {"new"}},
+		RegistrationPolicy: AllowOverwrite,
+	}}, b.Pipelines("t"))
+	assert.Equal(t, []NodeInfo{
+		{ID: "formatter", Name: "JSONFormatter", Type: NodeTypeFormatter, ReferenceCount: 1, RegistrationPolicy: AllowOverwrite},
+		{ID: "new", Name: "testSink", Type: NodeTypeSink, ReferenceCount: 1, RegistrationPolicy: AllowOverwrite},
+	}, b.Nodes())
+
+	_, err = b.Send(ctx, "t", "after")
+	require.NoError(t, err)
+	assert.Equal(t, 1, old.count)
+	assert.Equal(t, 1, replacement.count)
+
+	err = tx.Commit(ctx)
+	require.ErrorIs(t, err, ErrInvalidParameter)
+
+	require.NoError(t, b.Close(ctx))
+	assert.Equal(t, int32(1), old.closed.Load())
+	assert.Equal(t, int32(1), replacement.closed.Load())
+}
+
+func TestTransaction_Commit_AllOrNothing(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	b, err := NewBroker()
+	require.NoError(t, err)
+	sink := &countingCloser{}
+	require.NoError(t, b.RegisterNode("formatter", &JSONFormatter{}))
+	require.NoError(t, b.RegisterNode("sink", sink))
+	require.NoError(t, b.RegisterPipeline(Pipeline{PipelineID: "p1", EventType: "t", NodeIDs: []NodeID{"formatter", "sink"}}))
+	want := b.Topology()
+
+	tests := map[string]func(tx *Transaction){
+		"missing-node": func(tx *Transaction) {
+			require.NoError(t, tx.RemovePipeline("t", "p1"))
+			require.NoError(t, tx.RegisterPipeline(Pipeline{PipelineID: "p2", EventType: "t", NodeIDs: []NodeID{"formatter", "missing"}}))
+		},
+		"node-still-referenced": func(tx *Transaction) {
+			require.NoError(t, tx.RegisterNode("other", &testSink{}))
+			require.NoError(t, tx.RemoveNode("sink"))
+		},
+		"missing-pipeline": func(tx *Transaction) {
+			require.NoError(t, tx.RemovePipelineAndNodes("t", "p1"))
+			require.NoError(t, tx.RemovePipelineAndNodes("t", "p1"))
+		},
+		"deny-overwrite": func(tx *Transaction) {
+			require.NoError(t, tx.RegisterNode("sink-2", &testSink{}, WithNodeRegistrationPolicy(DenyOverwrite)))
+			require.NoError(t, tx.RegisterNode("sink-2", &testSink{}))
+		},
+	}
+	for name, stage := range tests {
+		t.Run(name, func(t *testing.T) {
+			tx := b.Begin()
+			stage(tx)
+			require.Error(t, tx.Commit(ctx))
+			assert.Equal(t, want, b.Topology())
+			assert.Equal(t, int32(0), sink.closed.Load())
+		})
+	}
+}
+
+func TestTransaction_InvalidParameters(t *testing.T) {
+	t.Parallel()
+
+	b, err := NewBroker()
+	require.NoError(t, err)
+	tx := b.Begin()
+
+	require.ErrorIs(t, tx.RegisterNode("", &testSink{}), ErrInvalidParameter)
+	require.ErrorIs(t, tx.RegisterNode("sink", &testSink{}, WithNodeTimeout(-1)), ErrInvalidParameter)
+	require.ErrorIs(t, tx.RemoveNode(""), ErrInvalidParameter)
+	require.Error(t, tx.RegisterPipeline(Pipeline{EventType: "t"}))
+	require.ErrorIs(t, tx.RemovePipeline("", "p"), ErrInvalidParameter)
+	require.ErrorIs(t, tx.RemovePipelineAndNodes("t", ""), ErrInvalidParameter)
+
+	// Nothing was staged, so committing does nothing.
+	require.NoError(t, tx.Commit(context.Background()))
+	assert.Empty(t, b.Nodes())
+
+	require.NoError(t, b.Close(context.Background()))
+	require.ErrorIs(t, b.Begin().Commit(context.Background()), ErrBrokerClosed)
+}
+
+func TestTransaction_Commit_InFlight(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	started, release := make(chan struct{}, 1), make(chan struct{})
+	var processed atomic.Int32
+	sink := &mockCloser{Node: blockingSink(started, release, &processed)}
+	b := newTestBroker(t, map[NodeID]Node{"sink": sink}, []Pipeline{testPipeline})
+	require.NoError(t, b.SetSuccessThresholdSinks("t", 1))
+
+	sent := make(chan error)
+	go func() {
+		_, err := b.Send(ctx, "t", "in-flight")
+		sent <- err
+	}()
+	<-started
+
+	tx := b.Begin()
+	require.NoError(t, tx.RemovePipelineAndNodes("t", "p"))
+
+	// The changes are applied straight away, but the removed sink isn't closed
+	// while it's processing an event.
+	commitCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	err := tx.Commit(commitCtx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.False(t, sink.closed)
+	assert.Empty(t, b.Pipelines("t"))
+	assert.Empty(t, b.Nodes())
+
+	close(release)
+	require.NoError(t, <-sent)
+	assert.Equal(t, int32(1), processed.Load())
+
+	// The sink is closed by the broker instead.
+	require.NoError(t, b.Close(ctx))
+	assert.True(t, sink.closed)
+}
+
+func TestTransaction_Commit_WaitsForInFlight(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	started, release := make(chan struct{}, 1), make(chan struct{})
+	var processed atomic.Int32
+	sink := &mockCloser{Node: blockingSink(started, release, &processed)}
+	b := newTestBroker(t, map[NodeID]Node{"sink": sink}, []Pipeline{testPipeline})
+	require.NoError(t, b.SetSuccessThresholdSinks("t", 1))
+
+	sent := make(chan error)
+	go func() {
+		_, err := b.Send(ctx, "t", "in-flight")
+		sent <- err
+	}()
+	<-started
+
+	tx := b.Begin()
+	require.NoError(t, tx.RemovePipelineAndNodes("t", "p"))
+	committed := make(chan error)
+	go func() {
+		committed <- tx.Commit(ctx)
+	}()
+
+	select {
+	case err := <-committed:
+		t.Fatalf("commit returned before in-flight event finished: %v", err)
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	close(release)
+	require.NoError(t, <-sent)
+	require.NoError(t, <-committed)
+	assert.True(t, sink.closed)
+	require.NoError(t, b.Close(ctx))
+}
+
+func TestTransaction_Commit_WaitsForCancelledSend(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	started, release := make(chan struct{}, 1), make(chan struct{})
+	var processed atomic.Int32
+	sink := &mockCloser{Node: blockingSink(started, release, &processed)}
+	b := newTestBroker(t, map[NodeID]Node{"sink": sink}, []Pipeline{testPipeline})
+
+	sendCtx, cancel := context.WithCancel(ctx)
+	sent := make(chan error)
+	go func() {
+		_, err := b.Send(sendCtx, "t", "in-flight")
+		sent <- err
+	}()
+	<-started
+
+	// Send returns once its context is cancelled, but the sink is still
+	// processing the event so mustn't be closed.
+	cancel()
+	<-sent
+
+	tx := b.Begin()
+	require.NoError(t, tx.RemovePipelineAndNodes("t", "p"))
+	committed := make(chan error)
+	go func() {
+		committed <- tx.Commit(ctx)
+	}()
+
+	select {
+	case err := <-committed:
+		t.Fatalf("commit returned before the sink finished processing: %v", err)
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	close(release)
+	require.NoError(t, <-committed)
+	assert.Equal(t, int32(1), processed.Load())
+	assert.True(t, sink.closed)
+	require.NoError(t, b.Close(ctx))
+}
+
+func TestTransaction_Commit_CloseError(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	b, err := NewBroker()
+	require.NoError(t, err)
+	failing := &countingCloser{closeErr: errors.New("flush failed")}
+	require.NoError(t, b.RegisterNode("failing", failing))
+
+	tx := b.Begin()
+	require.NoError(t, tx.RemoveNode("failing"))
+	err = tx.Commit(ctx)
+	require.EqualError(t, err, "1 error occurred:\n\t* unable to close node ID \"failing\": flush failed\n\n")
+	assert.Empty(t, b.Nodes())
+}